@@ -0,0 +1,14 @@
+package dto
+
+import "github.com/nikitalystsev/BookSmart-web-api/core/models"
+
+type APIKeyInputDTO struct {
+	Name   string   `json:"name" binding:"required,max=255"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=catalog:read reservations:write"`
+}
+
+// APIKeyOutputDTO contains the key itself, which is shown only once.
+type APIKeyOutputDTO struct {
+	Key    string                  `json:"key"`
+	APIKey *models.JSONAPIKeyModel `json:"api_key"`
+}
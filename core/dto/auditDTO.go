@@ -0,0 +1,15 @@
+package dto
+
+// AuditParamsInputDTO is the query of the audit log. Times are in RFC 3339,
+// the period includes From and excludes To.
+type AuditParamsInputDTO struct {
+	ActorID      string `form:"actor_id" binding:"omitempty,uuid_not_nil"`
+	ActorRole    string `form:"actor_role"`
+	Action       string `form:"action"`
+	ResourceType string `form:"resource_type"`
+	ResourceID   string `form:"resource_id"`
+	RequestID    string `form:"request_id"`
+	From         string `form:"from" binding:"omitempty,date_time"`
+	To           string `form:"to" binding:"omitempty,date_time"`
+	PageNumber   string `form:"page_number" binding:"omitempty,query_number,startsnotwith=0"`
+}
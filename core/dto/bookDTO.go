@@ -0,0 +1,18 @@
+package dto
+
+// BookParamsInputDTO is the query of the catalog search. Numbers are kept as
+// strings, so that all invalid parameters are reported at once; "NaN" and
+// "null" sent by the frontend mean that the parameter is not set.
+type BookParamsInputDTO struct {
+	Title          string `form:"title"`
+	Author         string `form:"author"`
+	Publisher      string `form:"publisher"`
+	Rarity         string `form:"rarity"`
+	Genre          string `form:"genre"`
+	Language       string `form:"language"`
+	CopiesNumber   string `form:"copies_number" binding:"omitempty,query_number"`
+	PublishingYear string `form:"publishing_year" binding:"omitempty,query_number"`
+	AgeLimit       string `form:"age_limit" binding:"omitempty,query_number"`
+	PageNumber     string `form:"page_number" binding:"omitempty,query_number,startsnotwith=0"`
+	SortBy         string `form:"sort_by" binding:"omitempty,oneof=rating_score"`
+}
@@ -1,5 +1,19 @@
 package dto
 
-type ErrorResponse struct {
-	ErrorMsg string `json:"error_msg"`
+// ProblemDetails is an error response in the format of RFC 7807.
+// Code is a stable machine-readable identifier of the error.
+type ProblemDetails struct {
+	Type          string             `json:"type"`
+	Title         string             `json:"title"`
+	Status        int                `json:"status"`
+	Detail        string             `json:"detail,omitempty"`
+	Instance      string             `json:"instance,omitempty"`
+	Code          string             `json:"code"`
+	RequestID     string             `json:"request_id,omitempty"`
+	InvalidParams []*InvalidParamDTO `json:"invalid_params,omitempty"`
+}
+
+type InvalidParamDTO struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
 }
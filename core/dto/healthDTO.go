@@ -0,0 +1,25 @@
+package dto
+
+type HealthOutputDTO struct {
+	Status string `json:"status"`
+}
+
+type DependencyCheckDTO struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+type ReadinessOutputDTO struct {
+	Status string                `json:"status"`
+	Checks []*DependencyCheckDTO `json:"checks"`
+}
+
+type VersionOutputDTO struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+	Modified  bool   `json:"modified"`
+}
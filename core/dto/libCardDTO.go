@@ -0,0 +1,5 @@
+package dto
+
+type LibCardStatusInputDTO struct {
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/core/models"
+	"time"
+)
+
+type LookupReaderDTO struct {
+	ID          uuid.UUID `json:"id"`
+	Fio         string    `json:"fio"`
+	PhoneNumber string    `json:"phone_number"`
+	Age         uint      `json:"age"`
+}
+
+type FineDTO struct {
+	ReservationID uuid.UUID `json:"reservation_id"`
+	ReturnDate    time.Time `json:"return_date"`
+	OverdueDays   int       `json:"overdue_days"`
+	Amount        float64   `json:"amount"`
+}
+
+type LibCardLookupOutputDTO struct {
+	Reader              *LookupReaderDTO         `json:"reader"`
+	LibCard             *models.JSONLibCardModel `json:"lib_card"`
+	IsLibCardValid      bool                     `json:"is_lib_card_valid"`
+	OpenReservations    []*ReservationOutputDTO  `json:"open_reservations"`
+	OverdueReservations []*ReservationOutputDTO  `json:"overdue_reservations"`
+	Fines               []*FineDTO               `json:"fines"`
+	TotalFine           float64                  `json:"total_fine"`
+}
+
+type BookCopyLookupOutputDTO struct {
+	Book                    *models.JSONBookModel          `json:"book"`
+	CopyNum                 uint                           `json:"copy_num,omitempty"`
+	ActiveReservations      []*models.JSONReservationModel `json:"active_reservations"`
+	ActiveReservationsCount int                            `json:"active_reservations_count"`
+}
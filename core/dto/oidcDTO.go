@@ -0,0 +1,14 @@
+package dto
+
+type OIDCLoginInputDTO struct {
+	LoginHint string `form:"login_hint" binding:"max=255"`
+}
+
+// OIDCCallbackInputDTO is the redirect back from the provider, which contains
+// either the code or the error of the login.
+type OIDCCallbackInputDTO struct {
+	State            string `form:"state" binding:"required"`
+	Code             string `form:"code" binding:"required_without=Error"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
@@ -0,0 +1,46 @@
+package dto
+
+import "github.com/google/uuid"
+
+type ReviewOutputDTO struct {
+	ID        uuid.UUID `json:"id"`
+	ReaderFio string    `json:"reader_fio"`
+	Review    string    `json:"review"`
+	Rating    int       `json:"rating"`
+}
+
+type RatingInputDTO struct {
+	ReaderID string `json:"reader_id" binding:"required,uuid_not_nil"`
+	Review   string `json:"review"`
+	Rating   int    `json:"rating" binding:"min=1,max=5"`
+}
+
+type ReviewReportInputDTO struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type ReviewModerationInputDTO struct {
+	Action string `json:"action" binding:"required,oneof=approve hide delete"`
+	Reason string `json:"reason" binding:"required_unless=Action approve"`
+}
+
+type RatingBucketDTO struct {
+	Rating int `json:"rating"`
+	Count  int `json:"count"`
+}
+
+type RatingTrendPointDTO struct {
+	Period    string  `json:"period"`
+	Count     int     `json:"count"`
+	AvgRating float32 `json:"avg_rating"`
+}
+
+type RatingStatsOutputDTO struct {
+	Histogram     []*RatingBucketDTO     `json:"histogram"`
+	TotalCount    int                    `json:"total_count"`
+	AvgRating     float32                `json:"avg_rating"`
+	BayesianScore float32                `json:"bayesian_score"`
+	Trend         []*RatingTrendPointDTO `json:"trend"`
+	// UntrackedCount ratings were submitted before their times were recorded and are missing from Trend
+	UntrackedCount int `json:"untracked_count"`
+}
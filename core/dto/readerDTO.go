@@ -0,0 +1,21 @@
+package dto
+
+type SignUpInputDTO struct {
+	Fio         string `json:"fio" binding:"required,max=255"`
+	PhoneNumber string `json:"phone_number" binding:"required,phone"`
+	Age         uint   `json:"age" binding:"required,min=1,max=150"`
+	Password    string `json:"password" binding:"required,len=10"`
+}
+
+type SignInInputDTO struct {
+	PhoneNumber string `json:"phone_number" binding:"required,phone"`
+	Password    string `json:"password" binding:"required"`
+}
+
+type RefreshTokenInputDTO struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type FavoriteBookInputDTO struct {
+	BookID string `json:"book_id" binding:"required,uuid_not_nil"`
+}
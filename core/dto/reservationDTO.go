@@ -0,0 +1,23 @@
+package dto
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type ReservationInputDTO struct {
+	BookID string `json:"book_id" binding:"required,uuid_not_nil"`
+}
+
+type ReservationExtentionPeriodDaysInputDTO struct {
+	ExtentionPeriodDays int `json:"extention_period_days" binding:"required,min=1"`
+}
+
+type ReservationOutputDTO struct {
+	ID                 uuid.UUID `json:"id"`
+	BookTitleAndAuthor string    `json:"book_title_and_author"`
+	IssueDate          time.Time `json:"issue_date"`
+	ReturnDate         time.Time `json:"return_date"`
+	State              string    `json:"state"`
+	Version            string    `json:"version"`
+}
@@ -0,0 +1,43 @@
+package dto
+
+import "github.com/google/uuid"
+
+type TwoFactorCodeInputDTO struct {
+	Code string `json:"code" binding:"required,max=32"` // of the authenticator app or a recovery code
+}
+
+type TwoFactorChallengeInputDTO struct {
+	ChallengeToken string `json:"challenge_token" binding:"required,max=255"`
+}
+
+type TwoFactorVerifyInputDTO struct {
+	ChallengeToken string `json:"challenge_token" binding:"required,max=255"`
+	Code           string `json:"code" binding:"required,max=32"`
+}
+
+// TwoFactorChallengeOutputDTO is returned by sign-in instead of tokens when the second
+// factor is needed. Users who must use it but have not enrolled enroll first.
+type TwoFactorChallengeOutputDTO struct {
+	ChallengeToken       string `json:"challenge_token"`
+	IsEnrollmentRequired bool   `json:"enrollment_required"`
+	ExpiredAt            int64  `json:"expired_at"`
+}
+
+type TwoFactorEnrollmentOutputDTO struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // to be shown as a QR code
+}
+
+// TwoFactorSignInOutputDTO contains recovery codes if the enrolment was completed
+// by the sign-in, they are shown only once.
+type TwoFactorSignInOutputDTO struct {
+	ReaderID      uuid.UUID `json:"reader_id"`
+	AccessToken   string    `json:"access_token"`
+	RefreshToken  string    `json:"refresh_token"`
+	ExpiredAt     int64     `json:"expired_at"`
+	RecoveryCodes []string  `json:"recovery_codes,omitempty"`
+}
+
+type RecoveryCodesOutputDTO struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
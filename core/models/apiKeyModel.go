@@ -0,0 +1,22 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type JSONAPIKeyUsageModel struct {
+	Count      int64            `json:"count"`
+	LastUsedAt *time.Time       `json:"last_used_at,omitempty"`
+	ByRoute    map[string]int64 `json:"by_route"`
+}
+
+type JSONAPIKeyModel struct {
+	ID        uuid.UUID            `json:"id"`
+	Name      string               `json:"name"`
+	Scopes    []string             `json:"scopes"`
+	CreatedAt time.Time            `json:"created_at"`
+	RotatedAt *time.Time           `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time           `json:"revoked_at,omitempty"`
+	Usage     JSONAPIKeyUsageModel `json:"usage"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
+
+type JSONAuditEntryModel struct {
+	ID           uuid.UUID       `json:"id"`
+	Time         time.Time       `json:"time"`
+	ActorID      string          `json:"actor_id,omitempty"`
+	ActorRole    string          `json:"actor_role,omitempty"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id,omitempty"`
+	Before       json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After        json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	RequestID    string          `json:"request_id,omitempty"`
+	Route        string          `json:"route"`
+	Status       int             `json:"status"`
+}
@@ -13,4 +13,5 @@ type JSONBookModel struct {
 	PublishingYear uint      `json:"publishing_year"`
 	Language       string    `json:"language"`
 	AgeLimit       uint      `json:"age_limit"`
+	Version        string    `json:"version"`
 }
@@ -5,11 +5,25 @@ import (
 	"time"
 )
 
+type JSONLibCardStatusModel struct {
+	Event              string    `json:"event"`
+	Reason             string    `json:"reason"`
+	LibCardNum         string    `json:"lib_card_num"`
+	PreviousLibCardNum string    `json:"previous_lib_card_num,omitempty"`
+	ActorID            uuid.UUID `json:"actor_id"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
 type JSONLibCardModel struct {
-	ID           uuid.UUID `json:"id"`
-	ReaderID     uuid.UUID `json:"reader_id"`
-	LibCardNum   string    `json:"lib_card_num"`
-	Validity     int       `json:"validity"`
-	IssueDate    time.Time `json:"issue_date"`
-	ActionStatus bool      `json:"action_status"`
+	ID             uuid.UUID                 `json:"id"`
+	ReaderID       uuid.UUID                 `json:"reader_id"`
+	LibCardNum     string                    `json:"lib_card_num"`
+	Validity       int                       `json:"validity"`
+	IssueDate      time.Time                 `json:"issue_date"`
+	ExpirationDate time.Time                 `json:"expiration_date"`
+	ActionStatus   bool                      `json:"action_status"`
+	Status         string                    `json:"status"`
+	BlockReason    string                    `json:"block_reason,omitempty"`
+	StatusHistory  []*JSONLibCardStatusModel `json:"status_history"`
+	Version        string                    `json:"version"`
 }
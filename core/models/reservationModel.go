@@ -12,4 +12,5 @@ type JSONReservationModel struct {
 	IssueDate  time.Time `json:"issue_date"`
 	ReturnDate time.Time `json:"return_date"`
 	State      string    `json:"state"`
+	Version    string    `json:"version"`
 }
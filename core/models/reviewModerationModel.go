@@ -0,0 +1,30 @@
+package models
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type JSONReviewReportModel struct {
+	ReaderID  uuid.UUID `json:"reader_id"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type JSONModerationDecisionModel struct {
+	ModeratorID     uuid.UUID `json:"moderator_id"`
+	Action          string    `json:"action"`
+	Reason          string    `json:"reason"`
+	ResolvedReports int       `json:"resolved_reports"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type JSONReviewModerationModel struct {
+	Rating       JSONRatingModel                `json:"rating"`
+	State        string                         `json:"state"`
+	FlaggedWords []string                       `json:"flagged_words"`
+	Reports      []*JSONReviewReportModel       `json:"reports"`
+	Decisions    []*JSONModerationDecisionModel `json:"decisions"`
+	QueuedAt     time.Time                      `json:"queued_at"`
+	Version      string                         `json:"version"`
+}
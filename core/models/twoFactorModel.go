@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type JSONTwoFactorStatusModel struct {
+	IsEnabled           bool       `json:"enabled"`
+	IsEnrollmentPending bool       `json:"enrollment_pending"`
+	IsRequired          bool       `json:"required"`
+	RecoveryCodesLeft   int        `json:"recovery_codes_left"`
+	EnabledAt           *time.Time `json:"enabled_at,omitempty"`
+}
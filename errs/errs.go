@@ -3,5 +3,59 @@ package errs
 import "errors"
 
 var (
-	ErrRatingOutOfBounds = errors.New("error! Rating out of bounds")
+	ErrEmptyAuthHeader   = errors.New("error! Empty auth header")
+	ErrInvalidAuthHeader = errors.New("error! Invalid auth header")
+	ErrEmptyToken        = errors.New("error! Token is empty")
+	ErrInvalidToken      = errors.New("error! Invalid token")
+	ErrAccessDenied      = errors.New("error! Access denied")
+
+	ErrClientCertificateRequired = errors.New("error! Client certificate is required")
+
+	ErrInvalidOIDCState   = errors.New("error! Invalid or expired OIDC login state")
+	ErrInvalidOIDCCode    = errors.New("error! OIDC authorization code is rejected by the provider")
+	ErrInvalidIDToken     = errors.New("error! Invalid OIDC ID token")
+	ErrOIDCProviderFailed = errors.New("error! OIDC provider is unavailable")
+	ErrNoStaffRole        = errors.New("error! OIDC groups are not mapped to a staff role")
+
+	ErrInvalidChallengeToken         = errors.New("error! Invalid or expired two-factor challenge token")
+	ErrInvalidTwoFactorCode          = errors.New("error! Invalid two-factor code")
+	ErrTwoFactorIsAlreadyEnabled     = errors.New("error! Two-factor authentication is already enabled")
+	ErrTwoFactorIsNotEnabled         = errors.New("error! Two-factor authentication is not enabled")
+	ErrTwoFactorEnrollmentNotStarted = errors.New("error! Two-factor enrolment is not started")
+	ErrTwoFactorRequired             = errors.New("error! Two-factor authentication is required for the role")
+	ErrTwoFactorUnavailable          = errors.New("error! Two-factor authentication is unavailable")
+
+	ErrInvalidAPIKey          = errors.New("error! Invalid API key")
+	ErrAPIKeyIsRevoked        = errors.New("error! API key is revoked")
+	ErrAPIKeyNotAllowed       = errors.New("error! API keys are not accepted by this route")
+	ErrAPIKeyScopeMissing     = errors.New("error! API key lacks the scope of this route")
+	ErrAPIKeyDoesNotExists    = errors.New("error! API key does not exist")
+	ErrAPIKeyIsAlreadyRevoked = errors.New("error! API key is already revoked")
+
+	ErrPreconditionRequired = errors.New("error! If-Match header is required")
+	ErrPreconditionFailed   = errors.New("error! Resource version does not match If-Match")
+
+	ErrReviewDoesNotExists     = errors.New("error! Review does not exist")
+	ErrReviewAlreadyReported   = errors.New("error! Review already reported by this reader")
+	ErrReviewIsDeleted         = errors.New("error! Review is deleted")
+	ErrInvalidModerationAction = errors.New("error! Invalid moderation action")
+	ErrEmptyModerationReason   = errors.New("error! Empty moderation reason")
+	ErrEmptyReportReason       = errors.New("error! Empty report reason")
+
+	ErrLibCardIsBlocked         = errors.New("error! LibCard is blocked")
+	ErrLibCardIsNotBlocked      = errors.New("error! LibCard is not blocked")
+	ErrEmptyLibCardStatusReason = errors.New("error! Empty libCard status change reason")
+	ErrLibCardNumIsUnknown      = errors.New("error! LibCard number is unknown")
+	ErrLibCardNumIsReplaced     = errors.New("error! LibCard number was replaced")
+	ErrLibCardNumIsNotGenerated = errors.New("error! Unique libCard number was not generated")
+
+	ErrInvalidBookCopyBarcode = errors.New("error! Invalid book copy barcode")
+
+	ErrRateLimitExceeded = errors.New("error! Rate limit exceeded")
+
+	ErrUnsupportedMediaType = errors.New("error! Request body must be JSON")
+
+	ErrInvalidIdempotencyKey       = errors.New("error! Invalid idempotency key")
+	ErrIdempotencyKeyReused        = errors.New("error! Idempotency key is reused with another request")
+	ErrIdempotentRequestInProgress = errors.New("error! Request with this idempotency key is in progress")
 )
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
+	jsonmodels "github.com/nikitalystsev/BookSmart-web-api/core/models"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/apikey"
+	"net/http"
+	"time"
+)
+
+// @Summary Метод выпуска API-ключа для интеграции
+// @Security ApiKeyAuth
+// @Tags admin
+// @ID issueAPIKey
+// @Accept  json
+// @Produce  json
+// @Param input body dto.APIKeyInputDTO true "Название интеграции и разрешения ключа"
+// @Success 201 {object} dto.APIKeyOutputDTO "Ключ выпущен, он показывается только один раз"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/api_keys [post]
+func (h *Handler) issueAPIKey(c *gin.Context) {
+	var inp jsondto.APIKeyInputDTO
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	scopes := make([]apikey.Scope, 0, len(inp.Scopes))
+	for _, scope := range inp.Scopes {
+		scopes = append(scopes, apikey.Scope(scope))
+	}
+
+	key, rawKey, err := h.apiKeyRegistry.Issue(c.Request.Context(), inp.Name, scopes)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	jsonKey := h.convertToJSONAPIKeyModel(key)
+	setAuditResourceID(c, key.ID)
+	h.setAuditAfter(c, jsonKey)
+
+	c.JSON(http.StatusCreated, jsondto.APIKeyOutputDTO{Key: rawKey, APIKey: jsonKey})
+}
+
+// @Summary Метод получения API-ключей с их использованием
+// @Security ApiKeyAuth
+// @Tags admin
+// @ID getAPIKeys
+// @Produce  json
+// @Success 200 {array} models.JSONAPIKeyModel "Успешное получение ключей"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/api_keys [get]
+func (h *Handler) getAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyRegistry.GetAll(c.Request.Context())
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	jsonKeys := make([]*jsonmodels.JSONAPIKeyModel, 0, len(keys))
+	for _, key := range keys {
+		jsonKeys = append(jsonKeys, h.convertToJSONAPIKeyModel(key))
+	}
+
+	c.JSON(http.StatusOK, jsonKeys)
+}
+
+// @Summary Метод получения API-ключа с его использованием
+// @Security ApiKeyAuth
+// @Tags admin
+// @ID getAPIKeyByID
+// @Produce  json
+// @Param key_id path string true "Идентификатор ключа"
+// @Success 200 {object} models.JSONAPIKeyModel "Успешное получение ключа"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Ключ не найден"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/api_keys/{key_id} [get]
+func (h *Handler) getAPIKeyByID(c *gin.Context) {
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		h.abortWithInvalidParam(c, "key_id", err)
+		return
+	}
+
+	key, err := h.apiKeyRegistry.GetByID(c.Request.Context(), keyID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.convertToJSONAPIKeyModel(key))
+}
+
+// @Summary Метод замены секрета API-ключа
+// @Security ApiKeyAuth
+// @Tags admin
+// @ID rotateAPIKey
+// @Produce  json
+// @Param key_id path string true "Идентификатор ключа"
+// @Success 200 {object} dto.APIKeyOutputDTO "Новый ключ, прежний больше не действует"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Ключ не найден"
+// @Failure 409 {object} dto.ProblemDetails "Ключ отозван"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/api_keys/{key_id}/rotate [post]
+func (h *Handler) rotateAPIKey(c *gin.Context) {
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		h.abortWithInvalidParam(c, "key_id", err)
+		return
+	}
+
+	if !h.setAPIKeyAuditBefore(c, keyID) {
+		return
+	}
+
+	key, rawKey, err := h.apiKeyRegistry.Rotate(c.Request.Context(), keyID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	jsonKey := h.convertToJSONAPIKeyModel(key)
+	h.setAuditAfter(c, jsonKey)
+
+	c.JSON(http.StatusOK, jsondto.APIKeyOutputDTO{Key: rawKey, APIKey: jsonKey})
+}
+
+// @Summary Метод отзыва API-ключа
+// @Security ApiKeyAuth
+// @Tags admin
+// @ID revokeAPIKey
+// @Produce  json
+// @Param key_id path string true "Идентификатор ключа"
+// @Success 200 {object} models.JSONAPIKeyModel "Ключ отозван"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Ключ не найден"
+// @Failure 409 {object} dto.ProblemDetails "Ключ уже отозван"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/api_keys/{key_id} [delete]
+func (h *Handler) revokeAPIKey(c *gin.Context) {
+	keyID, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		h.abortWithInvalidParam(c, "key_id", err)
+		return
+	}
+
+	if !h.setAPIKeyAuditBefore(c, keyID) {
+		return
+	}
+
+	key, err := h.apiKeyRegistry.Revoke(c.Request.Context(), keyID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	jsonKey := h.convertToJSONAPIKeyModel(key)
+	h.setAuditAfter(c, jsonKey)
+
+	c.JSON(http.StatusOK, jsonKey)
+}
+
+// setAPIKeyAuditBefore keeps the key before the change, aborting the request if it can not be read.
+func (h *Handler) setAPIKeyAuditBefore(c *gin.Context, keyID uuid.UUID) bool {
+	key, err := h.apiKeyRegistry.GetByID(c.Request.Context(), keyID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return false
+	}
+
+	h.setAuditBefore(c, h.convertToJSONAPIKeyModel(key))
+
+	return true
+}
+
+func (h *Handler) convertToJSONAPIKeyModel(key *apikey.Key) *jsonmodels.JSONAPIKeyModel {
+	scopes := make([]string, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, string(scope))
+	}
+
+	return &jsonmodels.JSONAPIKeyModel{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    scopes,
+		CreatedAt: key.CreatedAt,
+		RotatedAt: h.getOptionalTime(key.RotatedAt),
+		RevokedAt: h.getOptionalTime(key.RevokedAt),
+		Usage: jsonmodels.JSONAPIKeyUsageModel{
+			Count:      key.Usage.Count,
+			LastUsedAt: h.getOptionalTime(key.Usage.LastUsedAt),
+			ByRoute:    key.Usage.ByRoute,
+		},
+	}
+}
+
+func (h *Handler) getOptionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+
+	return &t
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
+	jsonmodels "github.com/nikitalystsev/BookSmart-web-api/core/models"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/audit"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/validation"
+	"net/http"
+	"time"
+)
+
+const jsonLinesContentType = "application/x-ndjson"
+
+// @Summary Метод получения журнала аудита изменений
+// @Security ApiKeyAuth
+// @Tags admin_audit
+// @ID getAuditLog
+// @Produce  json
+// @Param actor_id query string false "Идентификатор пользователя или API-ключа"
+// @Param actor_role query string false "Роль пользователя, APIKey для интеграций"
+// @Param action query string false "Действие, например lib_card.create"
+// @Param resource_type query string false "Тип ресурса, например reservation"
+// @Param resource_id query string false "Идентификатор ресурса"
+// @Param request_id query string false "Идентификатор запроса (X-Request-ID)"
+// @Param from query string false "Начало периода в формате RFC 3339 (включительно)"
+// @Param to query string false "Конец периода в формате RFC 3339 (не включительно)"
+// @Param page_number query string false "Номер страницы, записи упорядочены от новых к старым"
+// @Success 200 {array} models.JSONAuditEntryModel "Успешное получение записей журнала"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения параметров запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/audit [get]
+func (h *Handler) getAuditLog(c *gin.Context) {
+	filter, err := h.getAuditFilter(c)
+	if err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	entries, err := h.auditLog.Query(c.Request.Context(), filter)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	jsonEntries := make([]*jsonmodels.JSONAuditEntryModel, 0, len(entries))
+	for _, entry := range entries {
+		jsonEntries = append(jsonEntries, h.convertToJSONAuditEntryModel(entry))
+	}
+
+	c.JSON(http.StatusOK, jsonEntries)
+}
+
+// @Summary Метод выгрузки журнала аудита в формате JSON Lines
+// @Security ApiKeyAuth
+// @Tags admin_audit
+// @ID exportAuditLog
+// @Produce  application/x-ndjson
+// @Param actor_id query string false "Идентификатор пользователя или API-ключа"
+// @Param actor_role query string false "Роль пользователя, APIKey для интеграций"
+// @Param action query string false "Действие, например lib_card.create"
+// @Param resource_type query string false "Тип ресурса, например reservation"
+// @Param resource_id query string false "Идентификатор ресурса"
+// @Param request_id query string false "Идентификатор запроса (X-Request-ID)"
+// @Param from query string false "Начало периода в формате RFC 3339 (включительно)"
+// @Param to query string false "Конец периода в формате RFC 3339 (не включительно)"
+// @Success 200 {string} string "Все подходящие записи, по одной в строке, от новых к старым"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения параметров запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/audit/export [get]
+func (h *Handler) exportAuditLog(c *gin.Context) {
+	filter, err := h.getAuditFilter(c)
+	if err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+	// the export is not paged
+	filter.Limit, filter.Offset = 0, 0
+
+	entries, err := h.auditLog.Query(c.Request.Context(), filter)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", jsonLinesContentType)
+	c.Header("Content-Disposition", `attachment; filename="audit.jsonl"`)
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	for _, entry := range entries {
+		if err = encoder.Encode(h.convertToJSONAuditEntryModel(entry)); err != nil {
+			// the status is already sent, the client sees a truncated file
+			h.logger.WithContext(c.Request.Context()).WithError(err).Warn("audit log export failed")
+			return
+		}
+	}
+}
+
+func (h *Handler) getAuditFilter(c *gin.Context) (*audit.Filter, error) {
+	var inp jsondto.AuditParamsInputDTO
+	if err := c.ShouldBindQuery(&inp); err != nil {
+		return nil, err
+	}
+
+	filter := &audit.Filter{
+		ActorID:      inp.ActorID,
+		ActorRole:    inp.ActorRole,
+		Action:       inp.Action,
+		ResourceType: inp.ResourceType,
+		ResourceID:   inp.ResourceID,
+		RequestID:    inp.RequestID,
+		Limit:        int(h.pageSize),
+	}
+
+	// the formats are checked by the binding
+	if inp.From != "" {
+		filter.From, _ = time.Parse(validation.DateTimeLayout, inp.From)
+	}
+	if inp.To != "" {
+		filter.To, _ = time.Parse(validation.DateTimeLayout, inp.To)
+	}
+	if h.isNoEmptyField(inp.PageNumber) {
+		pageNumber, err := h.getUintFromStr(inp.PageNumber)
+		if err != nil {
+			return nil, err
+		}
+		filter.Offset = int((pageNumber - 1) * h.pageSize)
+	}
+
+	return filter, nil
+}
+
+func (h *Handler) convertToJSONAuditEntryModel(entry *audit.Entry) *jsonmodels.JSONAuditEntryModel {
+	return &jsonmodels.JSONAuditEntryModel{
+		ID:           entry.ID,
+		Time:         entry.Time,
+		ActorID:      entry.ActorID,
+		ActorRole:    entry.ActorRole,
+		Action:       entry.Action,
+		ResourceType: entry.ResourceType,
+		ResourceID:   entry.ResourceID,
+		Before:       entry.Before,
+		After:        entry.After,
+		RequestID:    entry.RequestID,
+		Route:        entry.Route,
+		Status:       entry.Status,
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/audit"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/logging"
+	"net/http"
+)
+
+const (
+	auditRouteKey      = "auditRoute"
+	auditActorIDKey    = "auditActorID"
+	auditActorRoleKey  = "auditActorRole"
+	auditResourceIDKey = "auditResourceID"
+	auditBeforeKey     = "auditBefore"
+	auditAfterKey      = "auditAfter"
+	// apiKeyActorRole is the role of integrations, their actor ID is the ID of the API key.
+	apiKeyActorRole = "APIKey"
+)
+
+type auditRoute struct {
+	Action       string
+	ResourceType string
+	// ResourceParam is the path parameter identifying the resource, if the handler does not set it.
+	ResourceParam string
+}
+
+// auditedRoutes lists the state-changing routes and the action each one records.
+var auditedRoutes = map[string]auditRoute{
+	"POST /api/v1/auth/sign-up":               {Action: "reader.sign_up", ResourceType: "reader"},
+	"POST /api/v1/auth/sign-in/2fa/enroll":    {Action: "two_factor.enroll", ResourceType: "two_factor"},
+	"POST /api/v1/auth/2fa/enroll":            {Action: "two_factor.enroll", ResourceType: "two_factor"},
+	"POST /api/v1/auth/2fa/confirm":           {Action: "two_factor.confirm", ResourceType: "two_factor"},
+	"POST /api/v1/auth/2fa/disable":           {Action: "two_factor.disable", ResourceType: "two_factor"},
+	"POST /api/v1/auth/2fa/recovery_codes":    {Action: "two_factor.regenerate_recovery_codes", ResourceType: "two_factor"},
+	"POST /api/v1/readers/:id/favorite_books": {Action: "reader.add_favorite_book", ResourceType: "reader", ResourceParam: "id"},
+
+	"POST /api/v1/books/:id/ratings":                    {Action: "rating.create", ResourceType: "rating"},
+	"POST /api/v1/books/:id/ratings/:rating_id/reports": {Action: "rating.report", ResourceType: "rating", ResourceParam: "rating_id"},
+
+	"POST /api/v1/readers/:id/lib_cards": {Action: "lib_card.create", ResourceType: "lib_card"},
+	"PUT /api/v1/readers/:id/lib_cards":  {Action: "lib_card.update", ResourceType: "lib_card"},
+
+	"POST /api/v1/readers/:id/reservations":                  {Action: "reservation.create", ResourceType: "reservation"},
+	"PATCH /api/v1/readers/:id/reservations/:reservation_id": {Action: "reservation.extend", ResourceType: "reservation", ResourceParam: "reservation_id"},
+
+	"PATCH /api/v1/admin/reviews/:rating_id": {Action: "rating.moderate", ResourceType: "rating", ResourceParam: "rating_id"},
+
+	"POST /api/v1/admin/readers/:id/lib_cards/block":   {Action: "lib_card.block", ResourceType: "lib_card"},
+	"POST /api/v1/admin/readers/:id/lib_cards/unblock": {Action: "lib_card.unblock", ResourceType: "lib_card"},
+	"POST /api/v1/admin/readers/:id/lib_cards/replace": {Action: "lib_card.replace", ResourceType: "lib_card"},
+
+	"POST /api/v1/admin/api_keys":                {Action: "api_key.issue", ResourceType: "api_key"},
+	"POST /api/v1/admin/api_keys/:key_id/rotate": {Action: "api_key.rotate", ResourceType: "api_key", ResourceParam: "key_id"},
+	"DELETE /api/v1/admin/api_keys/:key_id":      {Action: "api_key.revoke", ResourceType: "api_key", ResourceParam: "key_id"},
+}
+
+// recordAudit appends an entry to the audit log for every successful request to a route
+// of auditedRoutes. Handlers add snapshots of the resource, responses replayed to
+// idempotent retries are not recorded, since nothing was changed by them.
+func (h *Handler) recordAudit(c *gin.Context) {
+	c.Next()
+
+	route, ok := auditedRoutes[c.Request.Method+" "+c.FullPath()]
+	if value, isSet := c.Get(auditRouteKey); isSet {
+		route, ok = value.(auditRoute)
+	}
+	if !ok || c.Writer.Status() >= http.StatusBadRequest || c.Writer.Header().Get(idempotentReplayedHeader) != "" {
+		return
+	}
+
+	// the request context may be canceled by its deadline once the response is written
+	ctx := context.WithoutCancel(c.Request.Context())
+
+	actorID, actorRole := getAuditActor(c)
+	resourceID := c.GetString(auditResourceIDKey)
+	if resourceID == "" && route.ResourceParam != "" {
+		resourceID = c.Param(route.ResourceParam)
+	}
+
+	entry := &audit.Entry{
+		ActorID:      actorID,
+		ActorRole:    actorRole,
+		Action:       route.Action,
+		ResourceType: route.ResourceType,
+		ResourceID:   resourceID,
+		RequestID:    logging.RequestIDFromContext(ctx),
+		Route:        c.Request.Method + " " + c.FullPath(),
+		Status:       c.Writer.Status(),
+	}
+	if before, isSet := c.Get(auditBeforeKey); isSet {
+		entry.Before = before.(json.RawMessage)
+	}
+	if after, isSet := c.Get(auditAfterKey); isSet {
+		entry.After = after.(json.RawMessage)
+	}
+
+	if err := h.auditLog.Append(ctx, entry); err != nil {
+		h.metrics.IncAuditAppendFailure()
+		h.logger.WithContext(ctx).WithError(err).WithField("action", route.Action).Error("audit entry appending failed")
+	}
+}
+
+// getAuditActor returns the user of the token or the API key, unless the handler
+// knows the actor otherwise, e.g. on the second step of sign-in.
+func getAuditActor(c *gin.Context) (string, string) {
+	if actorID := c.GetString(auditActorIDKey); actorID != "" {
+		return actorID, c.GetString(auditActorRoleKey)
+	}
+	if isAPIKeyRequest(c) {
+		return c.GetString(apiKeyID), apiKeyActorRole
+	}
+
+	return c.GetString(ID), c.GetString(Role)
+}
+
+// setAuditRoute records a route which changes state only in some cases.
+func setAuditRoute(c *gin.Context, route auditRoute) {
+	c.Set(auditRouteKey, route)
+}
+
+func setAuditActor(c *gin.Context, actorID uuid.UUID, actorRole string) {
+	c.Set(auditActorIDKey, actorID.String())
+	c.Set(auditActorRoleKey, actorRole)
+}
+
+func setAuditResourceID(c *gin.Context, resourceID uuid.UUID) {
+	c.Set(auditResourceIDKey, resourceID.String())
+}
+
+// setAuditBefore keeps the snapshot of the resource before the change. It is encoded
+// at once, since the handler may change the snapshot afterwards.
+func (h *Handler) setAuditBefore(c *gin.Context, snapshot any) {
+	h.setAuditSnapshot(c, auditBeforeKey, snapshot)
+}
+
+func (h *Handler) setAuditAfter(c *gin.Context, snapshot any) {
+	h.setAuditSnapshot(c, auditAfterKey, snapshot)
+}
+
+func (h *Handler) setAuditSnapshot(c *gin.Context, key string, snapshot any) {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		h.logger.WithContext(c.Request.Context()).WithError(err).Warn("audit snapshot encoding failed")
+		return
+	}
+
+	c.Set(key, json.RawMessage(data))
+}
@@ -3,20 +3,24 @@ package handlers
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/nikitalystsev/BookSmart-services/core/dto"
 	"github.com/nikitalystsev/BookSmart-services/core/models"
 	"github.com/nikitalystsev/BookSmart-services/errs"
-	"github.com/nikitalystsev/BookSmart-services/impl"
 	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
 	jsonmodels "github.com/nikitalystsev/BookSmart-web-api/core/models"
 	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/dataloader"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/ratingstats"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
+const sortByRatingScore = "rating_score"
+
 // @Summary Метод получения книг по параметрам
 // @Tags book
 // @ID getPageBooks
@@ -32,62 +36,39 @@ import (
 // @Param publishing_year query uint false "Год издания"
 // @Param age_limit query uint false "Возрастное ограничение"
 // @Param page_number query uint true "Номер страницы для пагинации"
+// @Param sort_by query string false "Ключ сортировки (rating_score - байесовская оценка по убыванию, только книги с оценками)"
+// @Param X-API-Key header string false "API-ключ интеграции с разрешением catalog:read вместо токена"
 // @Success 200 {array} models.JSONBookModel "Список книг"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 404 {object} dto.ErrorResponse "Книги не найдены"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неверный или отозванный API-ключ"
+// @Failure 403 {object} dto.ProblemDetails "У API-ключа нет разрешения catalog:read"
+// @Failure 404 {object} dto.ProblemDetails "Книги не найдены"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/books [get]
 func (h *Handler) getPageBooks(c *gin.Context) {
-	fmt.Println("call getPageBooks")
-	var (
-		params     dto.BookParamsDTO
-		err        error
-		pageNumber uint
-	)
-
-	params.Title = c.Query("title")
-	params.Author = c.Query("author")
-	params.Publisher = c.Query("publisher")
-	params.Rarity = c.Query("rarity")
-	params.Genre = c.Query("genre")
-	params.Language = c.Query("language")
-	if h.isNoEmptyField(c.Query("copies_number")) {
-		if params.CopiesNumber, err = h.getUintFromStr(c.Query("copies_number")); err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-			return
-		}
-	}
-	if h.isNoEmptyField(c.Query("publishing_year")) {
-		if params.PublishingYear, err = h.getUintFromStr(c.Query("publishing_year")); err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-			return
-		}
-	}
+	h.logger.WithContext(c.Request.Context()).Debug("call getPageBooks")
 
-	if h.isNoEmptyField(c.Query("age_limit")) {
-		if params.AgeLimit, err = h.getUintFromStr(c.Query("age_limit")); err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-			return
-		}
+	var inp jsondto.BookParamsInputDTO
+	if err := c.ShouldBindQuery(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
 	}
 
-	if h.isNoEmptyField(c.Query("page_number")) {
-		pageNumber, err = h.getUintFromStr(c.Query("page_number"))
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-			return
-		}
-		params.Limit = impl.PageLimit
-		params.Offset = int((pageNumber - 1) * impl.PageLimit)
+	params, err := h.copyBookParamsInputDTOToBookParamsDTO(&inp)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
 	}
 
-	books, err := h.bookService.GetByParams(c.Request.Context(), &params)
-	if err != nil && errors.Is(err, errs.ErrBookDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
+	var books []*models.BookModel
+	if inp.SortBy == sortByRatingScore {
+		books, err = h.getBooksSortedByRatingScore(c, params)
+	} else {
+		books, err = h.bookService.GetByParams(c.Request.Context(), params)
 	}
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
@@ -100,28 +81,28 @@ func (h *Handler) getPageBooks(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Идентификатор книги"
+// @Param X-API-Key header string false "API-ключ интеграции с разрешением catalog:read вместо токена"
 // @Success 200 {object} models.JSONBookModel "Успешное получение книги по идентификатору"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 404 {object} dto.ErrorResponse "Книги нет"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неверный или отозванный API-ключ"
+// @Failure 403 {object} dto.ProblemDetails "У API-ключа нет разрешения catalog:read"
+// @Failure 404 {object} dto.ProblemDetails "Книги нет"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/books/{id} [get]
 func (h *Handler) getBookByID(c *gin.Context) {
 	bookID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
 	book, err := h.bookService.GetByID(c.Request.Context(), bookID)
-	if err != nil && errors.Is(err, errs.ErrBookDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
+	h.setETag(c, h.getBookVersion(book))
 	c.JSON(http.StatusOK, h.convertToJSONBookModel(book))
 }
 
@@ -134,50 +115,47 @@ func (h *Handler) getBookByID(c *gin.Context) {
 // @Param id path string true "Идентификатор читателя"
 // @Param input body dto.FavoriteBookInputDTO true "Идентификатор книги"
 // @Success 201 "Успешное добавление книги в избранное"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 401 {object} dto.ErrorResponse "Неавторизованный пользователь"
-// @Failure 403 {object} dto.ErrorResponse "Доступ запрещен"
-// @Failure 404 {object} dto.ErrorResponse "Читатель не найден"
-// @Failure 409 {object} dto.ErrorResponse " Книга уже добавлена в избранное"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Читатель не найден"
+// @Failure 409 {object} dto.ProblemDetails " Книга уже добавлена в избранное"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/readers/{id}/favorite_books [post]
 func (h *Handler) addToFavorites(c *gin.Context) {
 	readerID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
 	isReader, err := isReaderID(c, readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if !isReader {
-		c.AbortWithStatusJSON(http.StatusForbidden, jsondto.ErrorResponse{ErrorMsg: "access denied"})
+		h.abortWithError(c, weberrs.ErrAccessDenied)
 		return
 	}
 
-	var inp dto.FavoriteBookInputDTO
-	if err = c.BindJSON(&inp); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	var inp jsondto.FavoriteBookInputDTO
+	if err = c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
 		return
 	}
 
-	err = h.readerService.AddToFavorites(c.Request.Context(), readerID, inp.BookID)
-	if err != nil && errors.Is(err, errs.ErrReaderDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrBookAlreadyIsFavorite) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
+	err = h.readerService.AddToFavorites(c.Request.Context(), readerID, uuid.MustParse(inp.BookID))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
+	h.setAuditAfter(c, inp)
+
 	c.Status(http.StatusCreated)
 }
 
@@ -187,31 +165,40 @@ func (h *Handler) addToFavorites(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Идентификатор книги"
-// @Success 200 {array} dto.RatingOutputDTO "Успешное получение отзывов на книгу"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 404 {object} dto.ErrorResponse "У книги нет отзывов"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Param X-API-Key header string false "API-ключ интеграции с разрешением catalog:read вместо токена"
+// @Success 200 {array} dto.ReviewOutputDTO "Успешное получение видимых отзывов на книгу"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неверный или отозванный API-ключ"
+// @Failure 403 {object} dto.ProblemDetails "У API-ключа нет разрешения catalog:read"
+// @Failure 404 {object} dto.ProblemDetails "У книги нет отзывов"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/books/{id}/ratings [get]
 func (h *Handler) getRatingsByBookID(c *gin.Context) {
 	bookID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
 	ratings, err := h.ratingService.GetByBookID(c.Request.Context(), bookID)
-	if err != nil && errors.Is(err, errs.ErrRatingDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	if err != nil {
+		h.abortWithError(c, err)
 		return
 	}
+
+	ratings, err = h.filterVisibleRatings(c.Request.Context(), ratings)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
+		return
+	}
+	if len(ratings) == 0 {
+		h.abortWithError(c, errs.ErrRatingDoesNotExists)
 		return
 	}
 
-	ratingOutputDTOs, err := h.copyRatingModelsToRatingOutputDTOs(c.Request.Context(), ratings)
+	ratingOutputDTOs, err := h.copyRatingModelsToRatingOutputDTOs(c, ratings)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
@@ -226,70 +213,92 @@ func (h *Handler) getRatingsByBookID(c *gin.Context) {
 // @Produce  json
 // @Param id path string true "Идентификатор книги"
 // @Param input body dto.RatingInputDTO true "DTO с данными отзыва"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор запроса с тем же ключом возвращает первый ответ"
 // @Success 201 "Успешное добавление отзыва"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 401 {object} dto.ErrorResponse "Неавторизованный пользователь"
-// @Failure 403 {object} dto.ErrorResponse "Доступ запрещен"
-// @Failure 404 {object} dto.ErrorResponse "Пользователь никогда не бронировал книгу"
-// @Failure 409 {object} dto.ErrorResponse "Пользователь уже оценил книгу"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Success 202 "Отзыв содержит запрещенные слова и отправлен на модерацию"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Пользователь никогда не бронировал книгу"
+// @Failure 409 {object} dto.ProblemDetails "Пользователь уже оценил книгу"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/books/{id}/ratings [post]
 func (h *Handler) addNewRating(c *gin.Context) {
 	bookID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
-	var ratingDTO dto.RatingInputDTO
-	if err = c.BindJSON(&ratingDTO); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	var ratingDTO jsondto.RatingInputDTO
+	if err = c.ShouldBindJSON(&ratingDTO); err != nil {
+		h.abortWithBindError(c, err)
 		return
 	}
+	readerID := uuid.MustParse(ratingDTO.ReaderID)
 
-	isReader, err := isReaderID(c, ratingDTO.ReaderID)
+	isReader, err := isReaderID(c, readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if !isReader {
-		c.AbortWithStatusJSON(http.StatusForbidden, jsondto.ErrorResponse{ErrorMsg: "access denied"})
-		return
-	}
-
-	if err = h.checkRatingCanBeAdded(&ratingDTO); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-
-	if ratingDTO.Rating < 0 || ratingDTO.Rating > 5 {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: "invalid rating"})
+		h.abortWithError(c, weberrs.ErrAccessDenied)
 		return
 	}
 
 	rating := &models.RatingModel{
 		ID:       uuid.New(),
-		ReaderID: ratingDTO.ReaderID,
+		ReaderID: readerID,
 		BookID:   bookID,
 		Review:   ratingDTO.Review,
 		Rating:   ratingDTO.Rating,
 	}
 
-	err = h.ratingService.Create(c.Request.Context(), rating)
-	if errors.Is(err, errs.ErrRatingAlreadyExist) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
+	// a flagged review is held before it is created, so that it is never public
+	blockedWords := h.wordFilter.FindBlockedWords(rating.Review)
+	if len(blockedWords) > 0 {
+		if err = h.reviewModerator.Hold(c.Request.Context(), rating, blockedWords); err != nil {
+			h.abortWithError(c, err)
+			return
+		}
 	}
-	if errors.Is(err, errs.ErrReservationDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+
+	err = h.ratingService.Create(c.Request.Context(), rating)
+	if err != nil {
+		if len(blockedWords) > 0 {
+			h.releaseHeldReview(c.Request.Context(), rating.ID)
+		}
+		h.abortWithError(c, err)
 		return
 	}
+
+	// the rating is created, a missing record only leaves it out of the trend
+	err = h.ratingHistory.Add(c.Request.Context(), &ratingstats.HistoryRecord{
+		RatingID:  rating.ID,
+		BookID:    rating.BookID,
+		Rating:    rating.Rating,
+		CreatedAt: time.Now(),
+	})
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.logger.WithContext(c.Request.Context()).WithError(err).WithField("rating_id", rating.ID).Error("rating history record adding failed")
+	}
+
+	h.invalidateRatingsCache(bookID)
+	h.updateRatingScore(c.Request.Context(), bookID)
+
+	setAuditResourceID(c, rating.ID)
+	h.setAuditAfter(c, h.convertToJSONRatingModel(rating))
+
+	if len(blockedWords) == 0 {
+		c.Status(http.StatusCreated)
 		return
 	}
 
-	c.Status(http.StatusCreated)
+	c.Status(http.StatusAccepted)
 }
 
 // @Summary Метод получения среднего рейтинга книги
@@ -298,29 +307,280 @@ func (h *Handler) addNewRating(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Идентификатор книги"
+// @Param X-API-Key header string false "API-ключ интеграции с разрешением catalog:read вместо токена"
 // @Success 200 {object} dto.AvgRatingOutputDTO "Успешное получение среднего рейтинга книги"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 404 {object} dto.ErrorResponse "У книги нет отзывов"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неверный или отозванный API-ключ"
+// @Failure 403 {object} dto.ProblemDetails "У API-ключа нет разрешения catalog:read"
+// @Failure 404 {object} dto.ProblemDetails "У книги нет отзывов"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/books/{id}/ratings/avg [get]
 func (h *Handler) getAvgRatingByBookID(c *gin.Context) {
 	bookID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
-	avgRating, err := h.ratingService.GetAvgRatingByBookID(c.Request.Context(), bookID)
-	if err != nil && errors.Is(err, errs.ErrRatingDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	// hidden and deleted reviews are not counted
+	ratings, err := h.getVisibleRatingsByBookID(c.Request.Context(), bookID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+	if len(ratings) == 0 {
+		h.abortWithError(c, errs.ErrRatingDoesNotExists)
 		return
 	}
+
+	var total float32
+	for _, rating := range ratings {
+		total += float32(rating.Rating)
+	}
+
+	c.JSON(http.StatusOK, dto.AvgRatingOutputDTO{AvgRating: total / float32(len(ratings))})
+}
+
+// @Summary Метод получения статистики рейтинга книги
+// @Tags book_ratings
+// @ID getRatingStatsByBookID
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Идентификатор книги"
+// @Param X-API-Key header string false "API-ключ интеграции с разрешением catalog:read вместо токена"
+// @Success 200 {object} dto.RatingStatsOutputDTO "Успешное получение статистики рейтинга книги"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неверный или отозванный API-ключ"
+// @Failure 403 {object} dto.ProblemDetails "У API-ключа нет разрешения catalog:read"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/books/{id}/ratings/stats [get]
+func (h *Handler) getRatingStatsByBookID(c *gin.Context) {
+	bookID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, dto.AvgRatingOutputDTO{AvgRating: avgRating})
+	ratings, err := h.getVisibleRatingsByBookID(c.Request.Context(), bookID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	history, err := h.ratingHistory.GetByBookID(c.Request.Context(), bookID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.convertToRatingStatsOutputDTO(h.statsCalculator.Calculate(ratings, history)))
+}
+
+// releaseHeldReview forgets the hold of a review which was not created. A failure
+// leaves a queued review without a rating, which moderators may delete.
+func (h *Handler) releaseHeldReview(ctx context.Context, ratingID uuid.UUID) {
+	if err := h.reviewModerator.Release(ctx, ratingID); err != nil {
+		h.logger.WithContext(ctx).WithError(err).WithField("rating_id", ratingID).Warn("held review releasing failed")
+	}
+}
+
+func (h *Handler) getVisibleRatingsByBookID(ctx context.Context, bookID uuid.UUID) ([]*models.RatingModel, error) {
+	ratings, err := h.ratingService.GetByBookID(ctx, bookID)
+	if err != nil && errors.Is(err, errs.ErrRatingDoesNotExists) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return h.filterVisibleRatings(ctx, ratings)
+}
+
+// getBooksSortedByRatingScore pages through the books in the order of the score index,
+// loading books in batches only until the requested page is filled. Books without
+// visible ratings are not ranked.
+func (h *Handler) getBooksSortedByRatingScore(c *gin.Context, params *dto.BookParamsDTO) ([]*models.BookModel, error) {
+	ctx := c.Request.Context()
+
+	if err := h.completeRatingScoreIndex(c); err != nil {
+		return nil, err
+	}
+
+	bookIDs, err := h.ratingScoreIndex.GetRanked(ctx, h.statsCalculator.Score)
+	if err != nil {
+		return nil, err
+	}
+
+	// without filters every ranked book matches, so the books before the page are not loaded
+	skipped := 0
+	if !hasBookFilters(params) {
+		skipped = min(params.Offset, len(bookIDs))
+		bookIDs = bookIDs[skipped:]
+	}
+
+	batchSize := int(h.pageSize)
+	if params.Limit != 0 {
+		batchSize = int(params.Limit)
+	}
+	loader := dataloader.NewLoader(h.findBookByID, h.loaderParallelism)
+
+	var books []*models.BookModel
+	for start := 0; start < len(bookIDs); start += batchSize {
+		batch := bookIDs[start:min(start+batchSize, len(bookIDs))]
+		batchBooks, err := loader.LoadMany(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, bookID := range batch {
+			book := batchBooks[bookID]
+			if book == nil || !matchesBookParams(book, params) {
+				continue
+			}
+			if skipped < params.Offset {
+				skipped++
+				continue
+			}
+
+			books = append(books, book)
+			if params.Limit != 0 && len(books) == int(params.Limit) {
+				return books, nil
+			}
+		}
+	}
+
+	if len(books) == 0 {
+		return nil, errs.ErrBookDoesNotExists
+	}
+
+	return books, nil
+}
+
+// completeRatingScoreIndex indexes the ratings submitted before the score index existed.
+// The catalog is listed once, later ratings are indexed as they change.
+func (h *Handler) completeRatingScoreIndex(c *gin.Context) error {
+	ctx := c.Request.Context()
+
+	h.ratingScoreBackfillMu.Lock()
+	defer h.ratingScoreBackfillMu.Unlock()
+
+	isComplete, err := h.ratingScoreIndex.IsComplete(ctx)
+	if err != nil || isComplete {
+		return err
+	}
+
+	entries := make(map[uuid.UUID]ratingstats.ScoreEntry)
+	params := &dto.BookParamsDTO{Limit: h.pageSize}
+	for {
+		page, err := h.bookService.GetByParams(ctx, params)
+		if err != nil && !errors.Is(err, errs.ErrBookDoesNotExists) {
+			return err
+		}
+
+		bookIDs := make([]uuid.UUID, len(page))
+		for i, book := range page {
+			bookIDs[i] = book.ID
+		}
+		ratingsByBookID, err := h.getLoaders(c).visibleRatings.LoadMany(ctx, bookIDs)
+		if err != nil {
+			return err
+		}
+		for _, bookID := range bookIDs {
+			entries[bookID] = ratingstats.NewScoreEntry(ratingsByBookID[bookID])
+		}
+
+		if uint(len(page)) < params.Limit {
+			break
+		}
+		params.Offset += int(params.Limit)
+	}
+
+	return h.ratingScoreIndex.Backfill(ctx, entries)
+}
+
+// findBookByID returns nil for a book deleted after it was rated.
+func (h *Handler) findBookByID(ctx context.Context, bookID uuid.UUID) (*models.BookModel, error) {
+	book, err := h.bookService.GetByID(ctx, bookID)
+	if err != nil && errors.Is(err, errs.ErrBookDoesNotExists) {
+		return nil, nil
+	}
+
+	return book, err
+}
+
+// hasBookFilters reports whether the params select a part of the catalog.
+func hasBookFilters(params *dto.BookParamsDTO) bool {
+	return params.Title != "" || params.Author != "" || params.Publisher != "" ||
+		params.Rarity != "" || params.Genre != "" || params.Language != "" ||
+		params.CopiesNumber != 0 || params.PublishingYear != 0 || params.AgeLimit != 0
+}
+
+// matchesBookParams applies the catalog filters to a ranked book: text matches
+// case-insensitive substrings and numbers match exactly.
+func matchesBookParams(book *models.BookModel, params *dto.BookParamsDTO) bool {
+	texts := []struct{ value, filter string }{
+		{book.Title, params.Title},
+		{book.Author, params.Author},
+		{book.Publisher, params.Publisher},
+		{book.Rarity, params.Rarity},
+		{book.Genre, params.Genre},
+		{book.Language, params.Language},
+	}
+	for _, text := range texts {
+		if text.filter != "" && !strings.Contains(strings.ToLower(text.value), strings.ToLower(text.filter)) {
+			return false
+		}
+	}
+
+	numbers := []struct{ value, filter uint }{
+		{book.CopiesNumber, params.CopiesNumber},
+		{book.PublishingYear, params.PublishingYear},
+		{book.AgeLimit, params.AgeLimit},
+	}
+	for _, number := range numbers {
+		if number.filter != 0 && number.value != number.filter {
+			return false
+		}
+	}
+
+	return true
+}
+
+// updateRatingScore reindexes the book after its visible ratings changed. A failure is
+// only logged, since the change is already made, and the stale score is kept until the next change.
+func (h *Handler) updateRatingScore(ctx context.Context, bookID uuid.UUID) {
+	ratings, err := h.getVisibleRatingsByBookID(ctx, bookID)
+	if err == nil {
+		err = h.ratingScoreIndex.Set(ctx, bookID, ratingstats.NewScoreEntry(ratings))
+	}
+	if err != nil {
+		h.logger.WithContext(ctx).WithError(err).WithField("book_id", bookID).Error("rating score updating failed")
+	}
+}
+
+func (h *Handler) convertToRatingStatsOutputDTO(stats *ratingstats.Statistics) *jsondto.RatingStatsOutputDTO {
+	histogram := make([]*jsondto.RatingBucketDTO, len(stats.Histogram))
+	for i, count := range stats.Histogram {
+		histogram[i] = &jsondto.RatingBucketDTO{Rating: ratingstats.MinRating + i, Count: count}
+	}
+
+	trend := make([]*jsondto.RatingTrendPointDTO, len(stats.Trend))
+	for i, point := range stats.Trend {
+		trend[i] = &jsondto.RatingTrendPointDTO{
+			Period:    point.Period,
+			Count:     point.Count,
+			AvgRating: point.AvgRating,
+		}
+	}
+
+	return &jsondto.RatingStatsOutputDTO{
+		Histogram:      histogram,
+		TotalCount:     stats.TotalCount,
+		AvgRating:      stats.AvgRating,
+		BayesianScore:  stats.BayesianScore,
+		Trend:          trend,
+		UntrackedCount: stats.UntrackedCount,
+	}
 }
 
 func (h *Handler) convertArrayBooksToJSONBookModels(books []*models.BookModel) []*jsonmodels.JSONBookModel {
@@ -344,9 +604,49 @@ func (h *Handler) convertToJSONBookModel(book *models.BookModel) *jsonmodels.JSO
 		PublishingYear: book.PublishingYear,
 		Language:       book.Language,
 		AgeLimit:       book.AgeLimit,
+		Version:        h.getBookVersion(book),
 	}
 }
 
+// copyBookParamsInputDTOToBookParamsDTO expects the numbers to be validated by binding rules.
+func (h *Handler) copyBookParamsInputDTOToBookParamsDTO(inp *jsondto.BookParamsInputDTO) (*dto.BookParamsDTO, error) {
+	params := &dto.BookParamsDTO{
+		Title:     inp.Title,
+		Author:    inp.Author,
+		Publisher: inp.Publisher,
+		Rarity:    inp.Rarity,
+		Genre:     inp.Genre,
+		Language:  inp.Language,
+	}
+
+	var err error
+	if h.isNoEmptyField(inp.CopiesNumber) {
+		if params.CopiesNumber, err = h.getUintFromStr(inp.CopiesNumber); err != nil {
+			return nil, err
+		}
+	}
+	if h.isNoEmptyField(inp.PublishingYear) {
+		if params.PublishingYear, err = h.getUintFromStr(inp.PublishingYear); err != nil {
+			return nil, err
+		}
+	}
+	if h.isNoEmptyField(inp.AgeLimit) {
+		if params.AgeLimit, err = h.getUintFromStr(inp.AgeLimit); err != nil {
+			return nil, err
+		}
+	}
+	if h.isNoEmptyField(inp.PageNumber) {
+		pageNumber, err := h.getUintFromStr(inp.PageNumber)
+		if err != nil {
+			return nil, err
+		}
+		params.Limit = h.pageSize
+		params.Offset = int((pageNumber - 1) * h.pageSize)
+	}
+
+	return params, nil
+}
+
 func (h *Handler) isNoEmptyField(field string) bool {
 	return field != "" && field != "NaN" && field != "null"
 }
@@ -370,16 +670,13 @@ func (h *Handler) convertToJSONRatingModel(rating *models.RatingModel) *jsonmode
 	}
 }
 
-func (h *Handler) copyRatingModelToRatingOutputDTO(ctx context.Context, rating *models.RatingModel) (*dto.RatingOutputDTO, error) {
-	reader, err := h.readerService.GetByID(ctx, rating.ReaderID)
-	if err != nil && !errors.Is(err, errs.ErrReaderDoesNotExists) {
-		return nil, err
-	}
+func (h *Handler) copyRatingModelToRatingOutputDTO(rating *models.RatingModel, reader *models.ReaderModel) (*jsondto.ReviewOutputDTO, error) {
 	if reader == nil {
 		return nil, errs.ErrReaderDoesNotExists
 	}
 
-	ratingOutputDTO := dto.RatingOutputDTO{
+	ratingOutputDTO := jsondto.ReviewOutputDTO{
+		ID:        rating.ID,
 		ReaderFio: reader.Fio,
 		Rating:    rating.Rating,
 		Review:    rating.Review,
@@ -388,11 +685,20 @@ func (h *Handler) copyRatingModelToRatingOutputDTO(ctx context.Context, rating *
 	return &ratingOutputDTO, nil
 }
 
-func (h *Handler) copyRatingModelsToRatingOutputDTOs(ctx context.Context, ratings []*models.RatingModel) ([]*dto.RatingOutputDTO, error) {
-	ratingOutputDTOs := make([]*dto.RatingOutputDTO, len(ratings))
+func (h *Handler) copyRatingModelsToRatingOutputDTOs(c *gin.Context, ratings []*models.RatingModel) ([]*jsondto.ReviewOutputDTO, error) {
+	readerIDs := make([]uuid.UUID, len(ratings))
+	for i, rating := range ratings {
+		readerIDs[i] = rating.ReaderID
+	}
 
+	readers, err := h.getLoaders(c).readers.LoadMany(c.Request.Context(), readerIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ratingOutputDTOs := make([]*jsondto.ReviewOutputDTO, len(ratings))
 	for i, rating := range ratings {
-		outputDTO, err := h.copyRatingModelToRatingOutputDTO(ctx, rating)
+		outputDTO, err := h.copyRatingModelToRatingOutputDTO(rating, readers[rating.ReaderID])
 		if err != nil {
 			return nil, err
 		}
@@ -401,10 +707,3 @@ func (h *Handler) copyRatingModelsToRatingOutputDTOs(ctx context.Context, rating
 
 	return ratingOutputDTOs, nil
 }
-
-func (h *Handler) checkRatingCanBeAdded(ratingDTO *dto.RatingInputDTO) error {
-	if ratingDTO.Rating < 0 || ratingDTO.Rating > 5 {
-		return weberrs.ErrRatingOutOfBounds
-	}
-	return nil
-}
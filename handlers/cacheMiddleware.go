@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/cache"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	booksRoutePrefix = "/api/v1/books"
+
+	defaultCacheCapacity = 1024
+	defaultCacheTTL      = time.Minute
+)
+
+// bufferedWriter holds the response back until the cache middleware has set validators.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.body.Len() > 0
+}
+
+// cacheResponse serves successful responses of public GET routes from the
+// response cache and answers conditional requests with 304 Not Modified.
+func (h *Handler) cacheResponse(c *gin.Context) {
+	key := h.getCacheKey(c)
+
+	if entry, ok := h.responseCache.Get(key); ok {
+		h.writeCachedResponse(c, entry)
+		c.Abort()
+		return
+	}
+
+	// a write invalidating the cache while the handler reads from the services
+	// makes the response stale, so it is served but not stored
+	generation := h.responseCache.Generation()
+
+	writer := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+	c.Writer = writer
+
+	c.Next()
+
+	c.Writer = writer.ResponseWriter
+
+	if writer.status != http.StatusOK {
+		c.Writer.WriteHeader(writer.status)
+		c.Writer.WriteHeaderNow()
+		_, _ = c.Writer.Write(writer.body.Bytes())
+		return
+	}
+
+	// a handler sets the ETag of a versioned resource, other responses get the hash of the body
+	etag := c.Writer.Header().Get("ETag")
+	if etag == "" {
+		etag = h.computeETag(writer.body.Bytes())
+	}
+
+	entry := &cache.Entry{
+		Body:        writer.body.Bytes(),
+		ContentType: c.Writer.Header().Get("Content-Type"),
+		ETag:        etag,
+		ExpiresAt:   time.Now().Add(h.responseCacheTTL),
+	}
+	h.responseCache.Set(key, entry, generation)
+
+	h.writeCachedResponse(c, entry)
+}
+
+func (h *Handler) writeCachedResponse(c *gin.Context, entry *cache.Entry) {
+	maxAge := int(time.Until(entry.ExpiresAt).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+
+	c.Header("ETag", entry.ETag)
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", maxAge))
+
+	if h.isETagMatched(c.GetHeader("If-None-Match"), entry.ETag) {
+		c.Status(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	c.Data(http.StatusOK, entry.ContentType, entry.Body)
+}
+
+func (h *Handler) getCacheKey(c *gin.Context) string {
+	return c.Request.URL.Path + "?" + c.Request.URL.Query().Encode()
+}
+
+func (h *Handler) computeETag(body []byte) string {
+	sum := sha256.Sum256(body)
+
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// isETagMatched uses the weak comparison required for If-None-Match.
+func (h *Handler) isETagMatched(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (h *Handler) invalidateBookCache(bookID uuid.UUID) {
+	h.responseCache.DeleteByPrefix(fmt.Sprintf("%s/%s", booksRoutePrefix, bookID))
+	h.responseCache.DeleteByPrefix(booksRoutePrefix + "?")
+}
+
+func (h *Handler) invalidateRatingsCache(bookID uuid.UUID) {
+	h.responseCache.DeleteByPrefix(fmt.Sprintf("%s/%s/ratings", booksRoutePrefix, bookID))
+	h.responseCache.DeleteByPrefix(booksRoutePrefix + "?")
+}
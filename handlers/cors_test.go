@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testOrigin          = "https://app.booksmart.example"
+	testSubdomainOrigin = "https://*.booksmart.example"
+)
+
+func newCORSTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+
+	corsConfig := config.CORSConfig{
+		AllowOrigins:     []string{testOrigin, testSubdomainOrigin},
+		AllowCredentials: true,
+		MaxAge:           2 * time.Hour,
+	}
+
+	return NewHandler(nil, nil, nil, nil, nil, nil, time.Minute, time.Hour, WithCORS(corsConfig, "")).InitRoutes()
+}
+
+func TestCORSPreflight(t *testing.T) {
+	router := newCORSTestRouter(t)
+
+	routes := []struct {
+		group  string
+		method string
+		path   string
+	}{
+		{"public", http.MethodGet, "/api/v1/books"},
+		{"registered", http.MethodPost, "/api/v1/readers/0b0e5a4c-4a4a-4f36-8d6f-0a0b5c1f3e11/reservations"},
+		{"admin", http.MethodPatch, "/api/v1/admin/reviews/0b0e5a4c-4a4a-4f36-8d6f-0a0b5c1f3e11"},
+	}
+	origins := []struct {
+		name      string
+		origin    string
+		isAllowed bool
+	}{
+		{"exact", testOrigin, true},
+		{"subdomain", "https://admin.booksmart.example", true},
+		{"nested subdomain", "https://a.b.booksmart.example", true},
+		{"other domain", "https://evil.example", false},
+		{"domain suffix without dot", "https://evilbooksmart.example", false},
+		{"other scheme", "http://app.booksmart.example", false},
+	}
+
+	for _, route := range routes {
+		for _, origin := range origins {
+			t.Run(route.group+"/"+origin.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodOptions, route.path, nil)
+				req.Header.Set("Origin", origin.origin)
+				req.Header.Set("Access-Control-Request-Method", route.method)
+				req.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type, Idempotency-Key")
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, req)
+
+				allowOrigin := w.Header().Get("Access-Control-Allow-Origin")
+				if !origin.isAllowed {
+					if w.Code != http.StatusForbidden || allowOrigin != "" {
+						t.Fatalf("got status %d and Access-Control-Allow-Origin %q, want 403 without it", w.Code, allowOrigin)
+					}
+					return
+				}
+
+				if w.Code != http.StatusNoContent {
+					t.Fatalf("got status %d, want 204", w.Code)
+				}
+				if allowOrigin != origin.origin {
+					t.Errorf("got Access-Control-Allow-Origin %q, want %q", allowOrigin, origin.origin)
+				}
+				if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+					t.Errorf("got Access-Control-Allow-Credentials %q, want true", got)
+				}
+				if got := w.Header().Get("Access-Control-Max-Age"); got != "7200" {
+					t.Errorf("got Access-Control-Max-Age %q, want 7200", got)
+				}
+				if got := w.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(got, route.method) {
+					t.Errorf("Access-Control-Allow-Methods %q does not contain %s", got, route.method)
+				}
+				for _, header := range []string{"Authorization", "Content-Type", "Idempotency-Key"} {
+					if got := w.Header().Get("Access-Control-Allow-Headers"); !strings.Contains(strings.ToLower(got), strings.ToLower(header)) {
+						t.Errorf("Access-Control-Allow-Headers %q does not contain %s", got, header)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCORSExposedHeaders(t *testing.T) {
+	router := newCORSTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, healthzRoute, nil)
+	req.Header.Set("Origin", testOrigin)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	exposed := strings.ToLower(w.Header().Get("Access-Control-Expose-Headers"))
+	for _, header := range []string{
+		"ETag",
+		rateLimitLimitHeader,
+		rateLimitRemainingHeader,
+		rateLimitResetHeader,
+		rateLimitPolicyHeader,
+		retryAfterHeader,
+		requestIDHeader,
+		idempotentReplayedHeader,
+	} {
+		if !strings.Contains(exposed, strings.ToLower(header)) {
+			t.Errorf("Access-Control-Expose-Headers %q does not contain %s", exposed, header)
+		}
+	}
+}
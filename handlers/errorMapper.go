@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/nikitalystsev/BookSmart-services/errs"
+	"github.com/nikitalystsev/BookSmart-services/pkg/hash"
+	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/logging"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/validation"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:booksmart:problem:"
+
+	codeInternalError    = "internal_error"
+	codeValidationFailed = "validation_failed"
+	codeNotFound         = "not_found"
+	codeBodyTooLarge     = "request_body_too_large"
+
+	languageKey = "language"
+
+	queryNumberTag = "query_number"
+)
+
+type problemType struct {
+	status int
+	code   string
+	field  string // input field which caused the error, empty if the error is not about input
+}
+
+type errorProblem struct {
+	err error
+	problemType
+}
+
+// errorProblems maps errors of services and of the API to problem types.
+// Codes are part of the API contract and must not be changed.
+var errorProblems = []errorProblem{
+	{weberrs.ErrEmptyAuthHeader, problemType{http.StatusUnauthorized, "empty_auth_header", ""}},
+	{weberrs.ErrInvalidAuthHeader, problemType{http.StatusUnauthorized, "invalid_auth_header", ""}},
+	{weberrs.ErrEmptyToken, problemType{http.StatusUnauthorized, "empty_token", ""}},
+	{weberrs.ErrInvalidToken, problemType{http.StatusUnauthorized, "invalid_token", ""}},
+	{weberrs.ErrAccessDenied, problemType{http.StatusForbidden, "access_denied", ""}},
+	{weberrs.ErrClientCertificateRequired, problemType{http.StatusForbidden, "client_certificate_required", ""}},
+	{weberrs.ErrInvalidOIDCState, problemType{http.StatusBadRequest, "invalid_oidc_state", "state"}},
+	{weberrs.ErrInvalidOIDCCode, problemType{http.StatusUnauthorized, "invalid_oidc_code", "code"}},
+	{weberrs.ErrInvalidIDToken, problemType{http.StatusUnauthorized, "invalid_id_token", ""}},
+	{weberrs.ErrOIDCProviderFailed, problemType{http.StatusBadGateway, "oidc_provider_unavailable", ""}},
+	{weberrs.ErrNoStaffRole, problemType{http.StatusForbidden, "no_staff_role", ""}},
+	{weberrs.ErrInvalidChallengeToken, problemType{http.StatusUnauthorized, "invalid_challenge_token", "challenge_token"}},
+	{weberrs.ErrInvalidTwoFactorCode, problemType{http.StatusUnauthorized, "invalid_two_factor_code", "code"}},
+	{weberrs.ErrTwoFactorIsAlreadyEnabled, problemType{http.StatusConflict, "two_factor_already_enabled", ""}},
+	{weberrs.ErrTwoFactorIsNotEnabled, problemType{http.StatusConflict, "two_factor_not_enabled", ""}},
+	{weberrs.ErrTwoFactorEnrollmentNotStarted, problemType{http.StatusConflict, "two_factor_enrollment_not_started", ""}},
+	{weberrs.ErrTwoFactorRequired, problemType{http.StatusForbidden, "two_factor_required", ""}},
+	{weberrs.ErrTwoFactorUnavailable, problemType{http.StatusServiceUnavailable, "two_factor_unavailable", ""}},
+	{weberrs.ErrInvalidAPIKey, problemType{http.StatusUnauthorized, "invalid_api_key", ""}},
+	{weberrs.ErrAPIKeyIsRevoked, problemType{http.StatusUnauthorized, "api_key_revoked", ""}},
+	{weberrs.ErrAPIKeyNotAllowed, problemType{http.StatusForbidden, "api_key_not_allowed", ""}},
+	{weberrs.ErrAPIKeyScopeMissing, problemType{http.StatusForbidden, "api_key_scope_missing", ""}},
+	{weberrs.ErrAPIKeyDoesNotExists, problemType{http.StatusNotFound, "api_key_not_found", ""}},
+	{weberrs.ErrAPIKeyIsAlreadyRevoked, problemType{http.StatusConflict, "api_key_already_revoked", ""}},
+	{weberrs.ErrPreconditionRequired, problemType{http.StatusPreconditionRequired, "precondition_required", ifMatchHeader}},
+	{weberrs.ErrPreconditionFailed, problemType{http.StatusPreconditionFailed, "precondition_failed", ifMatchHeader}},
+	{weberrs.ErrRateLimitExceeded, problemType{http.StatusTooManyRequests, "rate_limit_exceeded", ""}},
+	{weberrs.ErrUnsupportedMediaType, problemType{http.StatusUnsupportedMediaType, "unsupported_media_type", contentTypeHeader}},
+	{context.DeadlineExceeded, problemType{http.StatusServiceUnavailable, "request_timeout", ""}},
+	{weberrs.ErrInvalidIdempotencyKey, problemType{http.StatusBadRequest, "invalid_idempotency_key", idempotencyKeyHeader}},
+	{weberrs.ErrIdempotencyKeyReused, problemType{http.StatusUnprocessableEntity, "idempotency_key_reused", idempotencyKeyHeader}},
+	{weberrs.ErrIdempotentRequestInProgress, problemType{http.StatusConflict, "idempotent_request_in_progress", ""}},
+
+	{errs.ErrReaderDoesNotExists, problemType{http.StatusNotFound, "reader_not_found", ""}},
+	{errs.ErrReaderAlreadyExist, problemType{http.StatusConflict, "reader_already_exists", ""}},
+	{errs.ErrReaderHasExpiredBooks, problemType{http.StatusConflict, "reader_has_expired_books", ""}},
+	{errs.ErrReaderObjectIsNil, problemType{http.StatusBadRequest, "invalid_request", ""}},
+	{errs.ErrEmptyReaderFio, problemType{http.StatusBadRequest, "empty_reader_fio", "fio"}},
+	{errs.ErrEmptyReaderPassword, problemType{http.StatusBadRequest, "empty_reader_password", "password"}},
+	{errs.ErrInvalidReaderPasswordLen, problemType{http.StatusBadRequest, "invalid_reader_password_len", "password"}},
+	{errs.ErrEmptyReaderPhoneNumber, problemType{http.StatusBadRequest, "empty_reader_phone_number", "phone_number"}},
+	{errs.ErrInvalidReaderPhoneNumberLen, problemType{http.StatusBadRequest, "invalid_reader_phone_number_len", "phone_number"}},
+	{errs.ErrInvalidReaderPhoneNumberFormat, problemType{http.StatusBadRequest, "invalid_reader_phone_number_format", "phone_number"}},
+	{errs.ErrInvalidReaderAge, problemType{http.StatusBadRequest, "invalid_reader_age", "age"}},
+	{hash.ErrInvalidLoginOrPassword, problemType{http.StatusConflict, "invalid_login_or_password", ""}},
+
+	{errs.ErrBookDoesNotExists, problemType{http.StatusNotFound, "book_not_found", ""}},
+	{errs.ErrBookAlreadyIsFavorite, problemType{http.StatusConflict, "book_already_favorite", ""}},
+	{errs.ErrBookNoCopiesNum, problemType{http.StatusConflict, "book_no_copies", ""}},
+	{weberrs.ErrInvalidBookCopyBarcode, problemType{http.StatusBadRequest, "invalid_book_copy_barcode", "barcode"}},
+
+	{errs.ErrLibCardDoesNotExists, problemType{http.StatusNotFound, "lib_card_not_found", ""}},
+	{errs.ErrLibCardAlreadyExist, problemType{http.StatusConflict, "lib_card_already_exists", ""}},
+	{errs.ErrLibCardIsValid, problemType{http.StatusConflict, "lib_card_is_valid", ""}},
+	{errs.ErrLibCardIsInvalid, problemType{http.StatusConflict, "lib_card_is_invalid", ""}},
+	{errs.ErrLibCardObjectIsNil, problemType{http.StatusBadRequest, "invalid_request", ""}},
+	{weberrs.ErrLibCardIsBlocked, problemType{http.StatusConflict, "lib_card_is_blocked", ""}},
+	{weberrs.ErrLibCardIsNotBlocked, problemType{http.StatusConflict, "lib_card_is_not_blocked", ""}},
+	{weberrs.ErrEmptyLibCardStatusReason, problemType{http.StatusBadRequest, "empty_lib_card_status_reason", "reason"}},
+	{weberrs.ErrLibCardNumIsUnknown, problemType{http.StatusNotFound, "lib_card_num_unknown", ""}},
+	{weberrs.ErrLibCardNumIsReplaced, problemType{http.StatusConflict, "lib_card_num_replaced", ""}},
+
+	{errs.ErrReservationDoesNotExists, problemType{http.StatusNotFound, "reservation_not_found", ""}},
+	{errs.ErrReservationAlreadyExists, problemType{http.StatusConflict, "reservation_already_exists", ""}},
+	{errs.ErrReservationsLimitExceeded, problemType{http.StatusConflict, "reservations_limit_exceeded", ""}},
+	{errs.ErrUniqueBookNotReserved, problemType{http.StatusConflict, "unique_book_not_reserved", ""}},
+	{errs.ErrRareAndUniqueBookNotExtended, problemType{http.StatusConflict, "rare_book_not_extended", ""}},
+	{errs.ErrReservationAgeLimit, problemType{http.StatusConflict, "reservation_age_limit", ""}},
+	{errs.ErrReservationIsAlreadyClosed, problemType{http.StatusConflict, "reservation_already_closed", ""}},
+	{errs.ErrReservationIsAlreadyExpired, problemType{http.StatusConflict, "reservation_already_expired", ""}},
+	{errs.ErrReservationIsAlreadyExtended, problemType{http.StatusConflict, "reservation_already_extended", ""}},
+	{errs.ErrReservationObjectIsNil, problemType{http.StatusBadRequest, "invalid_request", ""}},
+
+	{errs.ErrRatingDoesNotExists, problemType{http.StatusNotFound, "rating_not_found", ""}},
+	{errs.ErrRatingAlreadyExist, problemType{http.StatusConflict, "rating_already_exists", ""}},
+	{errs.ErrRatingObjectIsNil, problemType{http.StatusBadRequest, "invalid_request", ""}},
+
+	{weberrs.ErrReviewDoesNotExists, problemType{http.StatusNotFound, "review_not_found", ""}},
+	{weberrs.ErrReviewAlreadyReported, problemType{http.StatusConflict, "review_already_reported", ""}},
+	{weberrs.ErrReviewIsDeleted, problemType{http.StatusConflict, "review_is_deleted", ""}},
+	{weberrs.ErrInvalidModerationAction, problemType{http.StatusBadRequest, "invalid_moderation_action", "action"}},
+	{weberrs.ErrEmptyModerationReason, problemType{http.StatusBadRequest, "empty_moderation_reason", "reason"}},
+	{weberrs.ErrEmptyReportReason, problemType{http.StatusBadRequest, "empty_report_reason", "reason"}},
+}
+
+var internalProblemType = problemType{http.StatusInternalServerError, codeInternalError, ""}
+
+func (h *Handler) getProblemType(err error) problemType {
+	for _, errProblem := range errorProblems {
+		if errors.Is(err, errProblem.err) {
+			return errProblem.problemType
+		}
+	}
+
+	return internalProblemType
+}
+
+// abortWithError responds with the problem type of err. Messages of errors are
+// only logged: clients get the code and its title in their language.
+func (h *Handler) abortWithError(c *gin.Context, err error) {
+	_ = c.Error(err)
+
+	problemType := h.getProblemType(err)
+
+	problem := h.newProblem(c, problemType.status, problemType.code)
+	if problemType.field != "" {
+		problem.InvalidParams = []*jsondto.InvalidParamDTO{{Name: problemType.field, Reason: problem.Title}}
+	}
+
+	h.abortWithProblem(c, problem)
+}
+
+// abortWithInvalidParam responds with a validation problem about a path or query parameter.
+func (h *Handler) abortWithInvalidParam(c *gin.Context, name string, err error) {
+	_ = c.Error(err)
+
+	problem := h.newProblem(c, http.StatusBadRequest, codeValidationFailed)
+	problem.InvalidParams = []*jsondto.InvalidParamDTO{{Name: name, Reason: h.translate(c, "validation.invalid_value")}}
+
+	h.abortWithProblem(c, problem)
+}
+
+// abortWithBindError responds to a request which could not be decoded with 400, to
+// a request with a body over the size limit with 413 and to a request with fields
+// violating the binding rules with 422, listing all the fields.
+func (h *Handler) abortWithBindError(c *gin.Context, err error) {
+	_ = c.Error(err)
+
+	problem := h.newProblem(c, http.StatusUnprocessableEntity, codeValidationFailed)
+
+	var (
+		validationErrs validator.ValidationErrors
+		typeErr        *json.UnmarshalTypeError
+		syntaxErr      *json.SyntaxError
+		maxBytesErr    *http.MaxBytesError
+	)
+	switch {
+	case errors.As(err, &maxBytesErr):
+		problem = h.newProblem(c, http.StatusRequestEntityTooLarge, codeBodyTooLarge)
+		problem.Detail = h.translate(c, "validation.body_too_large", maxBytesErr.Limit)
+	case errors.As(err, &validationErrs):
+		for _, fieldErr := range validationErrs {
+			problem.InvalidParams = append(problem.InvalidParams, &jsondto.InvalidParamDTO{
+				Name:   fieldErr.Field(),
+				Reason: h.getValidationReason(c, fieldErr),
+			})
+		}
+	case errors.As(err, &typeErr):
+		problem.InvalidParams = []*jsondto.InvalidParamDTO{{
+			Name:   typeErr.Field,
+			Reason: h.translate(c, "validation.type", typeErr.Type.String()),
+		}}
+	case errors.As(err, &syntaxErr):
+		problem.Status = http.StatusBadRequest
+		problem.Detail = h.translate(c, "validation.syntax", syntaxErr.Offset)
+	case errors.Is(err, io.EOF):
+		problem.Status = http.StatusBadRequest
+		problem.Detail = h.translate(c, "validation.empty_body")
+	default:
+		problem.Status = http.StatusBadRequest
+		problem.Detail = h.translate(c, "validation.malformed_body")
+	}
+
+	h.abortWithProblem(c, problem)
+}
+
+func (h *Handler) abortWithNotFound(c *gin.Context) {
+	h.abortWithProblem(c, h.newProblem(c, http.StatusNotFound, codeNotFound))
+}
+
+func (h *Handler) abortWithProblem(c *gin.Context, problem *jsondto.ProblemDetails) {
+	c.Header("Content-Type", problemContentType)
+	c.Header("Content-Language", h.getLanguage(c))
+	c.Header("Vary", "Accept-Language")
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+func (h *Handler) newProblem(c *gin.Context, status int, code string) *jsondto.ProblemDetails {
+	return &jsondto.ProblemDetails{
+		Type:      problemTypePrefix + code,
+		Title:     h.translate(c, "problem."+code),
+		Status:    status,
+		Instance:  c.Request.URL.Path,
+		Code:      code,
+		RequestID: logging.RequestIDFromContext(c.Request.Context()),
+	}
+}
+
+// getValidationReason prefers a message of the tag, e.g. "validation.phone", to the generic one.
+func (h *Handler) getValidationReason(c *gin.Context, fieldErr validator.FieldError) string {
+	if strings.HasPrefix(fieldErr.Tag(), "required") {
+		return h.translate(c, "validation.required")
+	}
+
+	key := "validation." + fieldErr.Tag()
+	if h.translator.HasMessage(key) {
+		// parameters of an alias belong to its tags and are not shown
+		if fieldErr.Param() != "" && fieldErr.Tag() == fieldErr.ActualTag() {
+			return h.translate(c, key, fieldErr.Param())
+		}
+		return h.translate(c, key)
+	}
+	if fieldErr.Param() != "" {
+		return h.translate(c, "validation.rule_with_param", fieldErr.Tag(), fieldErr.Param())
+	}
+
+	return h.translate(c, "validation.rule", fieldErr.Tag())
+}
+
+// getLanguage negotiates the language of messages by the Accept-Language header.
+func (h *Handler) getLanguage(c *gin.Context) string {
+	if lang := c.GetString(languageKey); lang != "" {
+		return lang
+	}
+
+	lang := h.translator.Negotiate(c.GetHeader("Accept-Language"))
+	c.Set(languageKey, lang)
+
+	return lang
+}
+
+func (h *Handler) translate(c *gin.Context, key string, args ...any) string {
+	return h.translator.Translate(h.getLanguage(c), key, args...)
+}
+
+var registerValidatorsOnce sync.Once
+
+// registerValidators adds the custom validators and makes validation errors refer
+// to fields by their names in JSON or in the query.
+func registerValidators() {
+	validate, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	if err := validation.Register(validate); err != nil {
+		panic(err)
+	}
+	// the frontend sends "NaN" and "null" for numbers which are not set
+	validate.RegisterAlias(queryNumberTag, "max=9,number|oneof=NaN null")
+
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name := strings.SplitN(field.Tag.Get(tag), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+
+		return field.Name
+	})
+}
@@ -4,26 +4,444 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/nikitalystsev/BookSmart-services/intf"
+	"github.com/nikitalystsev/BookSmart-services/intfRepo"
 	"github.com/nikitalystsev/BookSmart-services/pkg/auth"
 	"github.com/nikitalystsev/BookSmart-services/pkg/hash"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/apikey"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/audit"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/cache"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/cardprint"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/config"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/dataloader"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/health"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/i18n"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/idempotency"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/libcard"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/logging"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/metrics"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/moderation"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/oidc"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/ratelimit"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/ratingstats"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/totp"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/tracing"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/versioning"
 	_ "github.com/nikitalystsev/BookSmart/docs_swagger"
+	"github.com/sirupsen/logrus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// files of the stores kept in config.StorageConfig.DataDir
+const (
+	reviewModerationFile = "review_moderation.json"
+	ratingHistoryFile    = "rating_history.json"
+	ratingScoreIndexFile = "rating_scores.json"
+	libCardRegistryFile  = "lib_card_states.json"
+	apiKeyRegistryFile   = "api_keys.json"
+	twoFactorFile        = "two_factor.json"
+)
+
 type Handler struct {
 	bookService        intf.IBookService
 	libCardService     intf.ILibCardService
 	readerService      intf.IReaderService
 	reservationService intf.IReservationService
 	ratingService      intf.IRatingService
+	serviceFactories   ServiceFactories
 	tokenManager       auth.ITokenManager
 	hasher             hash.IPasswordHasher
 	accessTokenTTL     time.Duration
 	refreshTokenTTL    time.Duration
+	reviewModerator    moderation.IReviewModerator
+	wordFilter         moderation.IWordFilter
+	ratingHistory      ratingstats.IRatingHistory
+	ratingScoreIndex   ratingstats.IScoreIndex
+	// ratingScoreBackfillMu lets one request list the catalog to complete the score index
+	ratingScoreBackfillMu sync.Mutex
+	statsCalculator       ratingstats.IStatisticsCalculator
+	loaderParallelism     int
+	responseCache         cache.ICache
+	responseCacheTTL      time.Duration
+	libCardRegistry       libcard.IStatusRegistry
+	libCardRepo           intfRepo.ILibCardRepo
+	cardRenderer          cardprint.ICardRenderer
+	finePerDay            float64
+	logger                *logrus.Entry
+	metrics               metrics.IMetrics
+	isMetricsPublic       bool
+	tracer                trace.Tracer
+	propagator            propagation.TextMapPropagator
+	readinessCheckers     []health.IChecker
+	readinessTimeout      time.Duration
+	rateLimitStore        ratelimit.IStore
+	rateLimitPolicies     map[string]ratelimit.Policy
+	translator            i18n.ITranslator
+	idempotencyStore      idempotency.IStore
+	idempotencyWindow     time.Duration
+	resourceLocks         versioning.IKeyedMutex
+	pageSize              uint
+	corsConfig            config.CORSConfig
+	isDraining            atomic.Bool
+	hstsMaxAge            time.Duration
+	isAdminCertRequired   bool
+	maxBodySize           int64
+	requestTimeout        time.Duration
+	routeTimeouts         map[string]time.Duration
+	trustedProxies        []string
+	apiKeyRegistry        apikey.IKeyRegistry
+	oidcProvider          oidc.IProvider
+	oidcGroupRoles        []oidc.GroupRole
+	oidcLoginTTL          time.Duration
+	oidcCookiePath        string
+	isOIDCCookieSecure    bool
+	oidcLogins            oidc.ILoginStore
+	staffSessions         oidc.ISessionStore
+	twoFactor             totp.IAuthenticator
+	twoFactorChallenges   totp.IChallengeStore
+	totpIssuer            string
+	challengeTTL          time.Duration
+	auditLog              audit.ILog
+}
+
+// HandlerOption configures optional Handler dependencies.
+type HandlerOption func(h *Handler)
+
+// WithReviewModerator sets storage of review visibility states and reports.
+func WithReviewModerator(reviewModerator moderation.IReviewModerator) HandlerOption {
+	return func(h *Handler) {
+		h.reviewModerator = reviewModerator
+	}
+}
+
+// WithRatingHistory sets storage of rating submission times used for the rating trend.
+func WithRatingHistory(ratingHistory ratingstats.IRatingHistory) HandlerOption {
+	return func(h *Handler) {
+		h.ratingHistory = ratingHistory
+	}
+}
+
+// WithRatingScoreIndex sets storage of the rating aggregates ranking the catalog.
+func WithRatingScoreIndex(ratingScoreIndex ratingstats.IScoreIndex) HandlerOption {
+	return func(h *Handler) {
+		h.ratingScoreIndex = ratingScoreIndex
+	}
+}
+
+// WithRatingPrior sets the prior mean and weight of the Bayesian rating score.
+func WithRatingPrior(priorMean, priorWeight float64) HandlerOption {
+	return func(h *Handler) {
+		h.statsCalculator = ratingstats.NewStatisticsCalculator(priorMean, priorWeight)
+	}
+}
+
+// WithLoaderParallelism sets the max number of concurrent service calls made while rendering lists.
+func WithLoaderParallelism(loaderParallelism int) HandlerOption {
+	return func(h *Handler) {
+		h.loaderParallelism = loaderParallelism
+	}
+}
+
+// WithResponseCache sets the cache of public catalog responses and the TTL of its entries.
+func WithResponseCache(responseCache cache.ICache, ttl time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.responseCache = responseCache
+		h.responseCacheTTL = ttl
+	}
+}
+
+// WithLibCardRegistry sets storage of lib card blocks and replacements.
+func WithLibCardRegistry(libCardRegistry libcard.IStatusRegistry) HandlerOption {
+	return func(h *Handler) {
+		h.libCardRegistry = libCardRegistry
+	}
+}
+
+// WithLibCardRepo sets the repository finding lib cards by number, e.g. for scanned cards.
+func WithLibCardRepo(libCardRepo intfRepo.ILibCardRepo) HandlerOption {
+	return func(h *Handler) {
+		h.libCardRepo = libCardRepo
+	}
+}
+
+// WithFinePerDay sets the fine charged for each day a reserved book is overdue.
+func WithFinePerDay(finePerDay float64) HandlerOption {
+	return func(h *Handler) {
+		h.finePerDay = finePerDay
+	}
+}
+
+// WithLogger sets the logger of the handlers. A hook adding the request ID to entries
+// logged with a request context is registered once on the underlying logrus.Logger,
+// the services layer gets the ID through WithServiceFactories.
+func WithLogger(logger *logrus.Entry) HandlerOption {
+	return func(h *Handler) {
+		logging.AddRequestIDHook(logger.Logger)
+		h.logger = logger
+	}
+}
+
+// ServiceFactories build the services with the given logger, see WithServiceFactories.
+type ServiceFactories struct {
+	Book        logging.NewServiceFunc[intf.IBookService]
+	LibCard     logging.NewServiceFunc[intf.ILibCardService]
+	Reader      logging.NewServiceFunc[intf.IReaderService]
+	Reservation logging.NewServiceFunc[intf.IReservationService]
+	Rating      logging.NewServiceFunc[intf.IRatingService]
+}
+
+// WithServiceFactories makes the handlers build a service for every call with the logger
+// of the handlers bound to the request ID, so the services log the ID of the request.
+// A set factory replaces the service passed to NewHandler.
+func WithServiceFactories(factories ServiceFactories) HandlerOption {
+	return func(h *Handler) {
+		h.serviceFactories = factories
+	}
+}
+
+// WithMetrics sets the collector of Prometheus metrics.
+func WithMetrics(metrics metrics.IMetrics) HandlerOption {
+	return func(h *Handler) {
+		h.metrics = metrics
+	}
+}
+
+// WithSeparateMetricsPort removes /metrics from the public router.
+// The endpoint is then served by MetricsHandler on a separate port, see server.WithMetricsHandler.
+func WithSeparateMetricsPort() HandlerOption {
+	return func(h *Handler) {
+		h.isMetricsPublic = false
+	}
+}
+
+// WithTracerProvider sets the provider of spans for requests and service calls.
+// By default the global provider is used, which is a no-op until tracing.Init is called.
+func WithTracerProvider(tracerProvider trace.TracerProvider) HandlerOption {
+	return func(h *Handler) {
+		h.tracer = tracerProvider.Tracer(tracing.InstrumentationName)
+	}
+}
+
+// WithReadinessCheckers adds checks of dependencies to the default ones of /readyz.
+func WithReadinessCheckers(checkers ...health.IChecker) HandlerOption {
+	return func(h *Handler) {
+		h.readinessCheckers = append(h.readinessCheckers, checkers...)
+	}
+}
+
+// WithReadinessTimeout limits the duration of every readiness check.
+func WithReadinessTimeout(timeout time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.readinessTimeout = timeout
+	}
+}
+
+// WithRateLimitStore sets storage of token buckets, which may be shared by several instances.
+func WithRateLimitStore(store ratelimit.IStore) HandlerOption {
+	return func(h *Handler) {
+		h.rateLimitStore = store
+	}
+}
+
+// WithRateLimitPolicy overrides the policy of a route group, e.g. RateLimitGroupAuth.
+// A non-positive limit disables limiting of the group.
+func WithRateLimitPolicy(group string, limit int, window time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.rateLimitPolicies[group] = ratelimit.Policy{Name: group, Limit: limit, Window: window}
+	}
+}
+
+// WithTranslator sets message catalogs, e.g. ones extended by files of a locales directory.
+func WithTranslator(translator i18n.ITranslator) HandlerOption {
+	return func(h *Handler) {
+		h.translator = translator
+	}
+}
+
+// WithIdempotencyStore sets the store of responses to requests with Idempotency-Key
+// and how long they are replayed to retries.
+func WithIdempotencyStore(store idempotency.IStore, window time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.idempotencyStore = store
+		h.idempotencyWindow = window
+	}
+}
+
+// WithConfig applies the token TTLs, page size, request limits, CORS and TLS settings of cfg,
+// overriding the TTLs passed to NewHandler.
+func WithConfig(cfg *config.Config) HandlerOption {
+	return func(h *Handler) {
+		h.accessTokenTTL = cfg.Auth.AccessTokenTTL
+		h.refreshTokenTTL = cfg.Auth.RefreshTokenTTL
+		h.totpIssuer = cfg.Auth.TOTPIssuer
+		h.challengeTTL = cfg.Auth.ChallengeTTL
+		h.pageSize = cfg.Catalog.PageSize
+		h.hstsMaxAge = cfg.TLS.HSTSMaxAge
+		h.isAdminCertRequired = cfg.TLS.IsAdminClientCertRequired()
+		h.maxBodySize = cfg.Server.MaxBodySize
+		h.requestTimeout = cfg.Server.RequestTimeout
+		for route, timeout := range cfg.Server.RouteTimeouts {
+			h.routeTimeouts[route] = timeout
+		}
+		h.trustedProxies = cfg.Server.TrustedProxies
+		// the catalogs are checked by cfg.Validate, a failure keeps the built-in ones
+		if translator, err := cfg.I18n.NewTranslator(); err != nil {
+			h.logger.WithError(err).Error("message catalogs loading failed")
+		} else {
+			h.translator = translator
+		}
+		// the file is checked by cfg.Validate, a failure keeps the previous words
+		if blockedWords, err := cfg.Moderation.LoadBlockedWords(); err != nil {
+			h.logger.WithError(err).Error("blocked words loading failed")
+		} else if blockedWords != nil {
+			WithBlockedWords(blockedWords)(h)
+		}
+		WithCORS(cfg.CORS, cfg.Environment)(h)
+		h.reviewModerator = moderation.NewReviewModerator(cfg.Storage.GetPath(reviewModerationFile))
+		h.ratingHistory = ratingstats.NewRatingHistory(cfg.Storage.GetPath(ratingHistoryFile))
+		h.ratingScoreIndex = ratingstats.NewScoreIndex(cfg.Storage.GetPath(ratingScoreIndexFile))
+		h.libCardRegistry = libcard.NewStatusRegistry(cfg.Storage.GetPath(libCardRegistryFile), h.isLibCardNumTaken)
+		h.apiKeyRegistry = apikey.NewKeyRegistry(cfg.Storage.GetPath(apiKeyRegistryFile))
+		h.twoFactor = totp.NewAuthenticator(cfg.Storage.GetPath(twoFactorFile))
+		// a failure keeps the entries in memory, so that requests are still audited until a restart
+		if auditLog, err := audit.NewFileLog(cfg.GetAuditFile()); err != nil {
+			h.logger.WithError(err).WithField("file", cfg.GetAuditFile()).Error("audit log opening failed")
+		} else {
+			h.auditLog = auditLog
+		}
+		if cfg.OIDC.IsEnabled() {
+			WithOIDC(cfg.OIDC)(h)
+		}
+		if cfg.Metrics.IsSeparate() {
+			WithSeparateMetricsPort()(h)
+		}
+	}
+}
+
+// WithPageSize sets the number of books on a catalog page.
+func WithPageSize(pageSize uint) HandlerOption {
+	return func(h *Handler) {
+		h.pageSize = pageSize
+	}
+}
+
+// WithCORS sets the origins allowed to make cross-origin requests in the environment
+// and how long browsers cache preflight responses.
+func WithCORS(corsConfig config.CORSConfig, environment string) HandlerOption {
+	return func(h *Handler) {
+		corsConfig.AllowOrigins = corsConfig.GetAllowOrigins(environment)
+		corsConfig.EnvironmentOrigins = nil
+		h.corsConfig = corsConfig
+	}
+}
+
+// WithHSTS sets max-age of the Strict-Transport-Security header of HTTPS responses,
+// zero disables the header.
+func WithHSTS(maxAge time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.hstsMaxAge = maxAge
+	}
+}
+
+// WithAdminClientCert requires the admin routes to be called with a client certificate
+// verified by the TLS server, see config.TLSConfig.ClientCAFile.
+func WithAdminClientCert() HandlerOption {
+	return func(h *Handler) {
+		h.isAdminCertRequired = true
+	}
+}
+
+// WithTrustedProxies sets IPs or CIDRs of reverse proxies whose X-Forwarded-For is used
+// as the client IP. By default no proxy is trusted and the address of the connection is used.
+func WithTrustedProxies(trustedProxies []string) HandlerOption {
+	return func(h *Handler) {
+		h.trustedProxies = trustedProxies
+	}
+}
+
+// WithMaxBodySize limits the size of request bodies in bytes.
+func WithMaxBodySize(maxBodySize int64) HandlerOption {
+	return func(h *Handler) {
+		h.maxBodySize = maxBodySize
+	}
+}
+
+// WithRequestTimeout sets the deadline of handling a request, zero disables it.
+func WithRequestTimeout(timeout time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.requestTimeout = timeout
+	}
+}
+
+// WithRouteTimeout overrides the request timeout of a route, e.g. of
+// http.MethodGet and "/api/v1/readers/:id/lib_cards/card.pdf".
+func WithRouteTimeout(method, route string, timeout time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.routeTimeouts[method+" "+route] = timeout
+	}
+}
+
+// WithAPIKeyRegistry sets storage of API keys of integrations, without it the keys are not accepted.
+func WithAPIKeyRegistry(apiKeyRegistry apikey.IKeyRegistry) HandlerOption {
+	return func(h *Handler) {
+		h.apiKeyRegistry = apiKeyRegistry
+	}
+}
+
+// WithOIDC enables sign-in of staff through the OpenID Connect provider of oidcConfig.
+func WithOIDC(oidcConfig config.OIDCConfig) HandlerOption {
+	return func(h *Handler) {
+		h.oidcProvider = oidc.NewProvider(oidcConfig.GetProviderConfig())
+		h.oidcGroupRoles = oidcConfig.GroupRoles
+		h.oidcLoginTTL = oidcConfig.LoginTTL
+		// the state cookie is sent back only to the callback route
+		if redirectURL, err := url.Parse(oidcConfig.RedirectURL); err == nil {
+			h.oidcCookiePath = redirectURL.Path
+			h.isOIDCCookieSecure = redirectURL.Scheme == "https"
+		}
+	}
+}
+
+// WithOIDCStores sets storage of logins started at the provider and of staff sessions,
+// which must be shared by instances behind a load balancer.
+func WithOIDCStores(logins oidc.ILoginStore, staffSessions oidc.ISessionStore) HandlerOption {
+	return func(h *Handler) {
+		h.oidcLogins = logins
+		h.staffSessions = staffSessions
+	}
+}
+
+// WithTwoFactorStores sets storage of TOTP secrets and recovery codes and of sign-ins
+// waiting for the second factor. Without the authenticator staff can not sign in.
+func WithTwoFactorStores(authenticator totp.IAuthenticator, challenges totp.IChallengeStore) HandlerOption {
+	return func(h *Handler) {
+		h.twoFactor = authenticator
+		h.twoFactorChallenges = challenges
+	}
+}
+
+// WithAuditLog sets the log of state-changing requests, e.g. audit.NewFileLog
+// to keep it across restarts.
+func WithAuditLog(auditLog audit.ILog) HandlerOption {
+	return func(h *Handler) {
+		h.auditLog = auditLog
+	}
+}
+
+// WithBlockedWords sets words which send a submitted review to the moderation queue.
+func WithBlockedWords(blockedWords []string) HandlerOption {
+	return func(h *Handler) {
+		h.wordFilter = moderation.NewWordFilter(blockedWords)
+	}
 }
 
 func NewHandler(
@@ -35,61 +453,193 @@ func NewHandler(
 	tokenManager auth.ITokenManager,
 	accessTokenTTL time.Duration,
 	refreshTokenTTL time.Duration,
+	opts ...HandlerOption,
 ) *Handler {
-	return &Handler{
-		bookService:        bookService,
-		libCardService:     libCardService,
-		readerService:      readerService,
-		reservationService: reservationService,
-		ratingService:      ratingService,
-		tokenManager:       tokenManager,
-		accessTokenTTL:     accessTokenTTL,
-		refreshTokenTTL:    refreshTokenTTL,
+	h := &Handler{
+		bookService:         bookService,
+		libCardService:      libCardService,
+		readerService:       readerService,
+		reservationService:  reservationService,
+		ratingService:       ratingService,
+		tokenManager:        tokenManager,
+		accessTokenTTL:      accessTokenTTL,
+		refreshTokenTTL:     refreshTokenTTL,
+		reviewModerator:     moderation.NewReviewModerator(""),
+		wordFilter:          moderation.NewWordFilter(nil),
+		ratingHistory:       ratingstats.NewRatingHistory(""),
+		ratingScoreIndex:    ratingstats.NewScoreIndex(""),
+		statsCalculator:     ratingstats.NewStatisticsCalculator(ratingstats.DefaultPriorMean, ratingstats.DefaultPriorWeight),
+		loaderParallelism:   dataloader.DefaultMaxParallelism,
+		responseCache:       cache.NewLRUCache(defaultCacheCapacity),
+		responseCacheTTL:    defaultCacheTTL,
+		cardRenderer:        cardprint.NewCardRenderer(),
+		finePerDay:          defaultFinePerDay,
+		logger:              newDefaultLogger(),
+		metrics:             metrics.NewMetrics(),
+		isMetricsPublic:     true,
+		tracer:              otel.Tracer(tracing.InstrumentationName),
+		propagator:          propagation.TraceContext{},
+		readinessTimeout:    defaultReadinessTimeout,
+		rateLimitStore:      ratelimit.NewMemoryStore(),
+		rateLimitPolicies:   getDefaultRateLimitPolicies(),
+		translator:          i18n.NewDefaultTranslator(),
+		idempotencyStore:    idempotency.NewMemoryStore(),
+		idempotencyWindow:   defaultIdempotencyWindow,
+		resourceLocks:       versioning.NewKeyedMutex(),
+		pageSize:            config.DefaultPageSize,
+		corsConfig:          config.Default().CORS,
+		hstsMaxAge:          config.DefaultHSTSMaxAge,
+		maxBodySize:         config.DefaultMaxBodySize,
+		requestTimeout:      config.DefaultRequestTimeout,
+		routeTimeouts:       make(map[string]time.Duration),
+		oidcLoginTTL:        config.DefaultOIDCLoginTTL,
+		oidcLogins:          oidc.NewMemoryLoginStore(),
+		staffSessions:       oidc.NewMemorySessionStore(),
+		twoFactorChallenges: totp.NewMemoryChallengeStore(),
+		totpIssuer:          config.DefaultTOTPIssuer,
+		challengeTTL:        config.DefaultChallengeTTL,
+		auditLog:            audit.NewMemoryLog(),
+	}
+	h.readinessCheckers = h.getDefaultReadinessCheckers()
+	h.libCardRegistry = libcard.NewStatusRegistry("", h.isLibCardNumTaken)
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.useServiceFactories()
+	h.bookService = tracing.NewBookService(h.bookService, h.tracer)
+	h.libCardService = tracing.NewLibCardService(h.libCardService, h.tracer)
+	h.readerService = tracing.NewReaderService(h.readerService, h.tracer)
+	h.reservationService = tracing.NewReservationService(h.reservationService, h.tracer)
+	h.ratingService = tracing.NewRatingService(h.ratingService, h.tracer)
+
+	return h
+}
+
+func (h *Handler) useServiceFactories() {
+	if h.serviceFactories.Book != nil {
+		h.bookService = logging.NewBookService(h.serviceFactories.Book, h.logger)
+	}
+	if h.serviceFactories.LibCard != nil {
+		h.libCardService = logging.NewLibCardService(h.serviceFactories.LibCard, h.logger)
+	}
+	if h.serviceFactories.Reader != nil {
+		h.readerService = logging.NewReaderService(h.serviceFactories.Reader, h.logger)
+	}
+	if h.serviceFactories.Reservation != nil {
+		h.reservationService = logging.NewReservationService(h.serviceFactories.Reservation, h.logger)
+	}
+	if h.serviceFactories.Rating != nil {
+		h.ratingService = logging.NewRatingService(h.serviceFactories.Rating, h.logger)
 	}
 }
 
 func (h *Handler) InitRoutes() *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
-	gin.DefaultWriter = io.Discard
 
-	router := gin.Default()
+	router := gin.New()
+	registerValidatorsOnce.Do(registerValidators)
+
+	// gin trusts every proxy by default, so that X-Forwarded-For would spoof the client IP
+	if err := router.SetTrustedProxies(h.trustedProxies); err != nil {
+		h.logger.WithError(err).Error("invalid trusted proxies, no proxy is trusted")
+		_ = router.SetTrustedProxies(nil)
+	}
+
+	router.Use(h.requestID, h.traceRequest, h.logRequest, h.collectMetrics, gin.CustomRecoveryWithWriter(io.Discard, h.recoverPanic))
+	router.Use(h.setSecurityHeaders, h.corsSettings(), h.limitBodySize, h.limitDuration)
 
-	router.Use(h.corsSettings())
+	router.GET(healthzRoute, h.healthz)
+	router.GET(readyzRoute, h.readyz)
+	router.GET(versionRoute, h.version)
+
+	if h.isMetricsPublic {
+		router.GET(metricsRoute, gin.WrapH(h.metrics.Handler()))
+	}
 
 	api := router.Group("/api")
 	{
-		v1 := api.Group("/v1")
+		v1 := api.Group("/v1", h.requireJSONBody, h.apiKeyIdentity, h.recordAudit)
 		{
-			v1.POST("/auth/sign-up", h.signUp)
-			v1.POST("/auth/sign-in", h.signIn)
-			v1.POST("/auth/refresh", h.refresh)
+			v1.POST("/auth/sign-up", h.limitRate(RateLimitGroupAuth), h.idempotent, h.signUp)
+			v1.POST("/auth/sign-in", h.limitRate(RateLimitGroupAuth), h.signIn)
+			if h.twoFactor != nil {
+				v1.POST("/auth/sign-in/2fa/enroll", h.limitRate(RateLimitGroupAuth), h.signInEnrollTwoFactor)
+				v1.POST("/auth/sign-in/2fa/verify", h.limitRate(RateLimitGroupAuth), h.signInVerifyTwoFactor)
+			}
+			v1.POST("/auth/refresh", h.limitRate(RateLimitGroupAuth), h.refresh)
+
+			if h.oidcProvider != nil {
+				v1.GET("/auth/oidc/login", h.limitRate(RateLimitGroupAuth), h.oidcLogin)
+				v1.GET("/auth/oidc/callback", h.limitRate(RateLimitGroupAuth), h.oidcCallback)
+			}
 
-			v1.GET("/books", h.getPageBooks)
-			v1.GET("/books/:id", h.getBookByID)
+			v1.GET("/books", h.limitRate(RateLimitGroupCatalog), h.cacheResponse, h.getPageBooks)
+			v1.GET("/books/:id", h.limitRate(RateLimitGroupCatalog), h.cacheResponse, h.getBookByID)
 
-			v1.GET("/books/:id/ratings/avg", h.getAvgRatingByBookID)
-			v1.GET("/books/:id/ratings", h.getRatingsByBookID)
+			v1.GET("/books/:id/ratings/avg", h.limitRate(RateLimitGroupCatalog), h.cacheResponse, h.getAvgRatingByBookID)
+			v1.GET("/books/:id/ratings/stats", h.limitRate(RateLimitGroupCatalog), h.cacheResponse, h.getRatingStatsByBookID)
+			v1.GET("/books/:id/ratings", h.limitRate(RateLimitGroupCatalog), h.cacheResponse, h.getRatingsByBookID)
 
-			registered := v1.Group("/", h.readerIdentity)
+			registered := v1.Group("/", h.readerIdentity, h.limitRate(RateLimitGroupDefault))
 			{
-				registered.POST("/books/:id/ratings", h.addNewRating)
+				if h.twoFactor != nil {
+					registered.GET("/auth/2fa", h.getTwoFactorStatus)
+					registered.POST("/auth/2fa/enroll", h.enrollTwoFactor)
+					registered.POST("/auth/2fa/confirm", h.confirmTwoFactor)
+					registered.POST("/auth/2fa/disable", h.disableTwoFactor)
+					registered.POST("/auth/2fa/recovery_codes", h.regenerateRecoveryCodes)
+				}
+
+				registered.POST("/books/:id/ratings", h.idempotent, h.addNewRating)
+				registered.POST("/books/:id/ratings/:rating_id/reports", h.reportRating)
 
 				registered.GET("/readers/:id", h.getReaderByID)
 				registered.POST("/readers/:id/favorite_books", h.addToFavorites)
 
 				registered.GET("/readers/:id/lib_cards", h.getLibCardByReaderID)
 				registered.PUT("/readers/:id/lib_cards", h.updateLibCard)
-				registered.POST("/readers/:id/lib_cards", h.createLibCard)
+				registered.POST("/readers/:id/lib_cards", h.idempotent, h.createLibCard)
+				registered.GET("/readers/:id/lib_cards/card.pdf", h.getLibCardPDF)
+				registered.GET("/readers/:id/lib_cards/card.png", h.getLibCardPNG)
 
-				registered.POST("/readers/:id/reservations", h.reserveBook)
+				registered.POST("/readers/:id/reservations", h.idempotent, h.reserveBook)
 				registered.GET("/readers/:id/reservations", h.getReservationsByReaderID)
 				registered.GET("/readers/:id/reservations/:reservation_id", h.getReservationByID)
 				registered.PATCH("/readers/:id/reservations/:reservation_id", h.updateReservation)
+
+				admin := registered.Group("/admin", h.getAdminMiddlewares()...)
+				{
+					admin.GET("/reviews/moderation", h.getModerationQueue)
+					admin.PATCH("/reviews/:rating_id", h.moderateReview)
+
+					admin.POST("/readers/:id/lib_cards/block", h.blockLibCard)
+					admin.POST("/readers/:id/lib_cards/unblock", h.unblockLibCard)
+					admin.POST("/readers/:id/lib_cards/replace", h.replaceLibCard)
+
+					admin.GET("/lookup/lib_cards/:lib_card_num", h.lookupByLibCardNum)
+					admin.GET("/lookup/book_copies/:barcode", h.lookupByBookCopyBarcode)
+
+					// the secret of a new key must not be kept, so issuing is not idempotent
+					if h.apiKeyRegistry != nil {
+						admin.POST("/api_keys", h.issueAPIKey)
+						admin.GET("/api_keys", h.getAPIKeys)
+						admin.GET("/api_keys/:key_id", h.getAPIKeyByID)
+						admin.POST("/api_keys/:key_id/rotate", h.rotateAPIKey)
+						admin.DELETE("/api_keys/:key_id", h.revokeAPIKey)
+					}
+
+					admin.GET("/audit", h.getAuditLog)
+					admin.GET("/audit/export", h.exportAuditLog)
+				}
 			}
 		}
 	}
 
-	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	router.GET("/swagger/*any", h.setSwaggerSecurityHeaders, ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	router.NoRoute(h.abortWithNotFound)
 
 	return router
 }
@@ -103,16 +653,34 @@ func (h *Handler) corsSettings() gin.HandlerFunc {
 			http.MethodPatch,
 			http.MethodDelete,
 		},
-		AllowOrigins: []string{
-			"*",
-		},
-		AllowCredentials: true,
+		AllowOrigins:     h.corsConfig.AllowOrigins,
+		AllowWildcard:    true,
+		AllowCredentials: h.corsConfig.AllowCredentials,
+		MaxAge:           h.corsConfig.MaxAge,
 		AllowHeaders: []string{
 			"Authorization",
 			"Content-Type",
+			"If-Match",
+			"If-None-Match",
+			apiKeyHeader,
+			idempotencyKeyHeader,
+			requestIDHeader,
+			traceparentHeader,
+			tracestateHeader,
 		},
 		ExposeHeaders: []string{
 			"Content-Type",
+			"Content-Language",
+			"ETag",
+			"Link",
+			idempotentReplayedHeader,
+			requestIDHeader,
+			traceparentHeader,
+			rateLimitLimitHeader,
+			rateLimitRemainingHeader,
+			rateLimitResetHeader,
+			rateLimitPolicyHeader,
+			retryAfterHeader,
 		},
 	})
 }
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/dto"
+	"github.com/nikitalystsev/BookSmart-services/errs"
+	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/buildinfo"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/health"
+	"net/http"
+	"time"
+)
+
+const (
+	healthzRoute = "/healthz"
+	readyzRoute  = "/readyz"
+	versionRoute = "/version"
+
+	defaultReadinessTimeout = 2 * time.Second
+	readinessProbeTokenTTL  = time.Minute
+)
+
+// isProbeRoute reports whether the route is polled by the orchestrator,
+// such requests are not logged and traced while they succeed.
+func (h *Handler) isProbeRoute(route string) bool {
+	return route == healthzRoute || route == readyzRoute || route == versionRoute
+}
+
+// @Summary Метод проверки того, что процесс жив
+// @Tags health
+// @ID healthz
+// @Produce  json
+// @Success 200 {object} dto.HealthOutputDTO "Процесс жив"
+// @Router /healthz [get]
+func (h *Handler) healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, jsondto.HealthOutputDTO{Status: health.StatusUp})
+}
+
+// StartDraining makes /readyz fail, so that the instance stops receiving new requests
+// while the server drains in-flight ones, see server.IServer.RegisterOnShutdown.
+func (h *Handler) StartDraining() {
+	h.isDraining.Store(true)
+}
+
+// @Summary Метод проверки готовности сервиса принимать запросы
+// @Tags health
+// @ID readyz
+// @Produce  json
+// @Success 200 {object} dto.ReadinessOutputDTO "Все зависимости доступны"
+// @Failure 503 {object} dto.ReadinessOutputDTO "Одна из зависимостей недоступна или сервис останавливается"
+// @Router /readyz [get]
+func (h *Handler) readyz(c *gin.Context) {
+	if h.isDraining.Load() {
+		c.JSON(http.StatusServiceUnavailable, jsondto.ReadinessOutputDTO{Status: health.StatusDown, Checks: []*jsondto.DependencyCheckDTO{}})
+		return
+	}
+
+	results, isReady := health.RunChecks(c.Request.Context(), h.readinessCheckers, h.readinessTimeout)
+
+	response := jsondto.ReadinessOutputDTO{Status: health.StatusUp, Checks: make([]*jsondto.DependencyCheckDTO, 0, len(results))}
+	for _, result := range results {
+		response.Checks = append(response.Checks, &jsondto.DependencyCheckDTO{
+			Name:       result.Name,
+			Status:     result.Status,
+			Error:      result.Error,
+			DurationMs: float64(result.Duration.Microseconds()) / 1000,
+		})
+	}
+
+	if !isReady {
+		response.Status = health.StatusDown
+		c.JSON(http.StatusServiceUnavailable, response)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Метод получения сведений о сборке
+// @Tags health
+// @ID version
+// @Produce  json
+// @Success 200 {object} dto.VersionOutputDTO "Сведения о сборке"
+// @Router /version [get]
+func (h *Handler) version(c *gin.Context) {
+	info := buildinfo.Get()
+
+	c.JSON(http.StatusOK, jsondto.VersionOutputDTO{
+		Version:   info.Version,
+		Commit:    info.Commit,
+		BuildTime: info.BuildTime,
+		GoVersion: info.GoVersion,
+		Modified:  info.Modified,
+	})
+}
+
+func (h *Handler) getDefaultReadinessCheckers() []health.IChecker {
+	return []health.IChecker{
+		health.NewChecker("token_manager", h.checkTokenManager),
+		health.NewChecker("book_service", h.checkBookService),
+	}
+}
+
+// checkTokenManager issues a short-lived token and parses it back.
+func (h *Handler) checkTokenManager(ctx context.Context) error {
+	token, err := h.tokenManager.NewJWT(uuid.New(), readerRole, readinessProbeTokenTTL)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = h.tokenManager.Parse(token)
+
+	return err
+}
+
+// checkBookService reads the first book of the catalog, which requires the storage behind services.
+// An empty catalog is not a failure.
+func (h *Handler) checkBookService(ctx context.Context) error {
+	_, err := h.bookService.GetByParams(ctx, &dto.BookParamsDTO{Limit: 1})
+	if err != nil && !errors.Is(err, errs.ErrBookDoesNotExists) {
+		return err
+	}
+
+	return nil
+}
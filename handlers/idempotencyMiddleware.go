@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/idempotency"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	defaultIdempotencyWindow = 24 * time.Hour
+)
+
+// replayedHeaders are stored with the response, the other headers belong to the retry.
+var replayedHeaders = []string{"Content-Type", "Content-Language", "Location", "ETag"}
+
+// recordingWriter passes the response to the client and keeps a copy of the body.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotent replays the stored response to a request retried with the same
+// Idempotency-Key. Keys are scoped by client and route, and a key reused with
+// another request is rejected. Requests without the header are not affected.
+func (h *Handler) idempotent(c *gin.Context) {
+	key := c.GetHeader(idempotencyKeyHeader)
+	if key == "" {
+		return
+	}
+	if !h.isValidRequestID(key) {
+		h.abortWithError(c, weberrs.ErrInvalidIdempotencyKey)
+		return
+	}
+
+	requestHash, err := h.hashRequest(c)
+	if err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	storeKey := h.getClientKey(c) + ":" + c.Request.Method + " " + c.FullPath() + ":" + key
+
+	record, err := h.idempotencyStore.Begin(ctx, storeKey, requestHash, h.idempotencyWindow)
+	if err != nil {
+		// the store is unavailable, the request is handled as if it had no key
+		h.logger.WithContext(ctx).WithError(err).Warn("idempotency store failed")
+		return
+	}
+	if record != nil {
+		h.replayIdempotentResponse(c, record, requestHash)
+		return
+	}
+
+	isCompleted := false
+	defer func() {
+		if isCompleted {
+			return
+		}
+		if err := h.idempotencyStore.Release(ctx, storeKey); err != nil {
+			h.logger.WithContext(ctx).WithError(err).Warn("idempotency key release failed")
+		}
+	}()
+
+	writer := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+
+	c.Next()
+
+	c.Writer = writer.ResponseWriter
+
+	// server errors are not stored, so that the client can retry
+	if status := c.Writer.Status(); status < http.StatusInternalServerError {
+		header := make(http.Header)
+		for _, name := range replayedHeaders {
+			if value := c.Writer.Header().Get(name); value != "" {
+				header.Set(name, value)
+			}
+		}
+
+		err = h.idempotencyStore.Complete(ctx, storeKey, &idempotency.Record{
+			RequestHash: requestHash,
+			Status:      status,
+			Header:      header,
+			Body:        writer.body.Bytes(),
+		})
+		if err != nil {
+			h.logger.WithContext(ctx).WithError(err).Warn("idempotency store failed")
+			return
+		}
+		isCompleted = true
+	}
+}
+
+func (h *Handler) replayIdempotentResponse(c *gin.Context, record *idempotency.Record, requestHash string) {
+	if record.RequestHash != requestHash {
+		h.abortWithError(c, weberrs.ErrIdempotencyKeyReused)
+		return
+	}
+	if !record.IsCompleted() {
+		h.abortWithError(c, weberrs.ErrIdempotentRequestInProgress)
+		return
+	}
+
+	for name, values := range record.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header(idempotentReplayedHeader, "true")
+
+	c.Status(record.Status)
+	c.Writer.WriteHeaderNow()
+	_, _ = c.Writer.Write(record.Body)
+	c.Abort()
+}
+
+// hashRequest identifies the request by its target and body, and restores the body for the handler.
+func (h *Handler) hashRequest(c *gin.Context) (string, error) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(c.Request.Body); err != nil {
+			return "", err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -1,14 +1,31 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/nikitalystsev/BookSmart-services/core/models"
 	"github.com/nikitalystsev/BookSmart-services/errs"
 	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
 	jsonmodels "github.com/nikitalystsev/BookSmart-web-api/core/models"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/cardprint"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/libcard"
+	"io"
 	"net/http"
+	"time"
+)
+
+const (
+	libCardStatusActive  = "active"
+	libCardStatusExpired = "expired"
+	libCardStatusBlocked = "blocked"
+
+	contentTypePDF = "application/pdf"
+	contentTypePNG = "image/png"
 )
 
 // @Summary Метод создания читательского билета
@@ -18,38 +35,45 @@ import (
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Идентификатор читателя"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор запроса с тем же ключом возвращает первый ответ"
 // @Success 201 "Успешное создание читательского билета"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 401 {object} dto.ErrorResponse "Неавторизованный пользователь"
-// @Failure 403 {object} dto.ErrorResponse "Доступ запрещен"
-// @Failure 409 {object} dto.ErrorResponse "Читательский билет уже существует"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 409 {object} dto.ProblemDetails "Читательский билет уже существует"
+// @Failure 422 {object} dto.ProblemDetails "Ключ идемпотентности использован с другим запросом"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/readers/{id}/lib_cards [post]
 func (h *Handler) createLibCard(c *gin.Context) {
 	readerID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
 	isReader, err := isReaderID(c, readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if !isReader {
-		c.AbortWithStatusJSON(http.StatusForbidden, jsondto.ErrorResponse{ErrorMsg: "access denied"})
+		h.abortWithError(c, weberrs.ErrAccessDenied)
 		return
 	}
 
 	err = h.libCardService.Create(c.Request.Context(), readerID)
-	if err != nil && errors.Is(err, errs.ErrLibCardAlreadyExist) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	if err != nil {
+		h.abortWithError(c, err)
 		return
 	}
+
+	// the card is created, a failure only leaves the audit entry without the snapshot
+	libCard, state, err := h.getLibCardWithState(c.Request.Context(), readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
+		h.logger.WithContext(c.Request.Context()).WithError(err).WithField("reader_id", readerID).Warn("created lib card reading failed")
+	} else {
+		setAuditResourceID(c, libCard.ID)
+		h.setAuditAfter(c, h.convertToJSONLibCardModel(libCard, state))
 	}
 
 	c.Status(http.StatusCreated)
@@ -62,59 +86,71 @@ func (h *Handler) createLibCard(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Param id path string true "Идентификатор читателя"
-// @Success 200 "Успешное обновление читательского билета"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 401 {object} dto.ErrorResponse "Неавторизованный пользователь"
-// @Failure 403 {object} dto.ErrorResponse "Доступ запрещен"
-// @Failure 404 {object} dto.ErrorResponse "Читательского билета не существует"
-// @Failure 409 {object} dto.ErrorResponse "Читательский билет актуален"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Param If-Match header string true "ETag читательского билета, полученный при его чтении"
+// @Success 200 "Успешное обновление читательского билета, ETag содержит новую версию"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Читательского билета не существует"
+// @Failure 409 {object} dto.ProblemDetails "Читательский билет актуален или заблокирован"
+// @Failure 412 {object} dto.ProblemDetails "Читательский билет был изменен, версия не совпадает с If-Match"
+// @Failure 428 {object} dto.ProblemDetails "Не передан заголовок If-Match"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/readers/{id}/lib_cards [put]
 func (h *Handler) updateLibCard(c *gin.Context) {
 	readerID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
 	isReader, err := isReaderID(c, readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if !isReader {
-		c.AbortWithStatusJSON(http.StatusForbidden, jsondto.ErrorResponse{ErrorMsg: "access denied"})
+		h.abortWithError(c, weberrs.ErrAccessDenied)
 		return
 	}
 
-	libCard, err := h.libCardService.GetByReaderID(c.Request.Context(), readerID)
-	if err != nil && !errors.Is(err, errs.ErrLibCardDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrLibCardDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	unlock := h.resourceLocks.Lock(h.getLibCardLockKey(readerID))
+	defer unlock()
+
+	libCard, state, err := h.getLibCardWithState(c.Request.Context(), readerID)
+	if err != nil {
+		h.abortWithError(c, err)
 		return
 	}
 
-	err = h.libCardService.Update(c.Request.Context(), libCard)
-	if err != nil && errors.Is(err, errs.ErrLibCardDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	if err = h.checkIfMatch(c, h.getLibCardVersion(libCard, state), true); err != nil {
+		h.abortWithError(c, err)
 		return
 	}
-	if err != nil && errors.Is(err, errs.ErrLibCardIsValid) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+
+	if state.Blocked {
+		h.abortWithError(c, fmt.Errorf("%w: %s", weberrs.ErrLibCardIsBlocked, state.BlockReason))
 		return
 	}
-	if err != nil && errors.Is(err, errs.ErrLibCardObjectIsNil) {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+
+	h.setAuditBefore(c, h.convertToJSONLibCardModel(libCard, state))
+
+	err = h.libCardService.Update(c.Request.Context(), libCard)
+	if err != nil {
+		h.abortWithError(c, err)
 		return
 	}
+
+	libCard, state, err = h.getLibCardWithState(c.Request.Context(), readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
+	setAuditResourceID(c, libCard.ID)
+	h.setAuditAfter(c, h.convertToJSONLibCardModel(libCard, state))
+
+	h.setETag(c, h.getLibCardVersion(libCard, state))
 	c.Status(http.StatusOK)
 }
 
@@ -126,59 +162,355 @@ func (h *Handler) updateLibCard(c *gin.Context) {
 // @Produce  json
 // @Param id path string true "Идентификатор читателя"
 // @Success 200 {array} models.JSONLibCardModel "Успешное получение читательского билета"
-// @Failure 400 {object} dto.ErrorResponse "Некорректный идентификатор пользователя"
-// @Failure 401 {object} dto.ErrorResponse "Неавторизованный пользователь"
-// @Failure 403 {object} dto.ErrorResponse "Доступ запрещен"
-// @Failure 404 {object} dto.ErrorResponse "Читательский билет не найден"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Некорректный идентификатор пользователя"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Читательский билет не найден"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/readers/{id}/lib_cards [get]
 func (h *Handler) getLibCardByReaderID(c *gin.Context) {
 	readerID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
 	isReader, err := isReaderID(c, readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if !isReader {
-		c.AbortWithStatusJSON(http.StatusForbidden, jsondto.ErrorResponse{ErrorMsg: "access denied"})
+		h.abortWithError(c, weberrs.ErrAccessDenied)
+		return
+	}
+
+	libCard, state, err := h.getLibCardWithState(c.Request.Context(), readerID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setETag(c, h.getLibCardVersion(libCard, state))
+	c.JSON(http.StatusOK, []*jsonmodels.JSONLibCardModel{h.convertToJSONLibCardModel(libCard, state)}) // один чит билет упаковываю в массив
+}
+
+// @Summary Метод получения печатной формы читательского билета в PDF
+// @Security ApiKeyAuth
+// @Tags reader_lib_card
+// @ID getLibCardPDF
+// @Produce  application/pdf
+// @Param id path string true "Идентификатор читателя"
+// @Success 200 {file} file "Печатная форма читательского билета со штрихкодом Code128 и QR-кодом"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Читатель или читательский билет не найден"
+// @Failure 409 {object} dto.ProblemDetails "Читательский билет заблокирован"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/readers/{id}/lib_cards/card.pdf [get]
+func (h *Handler) getLibCardPDF(c *gin.Context) {
+	h.renderLibCard(c, contentTypePDF, h.cardRenderer.RenderPDF)
+}
+
+// @Summary Метод получения изображения читательского билета в PNG
+// @Security ApiKeyAuth
+// @Tags reader_lib_card
+// @ID getLibCardPNG
+// @Produce  image/png
+// @Param id path string true "Идентификатор читателя"
+// @Success 200 {file} file "Изображение читательского билета со штрихкодом Code128 и QR-кодом"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Читатель или читательский билет не найден"
+// @Failure 409 {object} dto.ProblemDetails "Читательский билет заблокирован"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/readers/{id}/lib_cards/card.png [get]
+func (h *Handler) getLibCardPNG(c *gin.Context) {
+	h.renderLibCard(c, contentTypePNG, h.cardRenderer.RenderPNG)
+}
+
+type libCardRenderFunc func(w io.Writer, data *cardprint.CardData) error
+
+func (h *Handler) renderLibCard(c *gin.Context, contentType string, render libCardRenderFunc) {
+	readerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.abortWithInvalidParam(c, "id", err)
+		return
+	}
+
+	isAllowed, err := isReaderOrStaff(c, readerID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+	if !isAllowed {
+		h.abortWithError(c, weberrs.ErrAccessDenied)
+		return
+	}
+
+	reader, err := h.readerService.GetByID(c.Request.Context(), readerID)
+	if err != nil {
+		h.abortWithError(c, err)
 		return
 	}
 
 	libCard, err := h.libCardService.GetByReaderID(c.Request.Context(), readerID)
-	if err != nil && !errors.Is(err, errs.ErrLibCardDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	if err != nil {
+		h.abortWithError(c, err)
 		return
 	}
-	if err != nil && errors.Is(err, errs.ErrLibCardDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+
+	state, err := h.libCardRegistry.GetState(c.Request.Context(), libCard)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+	if state.Blocked {
+		h.abortWithError(c, weberrs.ErrLibCardIsBlocked)
 		return
 	}
 
-	libCards := []*models.LibCardModel{libCard} // один чит билет упаковываю в массив
+	var buf bytes.Buffer
+	err = render(&buf, &cardprint.CardData{
+		ReaderFio:      reader.Fio,
+		LibCardNum:     state.LibCardNum,
+		IssueDate:      libCard.IssueDate,
+		ExpirationDate: libCard.IssueDate.AddDate(0, 0, libCard.Validity),
+	})
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
 
-	c.JSON(http.StatusOK, h.convertArrayToJSONLibCardModels(libCards))
+// @Summary Метод блокировки читательского билета
+// @Security ApiKeyAuth
+// @Tags admin_lib_cards
+// @ID blockLibCard
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Идентификатор читателя"
+// @Param input body dto.LibCardStatusInputDTO true "Причина блокировки"
+// @Param If-Match header string false "ETag читательского билета: изменение выполняется, только если билет не менялся"
+// @Success 200 {object} models.JSONLibCardModel "Читательский билет заблокирован"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Читательский билет не найден"
+// @Failure 409 {object} dto.ProblemDetails "Читательский билет уже заблокирован"
+// @Failure 412 {object} dto.ProblemDetails "Читательский билет был изменен, версия не совпадает с If-Match"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/readers/{id}/lib_cards/block [post]
+func (h *Handler) blockLibCard(c *gin.Context) {
+	h.changeLibCardStatus(c, h.libCardRegistry.Block)
 }
 
-func (h *Handler) convertArrayToJSONLibCardModels(libCards []*models.LibCardModel) []*jsonmodels.JSONLibCardModel {
-	jsonLibCardModels := make([]*jsonmodels.JSONLibCardModel, len(libCards))
-	for i, libCard := range libCards {
-		jsonLibCardModels[i] = h.convertToJSONLibCardModel(libCard)
+// @Summary Метод разблокировки читательского билета
+// @Security ApiKeyAuth
+// @Tags admin_lib_cards
+// @ID unblockLibCard
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Идентификатор читателя"
+// @Param input body dto.LibCardStatusInputDTO true "Причина разблокировки"
+// @Param If-Match header string false "ETag читательского билета: изменение выполняется, только если билет не менялся"
+// @Success 200 {object} models.JSONLibCardModel "Читательский билет разблокирован"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Читательский билет не найден"
+// @Failure 409 {object} dto.ProblemDetails "Читательский билет не заблокирован"
+// @Failure 412 {object} dto.ProblemDetails "Читательский билет был изменен, версия не совпадает с If-Match"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/readers/{id}/lib_cards/unblock [post]
+func (h *Handler) unblockLibCard(c *gin.Context) {
+	h.changeLibCardStatus(c, h.libCardRegistry.Unblock)
+}
+
+// @Summary Метод выдачи нового номера взамен утерянного читательского билета
+// @Security ApiKeyAuth
+// @Tags admin_lib_cards
+// @ID replaceLibCard
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Идентификатор читателя"
+// @Param input body dto.LibCardStatusInputDTO true "Причина замены"
+// @Param If-Match header string false "ETag читательского билета: изменение выполняется, только если билет не менялся"
+// @Success 200 {object} models.JSONLibCardModel "Выдан новый номер читательского билета"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Читательский билет не найден"
+// @Failure 412 {object} dto.ProblemDetails "Читательский билет был изменен, версия не совпадает с If-Match"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/readers/{id}/lib_cards/replace [post]
+func (h *Handler) replaceLibCard(c *gin.Context) {
+	h.changeLibCardStatus(c, h.libCardRegistry.Replace)
+}
+
+type libCardStatusChangeFunc func(ctx context.Context, libCard *models.LibCardModel, actorID uuid.UUID, reason string) (*libcard.CardState, error)
+
+func (h *Handler) changeLibCardStatus(c *gin.Context, changeStatus libCardStatusChangeFunc) {
+	readerID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.abortWithInvalidParam(c, "id", err)
+		return
+	}
+
+	actorID, _, err := getReaderData(c)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	var inp jsondto.LibCardStatusInputDTO
+	if err = c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	unlock := h.resourceLocks.Lock(h.getLibCardLockKey(readerID))
+	defer unlock()
+
+	libCard, state, err := h.getLibCardWithState(c.Request.Context(), readerID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	// If-Match is optional here: the registry rejects changes which contradict the current status
+	if err = h.checkIfMatch(c, h.getLibCardVersion(libCard, state), false); err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setAuditBefore(c, h.convertToJSONLibCardModel(libCard, state))
+
+	state, err = changeStatus(c.Request.Context(), libCard, actorID, inp.Reason)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	jsonLibCard := h.convertToJSONLibCardModel(libCard, state)
+	setAuditResourceID(c, libCard.ID)
+	h.setAuditAfter(c, jsonLibCard)
+
+	h.setETag(c, h.getLibCardVersion(libCard, state))
+	c.JSON(http.StatusOK, jsonLibCard)
+}
+
+// getLibCardWithState returns the lib card of the reader with the status kept by the registry.
+func (h *Handler) getLibCardWithState(ctx context.Context, readerID uuid.UUID) (*models.LibCardModel, *libcard.CardState, error) {
+	libCard, err := h.libCardService.GetByReaderID(ctx, readerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err = h.libCardRegistry.Index(ctx, libCard); err != nil {
+		return nil, nil, err
+	}
+
+	state, err := h.libCardRegistry.GetState(ctx, libCard)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return libCard, state, nil
+}
+
+// isLibCardNumTaken checks numbers of replacement cards against the cards of the services
+// layer. Without the repository only the numbers known to the registry are checked.
+func (h *Handler) isLibCardNumTaken(ctx context.Context, libCardNum string) (bool, error) {
+	if h.libCardRepo == nil {
+		return false, nil
+	}
+
+	libCard, err := h.libCardRepo.GetByNum(ctx, libCardNum)
+	if err != nil && errors.Is(err, errs.ErrLibCardDoesNotExists) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return libCard != nil, nil
+}
+
+func (h *Handler) getLibCardLockKey(readerID uuid.UUID) string {
+	return "lib_card:" + readerID.String()
+}
+
+// checkReaderLibCardIsNotBlocked returns ErrLibCardIsBlocked with the block reason.
+// A missing lib card is not an error here, it is reported by the services layer.
+func (h *Handler) checkReaderLibCardIsNotBlocked(ctx context.Context, readerID uuid.UUID) error {
+	libCard, err := h.libCardService.GetByReaderID(ctx, readerID)
+	if err != nil && errors.Is(err, errs.ErrLibCardDoesNotExists) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return h.checkLibCardIsNotBlocked(ctx, libCard)
+}
+
+func (h *Handler) checkLibCardIsNotBlocked(ctx context.Context, libCard *models.LibCardModel) error {
+	state, err := h.libCardRegistry.GetState(ctx, libCard)
+	if err != nil {
+		return err
 	}
 
-	return jsonLibCardModels
+	if state.Blocked {
+		return fmt.Errorf("%w: %s", weberrs.ErrLibCardIsBlocked, state.BlockReason)
+	}
+
+	return nil
 }
 
-func (h *Handler) convertToJSONLibCardModel(libCard *models.LibCardModel) *jsonmodels.JSONLibCardModel {
+func (h *Handler) convertToJSONLibCardModel(libCard *models.LibCardModel, state *libcard.CardState) *jsonmodels.JSONLibCardModel {
+	expirationDate := libCard.IssueDate.AddDate(0, 0, libCard.Validity)
+
+	status := libCardStatusActive
+	if state.Blocked {
+		status = libCardStatusBlocked
+	} else if !libCard.ActionStatus || time.Now().After(expirationDate) {
+		status = libCardStatusExpired
+	}
+
+	statusHistory := make([]*jsonmodels.JSONLibCardStatusModel, len(state.History))
+	for i, change := range state.History {
+		statusHistory[i] = &jsonmodels.JSONLibCardStatusModel{
+			Event:              string(change.Event),
+			Reason:             change.Reason,
+			LibCardNum:         change.LibCardNum,
+			PreviousLibCardNum: change.PreviousLibCardNum,
+			ActorID:            change.ActorID,
+			CreatedAt:          change.CreatedAt,
+		}
+	}
+
 	return &jsonmodels.JSONLibCardModel{
-		ID:           libCard.ID,
-		LibCardNum:   libCard.LibCardNum,
-		Validity:     libCard.Validity,
-		IssueDate:    libCard.IssueDate,
-		ActionStatus: libCard.ActionStatus,
+		ID:             libCard.ID,
+		ReaderID:       libCard.ReaderID,
+		LibCardNum:     state.LibCardNum,
+		Validity:       libCard.Validity,
+		IssueDate:      libCard.IssueDate,
+		ExpirationDate: expirationDate,
+		ActionStatus:   libCard.ActionStatus,
+		Status:         status,
+		BlockReason:    state.BlockReason,
+		StatusHistory:  statusHistory,
+		Version:        h.getLibCardVersion(libCard, state),
 	}
 }
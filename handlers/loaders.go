@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/dataloader"
+)
+
+const loadersKey = "loaders"
+
+// requestLoaders are attached to the gin context on first use, so that the helpers of
+// a request share them and cached entities never outlive the request.
+type requestLoaders struct {
+	readers        *dataloader.Loader[uuid.UUID, *models.ReaderModel]
+	books          *dataloader.Loader[uuid.UUID, *models.BookModel]
+	visibleRatings *dataloader.Loader[uuid.UUID, []*models.RatingModel]
+}
+
+func (h *Handler) getLoaders(c *gin.Context) *requestLoaders {
+	if loaders, ok := c.Get(loadersKey); ok {
+		return loaders.(*requestLoaders)
+	}
+
+	loaders := &requestLoaders{
+		readers:        dataloader.NewLoader(h.readerService.GetByID, h.loaderParallelism),
+		books:          dataloader.NewLoader(h.bookService.GetByID, h.loaderParallelism),
+		visibleRatings: dataloader.NewLoader(h.getVisibleRatingsByBookID, h.loaderParallelism),
+	}
+	c.Set(loadersKey, loaders)
+
+	return loaders
+}
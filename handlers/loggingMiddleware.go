@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/logging"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+
+	maxRequestIDLength = 128
+	maxLoggedBodySize  = 4096
+)
+
+// secretBodyRoutes get codes of the second factor in a field named "code", which cannot be
+// redacted by name as problem details use it too, so their bodies are not logged at all.
+var secretBodyRoutes = map[string]bool{
+	"/api/v1/auth/sign-in/2fa/verify": true,
+	"/api/v1/auth/2fa/confirm":        true,
+	"/api/v1/auth/2fa/disable":        true,
+	"/api/v1/auth/2fa/recovery_codes": true,
+}
+
+// requestID propagates the X-Request-ID of the client or generates a new one
+// and puts it into the request context, which is passed to all service calls.
+func (h *Handler) requestID(c *gin.Context) {
+	requestID := c.GetHeader(requestIDHeader)
+	if !h.isValidRequestID(requestID) {
+		requestID = uuid.NewString()
+	}
+
+	c.Header(requestIDHeader, requestID)
+	c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+}
+
+func (h *Handler) logRequest(c *gin.Context) {
+	start := time.Now()
+
+	var body string
+	if h.logger.Logger.IsLevelEnabled(logrus.DebugLevel) && c.Request.Method != http.MethodGet {
+		body = h.readBodyForLog(c)
+	}
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+	if h.isProbeRoute(route) && c.Writer.Status() < http.StatusInternalServerError {
+		return
+	}
+
+	fields := logrus.Fields{
+		"method":        c.Request.Method,
+		"route":         route,
+		"status":        c.Writer.Status(),
+		"latency_ms":    float64(time.Since(start).Microseconds()) / 1000,
+		"client_ip":     c.ClientIP(),
+		"response_size": c.Writer.Size(),
+	}
+	if readerID, ok := c.Get(ID); ok && readerID != "" {
+		fields["reader_id"] = readerID
+	}
+	if role, ok := c.Get(Role); ok && role != "" {
+		fields["role"] = role
+	}
+	if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+		fields["trace_id"] = spanContext.TraceID().String()
+	}
+	if len(c.Errors) > 0 {
+		fields["errors"] = c.Errors.String()
+	}
+	if body != "" {
+		fields["request_body"] = body
+	}
+
+	entry := h.logger.WithContext(c.Request.Context()).WithFields(fields)
+
+	switch status := c.Writer.Status(); {
+	case status >= http.StatusInternalServerError:
+		entry.Error("request failed")
+	case status >= http.StatusBadRequest:
+		entry.Warn("request rejected")
+	default:
+		entry.Info("request handled")
+	}
+}
+
+func (h *Handler) recoverPanic(c *gin.Context, err any) {
+	h.logger.WithContext(c.Request.Context()).WithField("panic", err).Error("panic recovered")
+
+	h.abortWithProblem(c, h.newProblem(c, http.StatusInternalServerError, codeInternalError))
+}
+
+// readBodyForLog reads the beginning of the body with sensitive fields redacted
+// and restores the body for the handler, see also secretBodyRoutes.
+func (h *Handler) readBodyForLog(c *gin.Context) string {
+	if c.Request.Body == nil {
+		return ""
+	}
+	if secretBodyRoutes[c.FullPath()] {
+		return logging.RedactedValue
+	}
+
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, maxLoggedBodySize+1))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+	if err != nil {
+		return ""
+	}
+
+	if len(head) > maxLoggedBodySize {
+		return "[TRUNCATED]"
+	}
+
+	return logging.RedactJSON(head)
+}
+
+func (h *Handler) isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range requestID {
+		isAllowed := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' ||
+			r == '-' || r == '_' || r == '.' || r == ':'
+		if !isAllowed {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newDefaultLogger() *logrus.Entry {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+	logging.AddRequestIDHook(logger)
+
+	return logrus.NewEntry(logger)
+}
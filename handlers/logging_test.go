@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/config"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRequestRedactsTwoFactorCodes(t *testing.T) {
+	cfg := config.Default()
+	cfg.Storage.DataDir = t.TempDir()
+	logger, hook := test.NewNullLogger()
+	logger.SetLevel(logrus.DebugLevel)
+	router := NewHandler(nil, nil, nil, nil, nil, nil, time.Minute, time.Hour,
+		WithConfig(cfg), WithLogger(logrus.NewEntry(logger))).InitRoutes()
+
+	const code = "287082"
+	bodies := []struct {
+		path string
+		body string
+	}{
+		{"/api/v1/auth/sign-in/2fa/verify", `{"challenge_token":"challenge","code":"` + code + `"}`},
+		{"/api/v1/auth/2fa/confirm", `{"code":"` + code + `"}`},
+		{"/api/v1/auth/2fa/disable", `{"code":"` + code + `"}`},
+		{"/api/v1/auth/2fa/recovery_codes", `{"code":"` + code + `"}`},
+	}
+
+	for _, tt := range bodies {
+		t.Run(tt.path, func(t *testing.T) {
+			hook.Reset()
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			router.ServeHTTP(httptest.NewRecorder(), req)
+
+			entry := hook.LastEntry()
+			if entry == nil {
+				t.Fatal("request is not logged")
+			}
+			if got := entry.Data["request_body"]; got != logging.RedactedValue {
+				t.Errorf("got request body %v, want it redacted", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"github.com/nikitalystsev/BookSmart-services/errs"
+	"github.com/nikitalystsev/BookSmart-services/impl"
+	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/copybarcode"
+	"math"
+	"net/http"
+	"time"
+)
+
+const defaultFinePerDay = 10.0
+
+// @Summary Метод получения сведений о читателе по отсканированному номеру читательского билета
+// @Security ApiKeyAuth
+// @Tags admin_lookup
+// @ID lookupByLibCardNum
+// @Accept  json
+// @Produce  json
+// @Param lib_card_num path string true "Номер читательского билета"
+// @Success 200 {object} dto.LibCardLookupOutputDTO "Читатель, билет, открытые и просроченные брони, штрафы"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Читательский билет не найден"
+// @Failure 409 {object} dto.ProblemDetails "Номер билета заменен (билет утерян)"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/lookup/lib_cards/{lib_card_num} [get]
+func (h *Handler) lookupByLibCardNum(c *gin.Context) {
+	readerID, err := h.getReaderIDByLibCardNum(c.Request.Context(), c.Param("lib_card_num"))
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	reader, err := h.readerService.GetByID(c.Request.Context(), readerID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	libCard, err := h.libCardService.GetByReaderID(c.Request.Context(), readerID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	state, err := h.libCardRegistry.GetState(c.Request.Context(), libCard)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	reservations, err := h.reservationService.GetAllReservationsByReaderID(c.Request.Context(), readerID)
+	if err != nil && !errors.Is(err, errs.ErrReservationDoesNotExists) {
+		h.abortWithError(c, err)
+		return
+	}
+
+	var (
+		now                 = time.Now()
+		openReservations    []*models.ReservationModel
+		overdueReservations []*models.ReservationModel
+	)
+	for _, reservation := range reservations {
+		if reservation.State == impl.ReservationClosed {
+			continue
+		}
+		openReservations = append(openReservations, reservation)
+		if h.isReservationOverdue(reservation, now) {
+			overdueReservations = append(overdueReservations, reservation)
+		}
+	}
+
+	openReservationDTOs, err := h.copyReservationModelsToReservationOutputDTOs(c, openReservations)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	overdueReservationDTOs, err := h.copyReservationModelsToReservationOutputDTOs(c, overdueReservations)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	fines, totalFine := h.calculateFines(overdueReservations, now)
+	jsonLibCard := h.convertToJSONLibCardModel(libCard, state)
+
+	c.JSON(http.StatusOK, jsondto.LibCardLookupOutputDTO{
+		Reader: &jsondto.LookupReaderDTO{
+			ID:          reader.ID,
+			Fio:         reader.Fio,
+			PhoneNumber: reader.PhoneNumber,
+			Age:         reader.Age,
+		},
+		LibCard:             jsonLibCard,
+		IsLibCardValid:      jsonLibCard.Status == libCardStatusActive,
+		OpenReservations:    openReservationDTOs,
+		OverdueReservations: overdueReservationDTOs,
+		Fines:               fines,
+		TotalFine:           totalFine,
+	})
+}
+
+// @Summary Метод получения сведений об экземпляре книги по отсканированному штрихкоду
+// @Security ApiKeyAuth
+// @Tags admin_lookup
+// @ID lookupByBookCopyBarcode
+// @Accept  json
+// @Produce  json
+// @Param barcode path string true "Штрихкод экземпляра книги (идентификатор книги без дефисов и номер экземпляра через дефис)"
+// @Success 200 {object} dto.BookCopyLookupOutputDTO "Книга и ее активные брони"
+// @Failure 400 {object} dto.ProblemDetails "Неверный штрихкод"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Книга не найдена"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/lookup/book_copies/{barcode} [get]
+func (h *Handler) lookupByBookCopyBarcode(c *gin.Context) {
+	copyBarcode, err := copybarcode.Parse(c.Param("barcode"))
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	book, err := h.bookService.GetByID(c.Request.Context(), copyBarcode.BookID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	reservations, err := h.reservationService.GetByBookID(c.Request.Context(), copyBarcode.BookID)
+	if err != nil && !errors.Is(err, errs.ErrReservationDoesNotExists) {
+		h.abortWithError(c, err)
+		return
+	}
+
+	activeReservations := make([]*models.ReservationModel, 0, len(reservations))
+	for _, reservation := range reservations {
+		if reservation.State != impl.ReservationClosed {
+			activeReservations = append(activeReservations, reservation)
+		}
+	}
+
+	c.JSON(http.StatusOK, jsondto.BookCopyLookupOutputDTO{
+		Book:                    h.convertToJSONBookModel(book),
+		CopyNum:                 copyBarcode.CopyNum,
+		ActiveReservations:      h.convertArrayToJSONReservationModels(activeReservations),
+		ActiveReservationsCount: len(activeReservations),
+	})
+}
+
+// getReaderIDByLibCardNum resolves replaced numbers with the registry and other numbers
+// with the lib card repository. Without the repository only numbers seen by this API
+// (issued, viewed or replaced) are resolved.
+func (h *Handler) getReaderIDByLibCardNum(ctx context.Context, libCardNum string) (uuid.UUID, error) {
+	readerID, err := h.libCardRegistry.GetReaderIDByNum(ctx, libCardNum)
+	if err == nil || !errors.Is(err, weberrs.ErrLibCardNumIsUnknown) || h.libCardRepo == nil {
+		return readerID, err
+	}
+
+	libCard, err := h.libCardRepo.GetByNum(ctx, libCardNum)
+	if err != nil && errors.Is(err, errs.ErrLibCardDoesNotExists) {
+		return uuid.Nil, weberrs.ErrLibCardNumIsUnknown
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if libCard == nil {
+		return uuid.Nil, weberrs.ErrLibCardNumIsUnknown
+	}
+
+	if err = h.libCardRegistry.Index(ctx, libCard); err != nil {
+		return uuid.Nil, err
+	}
+
+	return libCard.ReaderID, nil
+}
+
+func (h *Handler) isReservationOverdue(reservation *models.ReservationModel, now time.Time) bool {
+	return reservation.State == impl.ReservationExpired || now.After(reservation.ReturnDate)
+}
+
+func (h *Handler) calculateFines(overdueReservations []*models.ReservationModel, now time.Time) ([]*jsondto.FineDTO, float64) {
+	var (
+		fines     = make([]*jsondto.FineDTO, 0, len(overdueReservations))
+		totalFine float64
+	)
+
+	for _, reservation := range overdueReservations {
+		overdueDays := int(math.Ceil(now.Sub(reservation.ReturnDate).Hours() / 24))
+		if overdueDays < 1 {
+			overdueDays = 1
+		}
+
+		amount := float64(overdueDays) * h.finePerDay
+		totalFine += amount
+
+		fines = append(fines, &jsondto.FineDTO{
+			ReservationID: reservation.ID,
+			ReturnDate:    reservation.ReturnDate,
+			OverdueDays:   overdueDays,
+			Amount:        amount,
+		})
+	}
+
+	return fines, totalFine
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/nikitalystsev/BookSmart-services/errs"
+	"github.com/nikitalystsev/BookSmart-services/pkg/hash"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"net/http"
+	"time"
+)
+
+const metricsRoute = "/metrics"
+
+type errorLabel struct {
+	err   error
+	label string
+}
+
+var reservationOutcomeLabels = []errorLabel{
+	{errs.ErrReaderDoesNotExists, "reader_does_not_exist"},
+	{errs.ErrReaderHasExpiredBooks, "reader_has_expired_books"},
+	{errs.ErrReservationsLimitExceeded, "reservations_limit_exceeded"},
+	{errs.ErrLibCardDoesNotExists, "lib_card_does_not_exist"},
+	{errs.ErrLibCardIsInvalid, "lib_card_is_invalid"},
+	{weberrs.ErrLibCardIsBlocked, "lib_card_is_blocked"},
+	{errs.ErrBookDoesNotExists, "book_does_not_exist"},
+	{errs.ErrBookNoCopiesNum, "book_no_copies_num"},
+	{errs.ErrUniqueBookNotReserved, "unique_book_not_reserved"},
+	{errs.ErrReservationAgeLimit, "reservation_age_limit"},
+	{errs.ErrReservationAlreadyExists, "reservation_already_exists"},
+}
+
+var authFailureLabels = []errorLabel{
+	{weberrs.ErrEmptyAuthHeader, "empty_auth_header"},
+	{weberrs.ErrInvalidAuthHeader, "invalid_auth_header"},
+	{weberrs.ErrEmptyToken, "empty_token"},
+	{weberrs.ErrInvalidToken, "invalid_token"},
+	{weberrs.ErrInvalidOIDCState, "invalid_oidc_state"},
+	{weberrs.ErrInvalidOIDCCode, "invalid_oidc_code"},
+	{weberrs.ErrInvalidIDToken, "invalid_id_token"},
+	{weberrs.ErrNoStaffRole, "no_staff_role"},
+	{weberrs.ErrInvalidChallengeToken, "invalid_challenge_token"},
+	{weberrs.ErrInvalidTwoFactorCode, "invalid_two_factor_code"},
+	{weberrs.ErrInvalidAPIKey, "invalid_api_key"},
+	{weberrs.ErrAPIKeyIsRevoked, "api_key_revoked"},
+	{errs.ErrReaderDoesNotExists, "reader_does_not_exist"},
+	{hash.ErrInvalidLoginOrPassword, "invalid_login_or_password"},
+}
+
+func (h *Handler) collectMetrics(c *gin.Context) {
+	if c.Request.URL.Path == metricsRoute {
+		return
+	}
+
+	start := time.Now()
+	h.metrics.IncInFlight()
+	defer h.metrics.DecInFlight()
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	h.metrics.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+}
+
+// MetricsHandler returns the Prometheus endpoint for serving on a separate metrics port.
+func (h *Handler) MetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(metricsRoute, h.metrics.Handler())
+
+	return mux
+}
+
+func (h *Handler) getReservationOutcome(err error) string {
+	return h.getErrorLabel(err, reservationOutcomeLabels, "reserved")
+}
+
+func (h *Handler) getAuthFailureReason(err error) string {
+	return h.getErrorLabel(err, authFailureLabels, "")
+}
+
+func (h *Handler) getErrorLabel(err error, labels []errorLabel, successLabel string) string {
+	if err == nil {
+		return successLabel
+	}
+
+	for _, errLabel := range labels {
+		if errors.Is(err, errLabel.err) {
+			return errLabel.label
+		}
+	}
+
+	return "internal_error"
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMetricsRoute(t *testing.T) {
+	tests := []struct {
+		name            string
+		metricsAddr     string
+		wantRouteStatus int
+	}{
+		{"public", "", http.StatusOK},
+		{"separate port", ":9100", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Storage.DataDir = t.TempDir()
+			cfg.Metrics.Addr = tt.metricsAddr
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			h := NewHandler(nil, nil, nil, nil, nil, nil, time.Minute, time.Hour, WithConfig(cfg))
+
+			w := httptest.NewRecorder()
+			h.InitRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, metricsRoute, nil))
+			if w.Code != tt.wantRouteStatus {
+				t.Errorf("got status %d of the public route, want %d", w.Code, tt.wantRouteStatus)
+			}
+
+			w = httptest.NewRecorder()
+			h.MetricsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, metricsRoute, nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("got status %d of the metrics handler, want 200", w.Code)
+			}
+		})
+	}
+}
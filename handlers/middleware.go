@@ -2,10 +2,10 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/nikitalystsev/BookSmart-web-api/core/dto"
-	"net/http"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
 	"strings"
 )
 
@@ -13,34 +13,79 @@ const (
 	authorizationHeader = "Authorization"
 	ID                  = "ID"
 	Role                = "role"
+
+	readerRole = "Reader"
 )
 
 func (h *Handler) readerIdentity(c *gin.Context) {
+	if isAPIKeyRequest(c) {
+		return
+	}
+
 	id, role, err := h.parseAuthHeader(c)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, dto.ErrorResponse{ErrorMsg: err.Error()})
+		h.metrics.IncAuthFailure(h.getAuthFailureReason(err))
+		h.abortWithError(c, err)
+		return
 	}
 
 	c.Set(ID, id)
 	c.Set(Role, role)
 }
 
+func (h *Handler) staffIdentity(c *gin.Context) {
+	_, role, err := getReaderData(c)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	if role == readerRole {
+		h.metrics.IncAuthFailure("staff_role_required")
+		h.abortWithError(c, weberrs.ErrAccessDenied)
+		return
+	}
+}
+
+// requireClientCert rejects requests without a client certificate verified during
+// the TLS handshake by the CAs of the server.
+func (h *Handler) requireClientCert(c *gin.Context) {
+	if c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0 {
+		h.metrics.IncAuthFailure("client_certificate_required")
+		h.abortWithError(c, weberrs.ErrClientCertificateRequired)
+		return
+	}
+}
+
+func (h *Handler) getAdminMiddlewares() []gin.HandlerFunc {
+	if h.isAdminCertRequired {
+		return []gin.HandlerFunc{h.requireClientCert, h.staffIdentity}
+	}
+
+	return []gin.HandlerFunc{h.staffIdentity}
+}
+
 func (h *Handler) parseAuthHeader(c *gin.Context) (string, string, error) {
 	header := c.GetHeader(authorizationHeader)
 	if header == "" {
-		return "", "", errors.New("empty auth header")
+		return "", "", weberrs.ErrEmptyAuthHeader
 	}
 
 	headerParts := strings.Split(header, " ")
 	if len(headerParts) != 2 || headerParts[0] != "Bearer" {
-		return "", "", errors.New("invalid auth header")
+		return "", "", weberrs.ErrInvalidAuthHeader
 	}
 
 	if len(headerParts[1]) == 0 {
-		return "", "", errors.New("token is empty")
+		return "", "", weberrs.ErrEmptyToken
+	}
+
+	id, role, err := h.tokenManager.Parse(headerParts[1])
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", weberrs.ErrInvalidToken, err)
 	}
 
-	return h.tokenManager.Parse(headerParts[1])
+	return id, role, nil
 }
 
 func getReaderData(c *gin.Context) (uuid.UUID, string, error) {
@@ -72,7 +117,13 @@ func getReaderData(c *gin.Context) (uuid.UUID, string, error) {
 	return readerID, roleStr, nil
 }
 
+// isReaderID reports whether the request is made by the reader. Integrations act on
+// behalf of any reader, since apiKeyIdentity lets them call only routes of their scopes.
 func isReaderID(c *gin.Context, readerID uuid.UUID) (bool, error) {
+	if isAPIKeyRequest(c) {
+		return true, nil
+	}
+
 	gettingReaderID, _, err := getReaderData(c)
 	if err != nil {
 		return false, err
@@ -84,3 +135,16 @@ func isReaderID(c *gin.Context, readerID uuid.UUID) (bool, error) {
 
 	return true, nil
 }
+
+func isReaderOrStaff(c *gin.Context, readerID uuid.UUID) (bool, error) {
+	if isAPIKeyRequest(c) {
+		return true, nil
+	}
+
+	gettingReaderID, role, err := getReaderData(c)
+	if err != nil {
+		return false, err
+	}
+
+	return gettingReaderID == readerID || role != readerRole, nil
+}
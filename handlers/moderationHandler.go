@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"github.com/nikitalystsev/BookSmart-services/errs"
+	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
+	jsonmodels "github.com/nikitalystsev/BookSmart-web-api/core/models"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/moderation"
+	"net/http"
+	"time"
+)
+
+// @Summary Метод отправки жалобы на отзыв
+// @Security ApiKeyAuth
+// @Tags book_ratings
+// @ID reportRating
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Идентификатор книги"
+// @Param rating_id path string true "Идентификатор отзыва"
+// @Param input body dto.ReviewReportInputDTO true "Причина жалобы"
+// @Success 201 "Жалоба успешно отправлена"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 404 {object} dto.ProblemDetails "Отзыв не найден"
+// @Failure 409 {object} dto.ProblemDetails "Читатель уже пожаловался на отзыв"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/books/{id}/ratings/{rating_id}/reports [post]
+func (h *Handler) reportRating(c *gin.Context) {
+	bookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		h.abortWithInvalidParam(c, "id", err)
+		return
+	}
+
+	ratingID, err := uuid.Parse(c.Param("rating_id"))
+	if err != nil {
+		h.abortWithInvalidParam(c, "rating_id", err)
+		return
+	}
+
+	readerID, _, err := getReaderData(c)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	var inp jsondto.ReviewReportInputDTO
+	if err = c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	rating, err := h.getRatingByBookAndID(c.Request.Context(), bookID, ratingID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	err = h.reviewModerator.Report(c.Request.Context(), rating, readerID, inp.Reason)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setAuditAfter(c, &jsonmodels.JSONReviewReportModel{ReaderID: readerID, Reason: inp.Reason, CreatedAt: time.Now()})
+
+	c.Status(http.StatusCreated)
+}
+
+// @Summary Метод получения очереди отзывов на модерацию
+// @Security ApiKeyAuth
+// @Tags admin_reviews
+// @ID getModerationQueue
+// @Accept  json
+// @Produce  json
+// @Success 200 {array} models.JSONReviewModerationModel "Успешное получение очереди модерации"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/reviews/moderation [get]
+func (h *Handler) getModerationQueue(c *gin.Context) {
+	queue, err := h.reviewModerator.GetQueue(c.Request.Context())
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, h.convertArrayToJSONReviewModerationModels(queue))
+}
+
+// @Summary Метод модерации отзыва (approve, hide, delete)
+// @Security ApiKeyAuth
+// @Tags admin_reviews
+// @ID moderateReview
+// @Accept  json
+// @Produce  json
+// @Param rating_id path string true "Идентификатор отзыва"
+// @Param input body dto.ReviewModerationInputDTO true "Действие модератора и причина"
+// @Param If-Match header string true "Версия отзыва из очереди модерации в кавычках"
+// @Success 200 {object} models.JSONReviewModerationModel "Успешная модерация отзыва"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Отзыв не найден в очереди модерации"
+// @Failure 409 {object} dto.ProblemDetails "Отзыв уже удален"
+// @Failure 412 {object} dto.ProblemDetails "Отзыв был изменен, версия не совпадает с If-Match"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 428 {object} dto.ProblemDetails "Не передан заголовок If-Match"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/admin/reviews/{rating_id} [patch]
+func (h *Handler) moderateReview(c *gin.Context) {
+	ratingID, err := uuid.Parse(c.Param("rating_id"))
+	if err != nil {
+		h.abortWithInvalidParam(c, "rating_id", err)
+		return
+	}
+
+	moderatorID, _, err := getReaderData(c)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	var inp jsondto.ReviewModerationInputDTO
+	if err = c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	unlock := h.resourceLocks.Lock("review:" + ratingID.String())
+	defer unlock()
+
+	status, err := h.reviewModerator.GetStatus(c.Request.Context(), ratingID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	if err = h.checkIfMatch(c, h.getReviewVersion(status), true); err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setAuditBefore(c, h.convertToJSONReviewModerationModel(status))
+
+	status, err = h.reviewModerator.Moderate(c.Request.Context(), ratingID, moderatorID, moderation.Action(inp.Action), inp.Reason)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.invalidateRatingsCache(status.Rating.BookID)
+	h.updateRatingScore(c.Request.Context(), status.Rating.BookID)
+
+	jsonStatus := h.convertToJSONReviewModerationModel(status)
+	h.setAuditAfter(c, jsonStatus)
+
+	h.setETag(c, h.getReviewVersion(status))
+	c.JSON(http.StatusOK, jsonStatus)
+}
+
+func (h *Handler) getRatingByBookAndID(ctx context.Context, bookID, ratingID uuid.UUID) (*models.RatingModel, error) {
+	ratings, err := h.ratingService.GetByBookID(ctx, bookID)
+	if err != nil && errors.Is(err, errs.ErrRatingDoesNotExists) {
+		return nil, weberrs.ErrReviewDoesNotExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rating := range ratings {
+		if rating.ID == ratingID {
+			return rating, nil
+		}
+	}
+
+	return nil, weberrs.ErrReviewDoesNotExists
+}
+
+func (h *Handler) filterVisibleRatings(ctx context.Context, ratings []*models.RatingModel) ([]*models.RatingModel, error) {
+	visibleRatings := make([]*models.RatingModel, 0, len(ratings))
+
+	for _, rating := range ratings {
+		state, err := h.reviewModerator.GetState(ctx, rating.ID)
+		if err != nil {
+			return nil, err
+		}
+		if state == moderation.ReviewStateVisible {
+			visibleRatings = append(visibleRatings, rating)
+		}
+	}
+
+	return visibleRatings, nil
+}
+
+func (h *Handler) convertArrayToJSONReviewModerationModels(statuses []*moderation.ReviewStatus) []*jsonmodels.JSONReviewModerationModel {
+	jsonStatuses := make([]*jsonmodels.JSONReviewModerationModel, len(statuses))
+	for i, status := range statuses {
+		jsonStatuses[i] = h.convertToJSONReviewModerationModel(status)
+	}
+
+	return jsonStatuses
+}
+
+func (h *Handler) convertToJSONReviewModerationModel(status *moderation.ReviewStatus) *jsonmodels.JSONReviewModerationModel {
+	reports := make([]*jsonmodels.JSONReviewReportModel, len(status.Reports))
+	for i, report := range status.Reports {
+		reports[i] = &jsonmodels.JSONReviewReportModel{
+			ReaderID:  report.ReaderID,
+			Reason:    report.Reason,
+			CreatedAt: report.CreatedAt,
+		}
+	}
+
+	decisions := make([]*jsonmodels.JSONModerationDecisionModel, len(status.Decisions))
+	for i, decision := range status.Decisions {
+		decisions[i] = &jsonmodels.JSONModerationDecisionModel{
+			ModeratorID:     decision.ModeratorID,
+			Action:          string(decision.Action),
+			Reason:          decision.Reason,
+			ResolvedReports: decision.ResolvedReports,
+			CreatedAt:       decision.CreatedAt,
+		}
+	}
+
+	return &jsonmodels.JSONReviewModerationModel{
+		Rating:       *h.convertToJSONRatingModel(&status.Rating),
+		State:        string(status.State),
+		FlaggedWords: status.FlaggedWords,
+		Reports:      reports,
+		Decisions:    decisions,
+		QueuedAt:     status.QueuedAt,
+		Version:      h.getReviewVersion(status),
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/oidc"
+	"net/http"
+	"time"
+)
+
+// oidcStateCookie binds a login to the browser which started it, so that a callback
+// with the state of another login, e.g. one started by an attacker, is rejected.
+const oidcStateCookie = "oidc_state"
+
+// @Summary Метод входа сотрудника через OIDC-провайдера
+// @Tags auth
+// @ID oidcLogin
+// @Param login_hint query string false "Учетная запись сотрудника у провайдера"
+// @Success 302 "Перенаправление на страницу входа провайдера, состояние входа сохраняется в cookie"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Failure 502 {object} dto.ProblemDetails "Провайдер недоступен"
+// @Router /api/v1/auth/oidc/login [get]
+func (h *Handler) oidcLogin(c *gin.Context) {
+	var inp jsondto.OIDCLoginInputDTO
+	if err := c.ShouldBindQuery(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	state, err := oidc.NewState()
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+	codeVerifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	authCodeURL, err := h.oidcProvider.AuthCodeURL(c.Request.Context(), state, nonce, oidc.NewCodeChallenge(codeVerifier), inp.LoginHint)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	login := &oidc.Login{Nonce: nonce, CodeVerifier: codeVerifier, ExpiresAt: time.Now().Add(h.oidcLoginTTL)}
+	if err = h.oidcLogins.Save(c.Request.Context(), state, login); err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setOIDCStateCookie(c, state, int(h.oidcLoginTTL.Seconds()))
+	c.Redirect(http.StatusFound, authCodeURL)
+}
+
+// @Summary Метод завершения входа сотрудника через OIDC-провайдера
+// @Tags auth
+// @ID oidcCallback
+// @Produce  json
+// @Param state query string true "Состояние, переданное провайдеру при входе"
+// @Param code query string false "Код авторизации"
+// @Param error query string false "Ошибка входа у провайдера"
+// @Success 202 {object} dto.TwoFactorChallengeOutputDTO "Требуется код второго фактора, обязательного для сотрудников"
+// @Failure 400 {object} dto.ProblemDetails "Недействительное или истекшее состояние входа, или оно не совпадает с cookie браузера"
+// @Failure 401 {object} dto.ProblemDetails "Провайдер отклонил вход"
+// @Failure 403 {object} dto.ProblemDetails "Группы сотрудника не дают роль"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Failure 502 {object} dto.ProblemDetails "Провайдер недоступен"
+// @Failure 503 {object} dto.ProblemDetails "Двухфакторная аутентификация недоступна"
+// @Router /api/v1/auth/oidc/callback [get]
+func (h *Handler) oidcCallback(c *gin.Context) {
+	var inp jsondto.OIDCCallbackInputDTO
+	if err := c.ShouldBindQuery(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	// the cookie is needed by one callback only
+	stateCookie, _ := c.Cookie(oidcStateCookie)
+	h.setOIDCStateCookie(c, "", -1)
+
+	res, session, err := h.signInWithOIDC(c.Request.Context(), &inp, stateCookie)
+	if err != nil && !errors.Is(err, weberrs.ErrOIDCProviderFailed) {
+		h.metrics.IncAuthFailure(h.getAuthFailureReason(err))
+	}
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	accountName := session.Email
+	if accountName == "" {
+		accountName = session.StaffID.String()
+	}
+
+	h.respondSignIn(c, session.StaffID, session.Role, accountName, res)
+}
+
+func (h *Handler) signInWithOIDC(ctx context.Context, inp *jsondto.OIDCCallbackInputDTO, stateCookie string) (*models.Tokens, *oidc.Session, error) {
+	if subtle.ConstantTimeCompare([]byte(stateCookie), []byte(inp.State)) != 1 {
+		return nil, nil, weberrs.ErrInvalidOIDCState
+	}
+
+	// the state is taken before anything else, so that it can not be reused after a failure
+	login, err := h.oidcLogins.Take(ctx, inp.State)
+	if err != nil {
+		return nil, nil, err
+	}
+	if inp.Error != "" {
+		return nil, nil, fmt.Errorf("%w: %s %s", weberrs.ErrInvalidOIDCCode, inp.Error, inp.ErrorDescription)
+	}
+
+	identity, err := h.oidcProvider.Exchange(ctx, inp.Code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role, ok := oidc.MapRole(identity.Groups, h.oidcGroupRoles)
+	if !ok {
+		return nil, nil, weberrs.ErrNoStaffRole
+	}
+	// the session would never pass the second factor
+	if h.twoFactor == nil {
+		return nil, nil, weberrs.ErrTwoFactorUnavailable
+	}
+
+	session := &oidc.Session{StaffID: oidc.GetStaffID(identity), Role: role, Email: identity.Email}
+	res, err := h.newStaffTokens(ctx, session)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res, session, nil
+}
+
+// setOIDCStateCookie sets the state of the login, a negative maxAge deletes the cookie.
+// The provider redirects back from its own site, so SameSite is Lax rather than Strict.
+func (h *Handler) setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     h.oidcCookiePath,
+		MaxAge:   maxAge,
+		Secure:   h.isOIDCCookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// newStaffTokens issues tokens of the session. The role is taken from the provider
+// only on sign-in, so a staff member removed from a group keeps the role until
+// the refresh token expires.
+func (h *Handler) newStaffTokens(ctx context.Context, session *oidc.Session) (*models.Tokens, error) {
+	accessToken, err := h.tokenManager.NewJWT(session.StaffID, session.Role, h.accessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := oidc.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session.ExpiresAt = time.Now().Add(h.refreshTokenTTL)
+	if err = h.staffSessions.Save(ctx, refreshToken, session); err != nil {
+		return nil, err
+	}
+
+	return &models.Tokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// refreshStaffTokens rotates the refresh token of a staff session, returning false
+// if the token belongs to a reader.
+func (h *Handler) refreshStaffTokens(ctx context.Context, refreshToken string) (*models.Tokens, bool, error) {
+	session, err := h.staffSessions.Take(ctx, refreshToken)
+	if errors.Is(err, weberrs.ErrInvalidToken) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+
+	res, err := h.newStaffTokens(ctx, session)
+
+	return res, true, err
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/nikitalystsev/BookSmart-services/pkg/auth"
+	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/config"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/oidc"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/oidcmock"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const (
+	testOIDCClientID     = "booksmart"
+	testOIDCClientSecret = "secret"
+	testOIDCCallbackPath = "/api/v1/auth/oidc/callback"
+	testOIDCRedirectURL  = "https://api.booksmart.example" + testOIDCCallbackPath
+	testLibrarianSubject = "librarian"
+	testUngroupedSubject = "ungrouped"
+)
+
+type oidcTestEnv struct {
+	router         http.Handler
+	providerClient *http.Client
+}
+
+func newOIDCTestEnv(t *testing.T) *oidcTestEnv {
+	t.Helper()
+
+	mock, err := oidcmock.NewServer(testOIDCClientID, testOIDCClientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.AddUser(oidcmock.User{Subject: testLibrarianSubject, Email: "librarian@booksmart.example", Groups: []string{"librarians"}})
+	mock.AddUser(oidcmock.User{Subject: testUngroupedSubject, Email: "guest@booksmart.example", Groups: []string{"guests"}})
+	provider := httptest.NewServer(mock)
+	t.Cleanup(provider.Close)
+
+	tokenManager, err := auth.NewTokenManager("test-signing-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Storage.DataDir = t.TempDir()
+	cfg.OIDC.IssuerURL = provider.URL
+	cfg.OIDC.ClientID = testOIDCClientID
+	cfg.OIDC.ClientSecret = testOIDCClientSecret
+	cfg.OIDC.RedirectURL = testOIDCRedirectURL
+	cfg.OIDC.GroupRoles = []oidc.GroupRole{{Group: "librarians", Role: "Librarian"}}
+	if err = cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &oidcTestEnv{
+		router: NewHandler(nil, nil, nil, nil, nil, tokenManager, time.Minute, time.Hour, WithConfig(cfg)).InitRoutes(),
+		// the redirects are followed by the tests, as a browser would do
+		providerClient: &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}},
+	}
+}
+
+// startLogin returns the authorization URL of the provider and the state cookie of the browser.
+func (env *oidcTestEnv) startLogin(t *testing.T, loginHint string) (*url.URL, *http.Cookie) {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/auth/oidc/login?login_hint="+loginHint, nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("got login status %d, want 302", w.Code)
+	}
+
+	authorizeURL, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oidcStateCookie {
+			return authorizeURL, cookie
+		}
+	}
+	t.Fatal("login sets no state cookie")
+
+	return nil, nil
+}
+
+// authorize returns the callback URL the provider redirects to.
+func (env *oidcTestEnv) authorize(t *testing.T, authorizeURL *url.URL) string {
+	t.Helper()
+
+	res, err := env.providerClient.Get(authorizeURL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	redirectURL, err := url.Parse(res.Header.Get("Location"))
+	if err != nil || redirectURL.Path != testOIDCCallbackPath {
+		t.Fatalf("got redirect to %q with status %d, want the callback", res.Header.Get("Location"), res.StatusCode)
+	}
+
+	return redirectURL.RequestURI()
+}
+
+func (env *oidcTestEnv) callback(callbackURL string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, callbackURL, nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+
+	env.router.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	env := newOIDCTestEnv(t)
+
+	authorizeURL, cookie := env.startLogin(t, testLibrarianSubject)
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != testOIDCCallbackPath {
+		t.Errorf("got state cookie %+v, want HttpOnly, Secure, SameSite=Lax on the callback path", cookie)
+	}
+	if cookie.Value != authorizeURL.Query().Get("state") {
+		t.Errorf("state cookie %q does not match the state of the login", cookie.Value)
+	}
+
+	w := env.callback(env.authorize(t, authorizeURL), cookie)
+
+	// staff must pass the second factor before getting tokens
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("got callback status %d, want 202: %s", w.Code, w.Body.String())
+	}
+	var challenge jsondto.TwoFactorChallengeOutputDTO
+	if err := json.Unmarshal(w.Body.Bytes(), &challenge); err != nil {
+		t.Fatal(err)
+	}
+	if challenge.ChallengeToken == "" || !challenge.IsEnrollmentRequired {
+		t.Errorf("got challenge %+v, want a token requiring enrollment", challenge)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oidcStateCookie && c.MaxAge >= 0 {
+			t.Errorf("callback keeps the state cookie %+v", c)
+		}
+	}
+}
+
+func TestOIDCCallbackRejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		wantStatus int
+		wantCode   string
+		// run completes a login and returns the response of the callback
+		run func(t *testing.T, env *oidcTestEnv) *httptest.ResponseRecorder
+	}{
+		{
+			name:       "wrong nonce",
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   "invalid_id_token",
+			run: func(t *testing.T, env *oidcTestEnv) *httptest.ResponseRecorder {
+				authorizeURL, cookie := env.startLogin(t, testLibrarianSubject)
+				params := authorizeURL.Query()
+				params.Set("nonce", "nonce-of-another-login")
+				authorizeURL.RawQuery = params.Encode()
+
+				return env.callback(env.authorize(t, authorizeURL), cookie)
+			},
+		},
+		{
+			name:       "reused state",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_oidc_state",
+			run: func(t *testing.T, env *oidcTestEnv) *httptest.ResponseRecorder {
+				authorizeURL, cookie := env.startLogin(t, testLibrarianSubject)
+				callbackURL := env.authorize(t, authorizeURL)
+				if w := env.callback(callbackURL, cookie); w.Code != http.StatusAccepted {
+					t.Fatalf("got first callback status %d, want 202", w.Code)
+				}
+
+				return env.callback(callbackURL, cookie)
+			},
+		},
+		{
+			name:       "no state cookie",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_oidc_state",
+			run: func(t *testing.T, env *oidcTestEnv) *httptest.ResponseRecorder {
+				authorizeURL, _ := env.startLogin(t, testLibrarianSubject)
+
+				return env.callback(env.authorize(t, authorizeURL), nil)
+			},
+		},
+		{
+			name:       "state cookie of another login",
+			wantStatus: http.StatusBadRequest,
+			wantCode:   "invalid_oidc_state",
+			run: func(t *testing.T, env *oidcTestEnv) *httptest.ResponseRecorder {
+				// the attacker makes the victim complete the login of the attacker
+				attackerURL, _ := env.startLogin(t, testLibrarianSubject)
+				_, victimCookie := env.startLogin(t, testLibrarianSubject)
+
+				return env.callback(env.authorize(t, attackerURL), victimCookie)
+			},
+		},
+		{
+			name:       "no mapped group",
+			wantStatus: http.StatusForbidden,
+			wantCode:   "no_staff_role",
+			run: func(t *testing.T, env *oidcTestEnv) *httptest.ResponseRecorder {
+				authorizeURL, cookie := env.startLogin(t, testUngroupedSubject)
+
+				return env.callback(env.authorize(t, authorizeURL), cookie)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.run(t, newOIDCTestEnv(t))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			var problem jsondto.ProblemDetails
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Code != tt.wantCode {
+				t.Errorf("got problem code %q, want %q", problem.Code, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/ratelimit"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	rateLimitLimitHeader     = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitResetHeader     = "RateLimit-Reset"
+	rateLimitPolicyHeader    = "RateLimit-Policy"
+	retryAfterHeader         = "Retry-After"
+)
+
+const (
+	RateLimitGroupAuth    = "auth"
+	RateLimitGroupCatalog = "catalog"
+	RateLimitGroupDefault = "default"
+)
+
+func getDefaultRateLimitPolicies() map[string]ratelimit.Policy {
+	return map[string]ratelimit.Policy{
+		RateLimitGroupAuth:    {Name: RateLimitGroupAuth, Limit: 10, Window: time.Minute},
+		RateLimitGroupCatalog: {Name: RateLimitGroupCatalog, Limit: 300, Window: time.Minute},
+		RateLimitGroupDefault: {Name: RateLimitGroupDefault, Limit: 120, Window: time.Minute},
+	}
+}
+
+// limitRate takes a token from the bucket of the client for the route group.
+// Authenticated readers are limited by ID, so readers behind one NAT do not share a bucket.
+func (h *Handler) limitRate(group string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy, ok := h.rateLimitPolicies[group]
+		if !ok {
+			policy = h.rateLimitPolicies[RateLimitGroupDefault]
+		}
+		if policy.Limit <= 0 || policy.Window <= 0 {
+			return
+		}
+
+		res, err := h.rateLimitStore.Take(c.Request.Context(), policy.Name+":"+h.getClientKey(c), policy)
+		if err != nil {
+			// the store is unavailable, limits are not enforced rather than failing all requests
+			h.logger.WithContext(c.Request.Context()).WithError(err).Warn("rate limit store failed")
+			return
+		}
+
+		c.Header(rateLimitLimitHeader, strconv.Itoa(res.Limit))
+		c.Header(rateLimitRemainingHeader, strconv.Itoa(res.Remaining))
+		c.Header(rateLimitResetHeader, h.formatSeconds(res.ResetAfter))
+		c.Header(rateLimitPolicyHeader, policy.Header())
+
+		if !res.Allowed {
+			c.Header(retryAfterHeader, h.formatSeconds(res.RetryAfter))
+			h.abortWithError(c, weberrs.ErrRateLimitExceeded)
+			return
+		}
+	}
+}
+
+// getClientKey identifies the client by API key, reader ID or, for anonymous requests, by IP.
+func (h *Handler) getClientKey(c *gin.Context) string {
+	if keyID, ok := c.Get(apiKeyID); ok {
+		return "apikey:" + keyID.(string)
+	}
+
+	if readerID, ok := c.Get(ID); ok && readerID != "" {
+		return "reader:" + readerID.(string)
+	}
+
+	if readerID, _, err := h.parseAuthHeader(c); err == nil && readerID != "" {
+		return "reader:" + readerID
+	}
+
+	return "ip:" + c.ClientIP()
+}
+
+func (h *Handler) formatSeconds(duration time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(duration.Seconds())))
+}
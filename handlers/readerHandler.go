@@ -10,6 +10,7 @@ import (
 	"github.com/nikitalystsev/BookSmart-services/pkg/hash"
 	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
 	jsonmodels "github.com/nikitalystsev/BookSmart-web-api/core/models"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
 	"net/http"
 	"time"
 )
@@ -20,15 +21,19 @@ import (
 // @Accept  json
 // @Produce  json
 // @Param input body dto.SignUpInputDTO true "DTO c данными пользователя"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор запроса с тем же ключом возвращает первый ответ"
 // @Success 201 "Успешное создание пользователя"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 409 {object} dto.ErrorResponse "Пользователь уже существует"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 409 {object} dto.ProblemDetails "Пользователь уже существует"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/auth/sign-up [post]
 func (h *Handler) signUp(c *gin.Context) {
-	var inp dto.SignUpInputDTO
-	if err := c.BindJSON(&inp); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	var inp jsondto.SignUpInputDTO
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
 		return
 	}
 
@@ -38,19 +43,20 @@ func (h *Handler) signUp(c *gin.Context) {
 		PhoneNumber: inp.PhoneNumber,
 		Age:         inp.Age,
 		Password:    inp.Password,
-		Role:        "Reader",
+		Role:        readerRole,
 	}
 
 	err := h.readerService.SignUp(c.Request.Context(), &reader)
-	if err != nil && errors.Is(err, errs.ErrReaderAlreadyExist) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
+	snapshot := h.convertToJSONReaderModel(&reader)
+	snapshot.Password = "" // passwords are not kept in the audit log
+	setAuditResourceID(c, reader.ID)
+	h.setAuditAfter(c, snapshot)
+
 	c.Status(http.StatusCreated)
 }
 
@@ -61,48 +67,39 @@ func (h *Handler) signUp(c *gin.Context) {
 // @Produce  json
 // @Param input body dto.SignInInputDTO true "DTO c номером телефона и паролем пользователя"
 // @Success 200 {object} dto.SignInOutputDTO "Успешный вход пользователя"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 404 {object} dto.ErrorResponse "Читателя не существует"
-// @Failure 409 {object} dto.ErrorResponse "Неверный логин или пароль"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Success 202 {object} dto.TwoFactorChallengeOutputDTO "Требуется код второго фактора"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 404 {object} dto.ProblemDetails "Читателя не существует"
+// @Failure 409 {object} dto.ProblemDetails "Неверный логин или пароль"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Failure 503 {object} dto.ProblemDetails "Двухфакторная аутентификация недоступна, сотрудники не могут войти"
 // @Router /api/v1/auth/sign-in [post]
 func (h *Handler) signIn(c *gin.Context) {
-	var inp dto.SignInInputDTO
-	if err := c.BindJSON(&inp); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	var inp jsondto.SignInInputDTO
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
 		return
 	}
 
 	res, err := h.readerService.SignIn(c.Request.Context(), inp.PhoneNumber, inp.Password)
-	if err != nil && errors.Is(err, errs.ErrReaderDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, hash.ErrInvalidLoginOrPassword) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrReaderObjectIsNil) {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
+	if err != nil && (errors.Is(err, errs.ErrReaderDoesNotExists) || errors.Is(err, hash.ErrInvalidLoginOrPassword)) {
+		h.metrics.IncAuthFailure(h.getAuthFailureReason(err))
 	}
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
-	readerID, err := h.getReaderIDFromAccessToken(res.AccessToken)
+	readerID, role, err := h.getReaderDataFromAccessToken(res.AccessToken)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, dto.SignInOutputDTO{
-		ReaderID:     readerID,
-		AccessToken:  res.AccessToken,
-		RefreshToken: res.RefreshToken,
-		ExpiredAt:    time.Now().Add(h.accessTokenTTL).UnixMilli(),
-	})
+	h.respondSignIn(c, readerID, role, inp.PhoneNumber, res)
 }
 
 // @Summary Метод обновления токенов
@@ -112,29 +109,26 @@ func (h *Handler) signIn(c *gin.Context) {
 // @Produce  json
 // @Param input body dto.RefreshTokenInputDTO true "Токен обновления"
 // @Success 200 {object} dto.RefreshTokenOutputDTO "Успешное обновление токенов"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 404 {object} dto.ErrorResponse "Читателя не существует"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 404 {object} dto.ProblemDetails "Читателя не существует"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/auth/refresh [post]
 func (h *Handler) refresh(c *gin.Context) {
-	var inp dto.RefreshTokenInputDTO
-	if err := c.BindJSON(&inp); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	var inp jsondto.RefreshTokenInputDTO
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
 		return
 	}
 
-	var (
-		res *models.Tokens
-		err error
-	)
-
-	res, err = h.readerService.RefreshTokens(c.Request.Context(), inp.RefreshToken)
-	if err != nil && errors.Is(err, errs.ErrReaderDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
+	res, isStaff, err := h.refreshStaffTokens(c.Request.Context(), inp.RefreshToken)
+	if !isStaff {
+		res, err = h.readerService.RefreshTokens(c.Request.Context(), inp.RefreshToken)
 	}
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
@@ -153,53 +147,49 @@ func (h *Handler) refresh(c *gin.Context) {
 // @Produce  json
 // @Param id path string true "Идентификатор читателя"
 // @Success 200 {object} models.JSONReaderModel "Успешное получение читателя"
-// @Failure 401 {object} dto.ErrorResponse "Неавторизованный пользователь"
-// @Failure 403 {object} dto.ErrorResponse "Доступ запрещен"
-// @Failure 404 {object} dto.ErrorResponse "Читатель не найден"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Читатель не найден"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/readers/{id} [get]
 func (h *Handler) getReaderByID(c *gin.Context) {
 	readerID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
 	isReader, err := isReaderID(c, readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if !isReader {
-		c.AbortWithStatusJSON(http.StatusForbidden, jsondto.ErrorResponse{ErrorMsg: "access denied"})
+		h.abortWithError(c, weberrs.ErrAccessDenied)
 		return
 	}
 
 	reader, err := h.readerService.GetByID(c.Request.Context(), readerID)
-	if err != nil && errors.Is(err, errs.ErrReaderDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, h.convertToJSONReaderModel(reader))
 }
 
-func (h *Handler) getReaderIDFromAccessToken(accessToken string) (uuid.UUID, error) {
-	readerIDStr, _, err := h.tokenManager.Parse(accessToken)
+func (h *Handler) getReaderDataFromAccessToken(accessToken string) (uuid.UUID, string, error) {
+	readerIDStr, role, err := h.tokenManager.Parse(accessToken)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, "", err
 	}
 
 	readerID, err := uuid.Parse(readerIDStr)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, "", err
 	}
 
-	return readerID, nil
+	return readerID, role, nil
 }
 
 func (h *Handler) convertToJSONReaderModel(reader *models.ReaderModel) *jsonmodels.JSONReaderModel {
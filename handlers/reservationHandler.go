@@ -1,16 +1,15 @@
 package handlers
 
 import (
-	"context"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/nikitalystsev/BookSmart-services/core/dto"
 	"github.com/nikitalystsev/BookSmart-services/core/models"
 	"github.com/nikitalystsev/BookSmart-services/errs"
 	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
 	jsonmodels "github.com/nikitalystsev/BookSmart-web-api/core/models"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
 	"net/http"
 )
 
@@ -22,84 +21,65 @@ import (
 // @Produce  json
 // @Param id path string true "Идентификатор читателя"
 // @Param input body dto.ReservationInputDTO true "Идентификатор книги"
+// @Param Idempotency-Key header string false "Ключ идемпотентности: повтор запроса с тем же ключом возвращает первый ответ"
+// @Param X-API-Key header string false "API-ключ интеграции с разрешением reservations:write вместо токена"
 // @Success 201 "Успешное бронирование книги"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 401 {object} dto.ErrorResponse "Неавторизованный пользователь"
-// @Failure 403 {object} dto.ErrorResponse "доступ запрещен"
-// @Failure 404 {object} dto.ErrorResponse "Нет читательского билета или книги"
-// @Failure 409 {object} dto.ErrorResponse "Бронирование невозможно из-за нарушения некоторых условий"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Нет читательского билета или книги"
+// @Failure 409 {object} dto.ProblemDetails "Бронирование невозможно из-за нарушения некоторых условий (в т.ч. билет заблокирован)"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/readers/{id}/reservations [post]
 func (h *Handler) reserveBook(c *gin.Context) {
 	readerID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
 	isReader, err := isReaderID(c, readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if !isReader {
-		c.AbortWithStatusJSON(http.StatusForbidden, jsondto.ErrorResponse{ErrorMsg: "access denied"})
+		h.abortWithError(c, weberrs.ErrAccessDenied)
 		return
 	}
 
-	var inp dto.ReservationInputDTO
-	if err = c.BindJSON(&inp); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	var inp jsondto.ReservationInputDTO
+	if err = c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
 		return
 	}
 
-	err = h.reservationService.Create(c.Request.Context(), readerID, inp.BookID)
-	if err != nil && errors.Is(err, errs.ErrReaderDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	if err = h.checkReaderLibCardIsNotBlocked(c.Request.Context(), readerID); err != nil && errors.Is(err, weberrs.ErrLibCardIsBlocked) {
+		h.metrics.IncReservationOutcome(h.getReservationOutcome(err))
+		h.abortWithError(c, err)
 		return
 	}
-	if err != nil && errors.Is(err, errs.ErrReaderHasExpiredBooks) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrReservationsLimitExceeded) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrLibCardDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrLibCardIsInvalid) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrBookDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrBookNoCopiesNum) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrUniqueBookNotReserved) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrReservationAgeLimit) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	if err != nil {
+		h.abortWithError(c, err)
 		return
 	}
 
-	if err != nil && errors.Is(err, errs.ErrReservationAlreadyExists) {
-		c.AbortWithStatusJSON(http.StatusConflict, jsondto.ErrorResponse{ErrorMsg: err.Error()})
-		return
-	}
+	bookID := uuid.MustParse(inp.BookID)
+
+	err = h.reservationService.Create(c.Request.Context(), readerID, bookID)
+	h.metrics.IncReservationOutcome(h.getReservationOutcome(err))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
+	h.invalidateBookCache(bookID) // бронирование уменьшает число копий книги
+
+	h.setReservationAuditAfter(c, readerID, bookID)
+
 	c.Status(http.StatusCreated)
 }
 
@@ -112,90 +92,85 @@ func (h *Handler) reserveBook(c *gin.Context) {
 // @Param id path string true "Идентификатор читателя"
 // @Param reservation_id path string true "Идентификатор брони"
 // @Param input body dto.ReservationExtentionPeriodDaysInputDTO true "Срок продления брони"
-// @Success 200 "Успешное продление брони"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 401 {object} dto.ErrorResponse "Неавторизованный пользователь"
-// @Failure 404 {object} dto.ErrorResponse "Бронь не найдена"
-// @Failure 409 {object} dto.ErrorResponse "Нарушение каких либо условий для успешного продления брони"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Param If-Match header string true "ETag брони, полученный при ее чтении"
+// @Param X-API-Key header string false "API-ключ интеграции с разрешением reservations:write вместо токена"
+// @Success 200 "Успешное продление брони, ETag содержит новую версию"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 404 {object} dto.ProblemDetails "Бронь не найдена"
+// @Failure 409 {object} dto.ProblemDetails "Нарушение каких либо условий для успешного продления брони"
+// @Failure 412 {object} dto.ProblemDetails "Бронь была изменена, версия не совпадает с If-Match"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 428 {object} dto.ProblemDetails "Не передан заголовок If-Match"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/readers/{id}/reservations/{reservation_id} [patch]
 func (h *Handler) updateReservation(c *gin.Context) {
 	readerID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
 	reservationID, err := uuid.Parse(c.Param("reservation_id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "reservation_id", err)
 		return
 	}
 
 	isReader, err := isReaderID(c, readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if !isReader {
-		c.AbortWithStatusJSON(http.StatusForbidden, jsondto.ErrorResponse{ErrorMsg: "access denied"})
+		h.abortWithError(c, weberrs.ErrAccessDenied)
 		return
 	}
 
-	var inp dto.ReservationExtentionPeriodDaysInputDTO
-	if err = c.BindJSON(&inp); err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	var inp jsondto.ReservationExtentionPeriodDaysInputDTO
+	if err = c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
 		return
 	}
 
+	unlock := h.resourceLocks.Lock("reservation:" + reservationID.String())
+	defer unlock()
+
 	reservation, err := h.reservationService.GetByID(c.Request.Context(), reservationID)
-	if err != nil && errors.Is(err, errs.ErrReservationDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, err.Error())
-		return
-	}
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		h.abortWithError(c, err)
 		return
 	}
 
-	err = h.reservationService.Update(c.Request.Context(), reservation, inp.ExtentionPeriodDays)
-	if err != nil && errors.Is(err, errs.ErrReservationObjectIsNil) {
-		c.AbortWithStatusJSON(http.StatusBadRequest, err.Error())
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrLibCardDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, err.Error())
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrLibCardIsInvalid) {
-		c.AbortWithStatusJSON(http.StatusConflict, err.Error())
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrReaderHasExpiredBooks) {
-		c.AbortWithStatusJSON(http.StatusConflict, err.Error())
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrReservationIsAlreadyClosed) {
-		c.AbortWithStatusJSON(http.StatusConflict, err.Error())
-		return
-	}
-	if err != nil && errors.Is(err, errs.ErrReservationIsAlreadyExpired) {
-		c.AbortWithStatusJSON(http.StatusConflict, err.Error())
+	if err = h.checkIfMatch(c, h.getReservationVersion(reservation), true); err != nil {
+		h.abortWithError(c, err)
 		return
 	}
-	if err != nil && errors.Is(err, errs.ErrReservationIsAlreadyExtended) {
-		c.AbortWithStatusJSON(http.StatusConflict, err.Error())
+
+	if err = h.checkReaderLibCardIsNotBlocked(c.Request.Context(), readerID); err != nil {
+		h.abortWithError(c, err)
 		return
 	}
-	if err != nil && errors.Is(err, errs.ErrRareAndUniqueBookNotExtended) {
-		c.AbortWithStatusJSON(http.StatusConflict, err.Error())
+
+	h.setAuditBefore(c, h.convertToJSONReservationModel(reservation))
+
+	err = h.reservationService.Update(c.Request.Context(), reservation, inp.ExtentionPeriodDays)
+	if err != nil {
+		h.abortWithError(c, err)
 		return
 	}
+
+	reservation, err = h.reservationService.GetByID(c.Request.Context(), reservationID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		h.abortWithError(c, err)
 		return
 	}
 
+	h.setAuditAfter(c, h.convertToJSONReservationModel(reservation))
+
+	h.setETag(c, h.getReservationVersion(reservation))
 	c.Status(http.StatusOK)
 }
 
@@ -207,43 +182,43 @@ func (h *Handler) updateReservation(c *gin.Context) {
 // @Produce  json
 // @Param id path string true "Идентификатор читателя"
 // @Success 200 {array} dto.ReservationOutputDTO "Успешное получение броней"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 401 {object} dto.ErrorResponse "Неавторизованный пользователь"
-// @Failure 403 {object} dto.ErrorResponse "Доступ запрещен"
-// @Failure 404 {object} dto.ErrorResponse "Нет броней"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails "Нет броней"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/readers/{id}/reservations [get]
 func (h *Handler) getReservationsByReaderID(c *gin.Context) {
 	readerID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 
 	isReader, err := isReaderID(c, readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if !isReader {
-		c.AbortWithStatusJSON(http.StatusForbidden, jsondto.ErrorResponse{ErrorMsg: "access denied"})
+		h.abortWithError(c, weberrs.ErrAccessDenied)
 		return
 	}
 
 	var reservations []*models.ReservationModel
 	reservations, err = h.reservationService.GetAllReservationsByReaderID(c.Request.Context(), readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if len(reservations) == 0 {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: "reservation not found"})
+		h.abortWithError(c, errs.ErrReservationDoesNotExists)
 		return
 	}
 
-	reservationOutputDTOs, err := h.copyReservationModelsToReservationOutputDTOs(c.Request.Context(), reservations)
+	reservationOutputDTOs, err := h.copyReservationModelsToReservationOutputDTOs(c, reservations)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
@@ -259,78 +234,104 @@ func (h *Handler) getReservationsByReaderID(c *gin.Context) {
 // @Param id path string true "Идентификатор читателя"
 // @Param reservation_id path string true "Идентификатор брони"
 // @Success 200 {object} dto.ReservationOutputDTO "Успешное получение брони"
-// @Failure 400 {object} dto.ErrorResponse "Неверный запрос"
-// @Failure 401 {object} dto.ErrorResponse "Неавторизованный пользователь"
-// @Failure 403 {object} dto.ErrorResponse "Доступ запрещен"
-// @Failure 404 {object} dto.ErrorResponse " Бронь не найдена"
-// @Failure 500 {object} dto.ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 403 {object} dto.ProblemDetails "Доступ запрещен"
+// @Failure 404 {object} dto.ProblemDetails " Бронь не найдена"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
 // @Router /api/v1/readers/{id}/reservations/{reservation_id} [get]
 func (h *Handler) getReservationByID(c *gin.Context) {
 	readerID, err := uuid.Parse(c.Param("id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "id", err)
 		return
 	}
 	reservationID, err := uuid.Parse(c.Param("reservation_id"))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithInvalidParam(c, "reservation_id", err)
 		return
 	}
 
 	isReader, err := isReaderID(c, readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusBadRequest, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 	if !isReader {
-		c.AbortWithStatusJSON(http.StatusForbidden, jsondto.ErrorResponse{ErrorMsg: "access denied"})
+		h.abortWithError(c, weberrs.ErrAccessDenied)
 		return
 	}
 
 	reservations, err := h.reservationService.GetByID(c.Request.Context(), reservationID)
-	if err != nil && errors.Is(err, errs.ErrReservationDoesNotExists) {
-		c.AbortWithStatusJSON(http.StatusNotFound, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+	if err != nil {
+		h.abortWithError(c, err)
 		return
 	}
+
+	reservationOutputDTOs, err := h.copyReservationModelsToReservationOutputDTOs(c, []*models.ReservationModel{reservations})
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.abortWithError(c, err)
 		return
 	}
 
-	reservationOutputDTO, err := h.copyReservationModelToReservationOutputDTO(c.Request.Context(), reservations)
+	h.setETag(c, reservationOutputDTOs[0].Version)
+	c.JSON(http.StatusOK, reservationOutputDTOs[0])
+}
+
+// setReservationAuditAfter keeps the reservation created by the request. The service does not
+// return it, so it is the latest reservation of the book by the reader.
+func (h *Handler) setReservationAuditAfter(c *gin.Context, readerID, bookID uuid.UUID) {
+	reservations, err := h.reservationService.GetAllReservationsByReaderID(c.Request.Context(), readerID)
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, jsondto.ErrorResponse{ErrorMsg: err.Error()})
+		h.logger.WithContext(c.Request.Context()).WithError(err).Warn("reservation audit snapshot failed")
 		return
 	}
 
-	c.JSON(http.StatusOK, reservationOutputDTO)
+	var created *models.ReservationModel
+	for _, reservation := range reservations {
+		if reservation.BookID == bookID && (created == nil || reservation.IssueDate.After(created.IssueDate)) {
+			created = reservation
+		}
+	}
+	if created == nil {
+		return
+	}
+
+	setAuditResourceID(c, created.ID)
+	h.setAuditAfter(c, h.convertToJSONReservationModel(created))
 }
 
-func (h *Handler) copyReservationModelToReservationOutputDTO(ctx context.Context, reservation *models.ReservationModel) (*dto.ReservationOutputDTO, error) {
-	book, err := h.bookService.GetByID(ctx, reservation.BookID)
-	if err != nil && !errors.Is(err, errs.ErrBookDoesNotExists) {
-		return nil, err
-	}
+func (h *Handler) copyReservationModelToReservationOutputDTO(reservation *models.ReservationModel, book *models.BookModel) (*jsondto.ReservationOutputDTO, error) {
 	if book == nil {
 		return nil, errs.ErrBookDoesNotExists
 	}
 
-	reservationOutputDTO := dto.ReservationOutputDTO{
+	reservationOutputDTO := jsondto.ReservationOutputDTO{
 		ID:                 reservation.ID,
 		BookTitleAndAuthor: fmt.Sprintf("%s; %s", book.Title, book.Author),
 		IssueDate:          reservation.IssueDate,
 		ReturnDate:         reservation.ReturnDate,
 		State:              reservation.State,
+		Version:            h.getReservationVersion(reservation),
 	}
 
 	return &reservationOutputDTO, nil
 }
 
-func (h *Handler) copyReservationModelsToReservationOutputDTOs(ctx context.Context, reservations []*models.ReservationModel) ([]*dto.ReservationOutputDTO, error) {
-	reservationOutputDTOs := make([]*dto.ReservationOutputDTO, len(reservations))
+func (h *Handler) copyReservationModelsToReservationOutputDTOs(c *gin.Context, reservations []*models.ReservationModel) ([]*jsondto.ReservationOutputDTO, error) {
+	bookIDs := make([]uuid.UUID, len(reservations))
+	for i, reservation := range reservations {
+		bookIDs[i] = reservation.BookID
+	}
+
+	books, err := h.getLoaders(c).books.LoadMany(c.Request.Context(), bookIDs)
+	if err != nil {
+		return nil, err
+	}
 
+	reservationOutputDTOs := make([]*jsondto.ReservationOutputDTO, len(reservations))
 	for i, reservation := range reservations {
-		outputDTO, err := h.copyReservationModelToReservationOutputDTO(ctx, reservation)
+		outputDTO, err := h.copyReservationModelToReservationOutputDTO(reservation, books[reservation.BookID])
 		if err != nil {
 			return nil, err
 		}
@@ -356,5 +357,6 @@ func (h *Handler) convertToJSONReservationModel(reservation *models.ReservationM
 		IssueDate:  reservation.IssueDate,
 		ReturnDate: reservation.ReturnDate,
 		State:      reservation.State,
+		Version:    h.getReservationVersion(reservation),
 	}
 }
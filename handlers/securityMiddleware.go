@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+const (
+	strictTransportSecurityHeader = "Strict-Transport-Security"
+	contentTypeOptionsHeader      = "X-Content-Type-Options"
+	frameOptionsHeader            = "X-Frame-Options"
+	referrerPolicyHeader          = "Referrer-Policy"
+	contentSecurityPolicyHeader   = "Content-Security-Policy"
+	contentTypeHeader             = "Content-Type"
+
+	jsonMediaType = "application/json"
+
+	// apiContentSecurityPolicy forbids browsers to run anything from JSON, PDF and PNG responses
+	apiContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+	// swaggerContentSecurityPolicy allows Swagger UI to run its own scripts and styles,
+	// the page initializes the UI by an inline script
+	swaggerContentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; frame-ancestors 'none'"
+)
+
+// setSecurityHeaders forbids browsers to sniff content types, to frame responses,
+// to run their content and to leak URLs of the API in the Referer header.
+// HSTS is sent over HTTPS only, since browsers ignore it over plain HTTP.
+func (h *Handler) setSecurityHeaders(c *gin.Context) {
+	header := c.Writer.Header()
+	header.Set(contentTypeOptionsHeader, "nosniff")
+	header.Set(frameOptionsHeader, "DENY")
+	header.Set(referrerPolicyHeader, "no-referrer")
+	header.Set(contentSecurityPolicyHeader, apiContentSecurityPolicy)
+
+	if c.Request.TLS != nil && h.hstsMaxAge > 0 {
+		header.Set(strictTransportSecurityHeader, fmt.Sprintf("max-age=%d; includeSubDomains", int(h.hstsMaxAge.Seconds())))
+	}
+}
+
+func (h *Handler) setSwaggerSecurityHeaders(c *gin.Context) {
+	c.Header(contentSecurityPolicyHeader, swaggerContentSecurityPolicy)
+}
+
+// limitBodySize rejects bodies over the limit, announced by Content-Length
+// at once and chunked ones as soon as the limit is read while binding.
+func (h *Handler) limitBodySize(c *gin.Context) {
+	if c.Request.ContentLength > h.maxBodySize {
+		h.abortWithBindError(c, &http.MaxBytesError{Limit: h.maxBodySize})
+		return
+	}
+
+	if c.Request.Body != nil {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxBodySize)
+	}
+}
+
+// limitDuration sets the deadline of the request context, so that service calls
+// are cancelled when the route takes longer than its timeout.
+func (h *Handler) limitDuration(c *gin.Context) {
+	timeout, ok := h.routeTimeouts[c.Request.Method+" "+c.FullPath()]
+	if !ok {
+		timeout = h.requestTimeout
+	}
+	if timeout <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+		h.abortWithError(c, context.DeadlineExceeded)
+	}
+}
+
+// requireJSONBody rejects requests changing resources with a body of a type other than JSON.
+func (h *Handler) requireJSONBody(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return
+	}
+	if c.Request.ContentLength == 0 {
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader(contentTypeHeader))
+	if err != nil || (mediaType != jsonMediaType && !strings.HasSuffix(mediaType, "+json")) {
+		h.abortWithError(c, weberrs.ErrUnsupportedMediaType)
+		return
+	}
+}
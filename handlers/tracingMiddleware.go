@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/logging"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+)
+
+// traceRequest starts the server span of the request. The parent is taken from
+// the W3C traceparent header, so traces continue those of the calling service.
+func (h *Handler) traceRequest(c *gin.Context) {
+	if h.isProbeRoute(c.FullPath()) {
+		return
+	}
+
+	ctx := h.propagator.Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+
+	route := c.FullPath()
+	if route == "" {
+		route = "unmatched"
+	}
+
+	ctx, span := h.tracer.Start(ctx, c.Request.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", c.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", c.Request.URL.Path),
+			attribute.String("client.address", c.ClientIP()),
+			attribute.String("request.id", logging.RequestIDFromContext(c.Request.Context())),
+		),
+	)
+	defer span.End()
+
+	h.propagator.Inject(ctx, propagation.HeaderCarrier(c.Writer.Header()))
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+
+	if role, ok := c.Get(Role); ok && role != "" {
+		span.SetAttributes(attribute.String("enduser.role", fmt.Sprint(role)))
+	}
+	if len(c.Errors) > 0 {
+		span.RecordError(c.Errors.Last())
+	}
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/dto"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"github.com/nikitalystsev/BookSmart-services/errs"
+	jsondto "github.com/nikitalystsev/BookSmart-web-api/core/dto"
+	jsonmodels "github.com/nikitalystsev/BookSmart-web-api/core/models"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/totp"
+	"net/http"
+	"time"
+)
+
+// @Summary Метод подключения второго фактора при входе сотрудника
+// @Tags auth
+// @ID signInEnrollTwoFactor
+// @Accept  json
+// @Produce  json
+// @Param input body dto.TwoFactorChallengeInputDTO true "Токен запроса второго фактора"
+// @Success 200 {object} dto.TwoFactorEnrollmentOutputDTO "Секрет и URI для QR-кода приложения-аутентификатора"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Недействительный или истекший токен запроса"
+// @Failure 409 {object} dto.ProblemDetails "Двухфакторная аутентификация уже включена"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/auth/sign-in/2fa/enroll [post]
+func (h *Handler) signInEnrollTwoFactor(c *gin.Context) {
+	var inp jsondto.TwoFactorChallengeInputDTO
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	challenge, err := h.twoFactorChallenges.Take(ctx, inp.ChallengeToken)
+	if err != nil {
+		h.metrics.IncAuthFailure(h.getAuthFailureReason(err))
+		h.abortWithError(c, err)
+		return
+	}
+
+	// the challenge is verified by the code of the enrolled app afterwards
+	if err = h.twoFactorChallenges.Save(ctx, inp.ChallengeToken, challenge); err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	setAuditActor(c, challenge.UserID, challenge.Role)
+	h.setTwoFactorAuditSnapshot(c, challenge.UserID, challenge.Role, h.setAuditBefore)
+
+	enrollment, err := h.twoFactor.Enroll(ctx, challenge.UserID, h.totpIssuer, challenge.AccountName)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setTwoFactorAuditSnapshot(c, challenge.UserID, challenge.Role, h.setAuditAfter)
+
+	c.JSON(http.StatusOK, jsondto.TwoFactorEnrollmentOutputDTO{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.ProvisioningURI,
+	})
+}
+
+// @Summary Метод проверки второго фактора при входе
+// @Tags auth
+// @ID signInVerifyTwoFactor
+// @Accept  json
+// @Produce  json
+// @Param input body dto.TwoFactorVerifyInputDTO true "Токен запроса второго фактора и код приложения или код восстановления"
+// @Success 200 {object} dto.TwoFactorSignInOutputDTO "Успешный вход пользователя"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неверный код или недействительный токен запроса"
+// @Failure 409 {object} dto.ProblemDetails "Подключение второго фактора не начато"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/auth/sign-in/2fa/verify [post]
+func (h *Handler) signInVerifyTwoFactor(c *gin.Context) {
+	var inp jsondto.TwoFactorVerifyInputDTO
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	challenge, err := h.twoFactorChallenges.Take(ctx, inp.ChallengeToken)
+	if err != nil {
+		h.metrics.IncAuthFailure(h.getAuthFailureReason(err))
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setTwoFactorAuditSnapshot(c, challenge.UserID, challenge.Role, h.setAuditBefore)
+
+	recoveryCodes, err := h.verifyChallenge(ctx, challenge, inp.Code)
+	if errors.Is(err, weberrs.ErrInvalidTwoFactorCode) {
+		h.metrics.IncAuthFailure(h.getAuthFailureReason(err))
+		challenge.Attempts++
+	}
+	if err != nil {
+		// the challenge is dropped after too many wrong codes, so that the password is needed again
+		if challenge.Attempts < totp.MaxChallengeAttempts {
+			if saveErr := h.twoFactorChallenges.Save(ctx, inp.ChallengeToken, challenge); saveErr != nil {
+				h.logger.WithContext(ctx).WithError(saveErr).Warn("two-factor challenge saving failed")
+			}
+		}
+		h.abortWithError(c, err)
+		return
+	}
+
+	// recovery codes are issued when the code completes the enrolment
+	if recoveryCodes != nil {
+		setAuditRoute(c, auditRoute{Action: "two_factor.confirm", ResourceType: "two_factor"})
+		setAuditActor(c, challenge.UserID, challenge.Role)
+		h.setTwoFactorAuditSnapshot(c, challenge.UserID, challenge.Role, h.setAuditAfter)
+	}
+
+	accessToken, err := h.tokenManager.NewJWT(challenge.UserID, challenge.Role, h.accessTokenTTL)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, jsondto.TwoFactorSignInOutputDTO{
+		ReaderID:      challenge.UserID,
+		AccessToken:   accessToken,
+		RefreshToken:  challenge.RefreshToken,
+		ExpiredAt:     time.Now().Add(h.accessTokenTTL).UnixMilli(),
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// verifyChallenge checks the code of the user, completing a pending enrolment.
+func (h *Handler) verifyChallenge(ctx context.Context, challenge *totp.Challenge, code string) ([]string, error) {
+	status, err := h.twoFactor.GetStatus(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if status.IsEnabled {
+		return nil, h.twoFactor.Verify(ctx, challenge.UserID, code)
+	}
+	if status.IsEnrollmentPending {
+		return h.twoFactor.Confirm(ctx, challenge.UserID, code)
+	}
+
+	return nil, weberrs.ErrTwoFactorEnrollmentNotStarted
+}
+
+// respondSignIn responds with the tokens of the first sign-in step, unless the user
+// has to enter a code, then the tokens are kept until the code is verified.
+// Without the factors staff can not sign in, while readers sign in with the password.
+func (h *Handler) respondSignIn(c *gin.Context, userID uuid.UUID, role, accountName string, tokens *models.Tokens) {
+	ctx := c.Request.Context()
+
+	status := &totp.Status{}
+	if h.twoFactor != nil {
+		var err error
+		if status, err = h.twoFactor.GetStatus(ctx, userID); err != nil {
+			h.abortWithError(c, err)
+			return
+		}
+	} else if isTwoFactorRequired(role) {
+		h.abortWithError(c, weberrs.ErrTwoFactorUnavailable)
+		return
+	}
+
+	if !status.IsEnabled && !isTwoFactorRequired(role) {
+		c.JSON(http.StatusOK, dto.SignInOutputDTO{
+			ReaderID:     userID,
+			AccessToken:  tokens.AccessToken,
+			RefreshToken: tokens.RefreshToken,
+			ExpiredAt:    time.Now().Add(h.accessTokenTTL).UnixMilli(),
+		})
+		return
+	}
+
+	challengeToken, err := totp.NewChallengeToken()
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+	challenge := &totp.Challenge{
+		UserID:       userID,
+		Role:         role,
+		AccountName:  accountName,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    time.Now().Add(h.challengeTTL),
+	}
+	if err = h.twoFactorChallenges.Save(ctx, challengeToken, challenge); err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, jsondto.TwoFactorChallengeOutputDTO{
+		ChallengeToken:       challengeToken,
+		IsEnrollmentRequired: !status.IsEnabled,
+		ExpiredAt:            challenge.ExpiresAt.UnixMilli(),
+	})
+}
+
+// @Summary Метод получения состояния двухфакторной аутентификации пользователя
+// @Security ApiKeyAuth
+// @Tags auth
+// @ID getTwoFactorStatus
+// @Produce  json
+// @Success 200 {object} models.JSONTwoFactorStatusModel "Успешное получение состояния"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/auth/2fa [get]
+func (h *Handler) getTwoFactorStatus(c *gin.Context) {
+	userID, role, err := getReaderData(c)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	status, err := h.getJSONTwoFactorStatusModel(c.Request.Context(), userID, role)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// @Summary Метод начала подключения двухфакторной аутентификации
+// @Security ApiKeyAuth
+// @Tags auth
+// @ID enrollTwoFactor
+// @Produce  json
+// @Success 200 {object} dto.TwoFactorEnrollmentOutputDTO "Секрет и URI для QR-кода приложения-аутентификатора"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь"
+// @Failure 409 {object} dto.ProblemDetails "Двухфакторная аутентификация уже включена"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/auth/2fa/enroll [post]
+func (h *Handler) enrollTwoFactor(c *gin.Context) {
+	userID, role, err := getReaderData(c)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	accountName, err := h.getTwoFactorAccountName(c.Request.Context(), userID)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setTwoFactorAuditSnapshot(c, userID, role, h.setAuditBefore)
+
+	enrollment, err := h.twoFactor.Enroll(c.Request.Context(), userID, h.totpIssuer, accountName)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setTwoFactorAuditSnapshot(c, userID, role, h.setAuditAfter)
+
+	c.JSON(http.StatusOK, jsondto.TwoFactorEnrollmentOutputDTO{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.ProvisioningURI,
+	})
+}
+
+// @Summary Метод подтверждения подключения двухфакторной аутентификации
+// @Security ApiKeyAuth
+// @Tags auth
+// @ID confirmTwoFactor
+// @Accept  json
+// @Produce  json
+// @Param input body dto.TwoFactorCodeInputDTO true "Код приложения-аутентификатора"
+// @Success 200 {object} dto.RecoveryCodesOutputDTO "Двухфакторная аутентификация включена, коды восстановления показываются один раз"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь или неверный код"
+// @Failure 409 {object} dto.ProblemDetails "Подключение не начато или уже завершено"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/auth/2fa/confirm [post]
+func (h *Handler) confirmTwoFactor(c *gin.Context) {
+	var inp jsondto.TwoFactorCodeInputDTO
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	userID, role, err := getReaderData(c)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setTwoFactorAuditSnapshot(c, userID, role, h.setAuditBefore)
+
+	recoveryCodes, err := h.twoFactor.Confirm(c.Request.Context(), userID, inp.Code)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setTwoFactorAuditSnapshot(c, userID, role, h.setAuditAfter)
+
+	c.JSON(http.StatusOK, jsondto.RecoveryCodesOutputDTO{RecoveryCodes: recoveryCodes})
+}
+
+// @Summary Метод отключения двухфакторной аутентификации
+// @Security ApiKeyAuth
+// @Tags auth
+// @ID disableTwoFactor
+// @Accept  json
+// @Param input body dto.TwoFactorCodeInputDTO true "Код приложения-аутентификатора или код восстановления"
+// @Success 204 "Двухфакторная аутентификация отключена"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь или неверный код"
+// @Failure 403 {object} dto.ProblemDetails "Для роли пользователя второй фактор обязателен"
+// @Failure 409 {object} dto.ProblemDetails "Двухфакторная аутентификация не включена"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/auth/2fa/disable [post]
+func (h *Handler) disableTwoFactor(c *gin.Context) {
+	var inp jsondto.TwoFactorCodeInputDTO
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	userID, role, err := getReaderData(c)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+	if isTwoFactorRequired(role) {
+		h.abortWithError(c, weberrs.ErrTwoFactorRequired)
+		return
+	}
+
+	h.setTwoFactorAuditSnapshot(c, userID, role, h.setAuditBefore)
+
+	if err = h.twoFactor.Disable(c.Request.Context(), userID, inp.Code); err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setTwoFactorAuditSnapshot(c, userID, role, h.setAuditAfter)
+
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Метод выпуска новых кодов восстановления
+// @Security ApiKeyAuth
+// @Tags auth
+// @ID regenerateRecoveryCodes
+// @Accept  json
+// @Produce  json
+// @Param input body dto.TwoFactorCodeInputDTO true "Код приложения-аутентификатора или код восстановления"
+// @Success 200 {object} dto.RecoveryCodesOutputDTO "Новые коды восстановления, прежние больше не действуют"
+// @Failure 400 {object} dto.ProblemDetails "Неверный запрос"
+// @Failure 401 {object} dto.ProblemDetails "Неавторизованный пользователь или неверный код"
+// @Failure 409 {object} dto.ProblemDetails "Двухфакторная аутентификация не включена"
+// @Failure 413 {object} dto.ProblemDetails "Слишком большое тело запроса"
+// @Failure 415 {object} dto.ProblemDetails "Тело запроса не в формате JSON"
+// @Failure 422 {object} dto.ProblemDetails "Некорректные значения полей запроса"
+// @Failure 500 {object} dto.ProblemDetails "Внутренняя ошибка сервера"
+// @Router /api/v1/auth/2fa/recovery_codes [post]
+func (h *Handler) regenerateRecoveryCodes(c *gin.Context) {
+	var inp jsondto.TwoFactorCodeInputDTO
+	if err := c.ShouldBindJSON(&inp); err != nil {
+		h.abortWithBindError(c, err)
+		return
+	}
+
+	userID, role, err := getReaderData(c)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setTwoFactorAuditSnapshot(c, userID, role, h.setAuditBefore)
+
+	recoveryCodes, err := h.twoFactor.RegenerateRecoveryCodes(c.Request.Context(), userID, inp.Code)
+	if err != nil {
+		h.abortWithError(c, err)
+		return
+	}
+
+	h.setTwoFactorAuditSnapshot(c, userID, role, h.setAuditAfter)
+
+	c.JSON(http.StatusOK, jsondto.RecoveryCodesOutputDTO{RecoveryCodes: recoveryCodes})
+}
+
+func (h *Handler) getJSONTwoFactorStatusModel(ctx context.Context, userID uuid.UUID, role string) (*jsonmodels.JSONTwoFactorStatusModel, error) {
+	status, err := h.twoFactor.GetStatus(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jsonmodels.JSONTwoFactorStatusModel{
+		IsEnabled:           status.IsEnabled,
+		IsEnrollmentPending: status.IsEnrollmentPending,
+		IsRequired:          isTwoFactorRequired(role),
+		RecoveryCodesLeft:   status.RecoveryCodesLeft,
+		EnabledAt:           h.getOptionalTime(status.EnabledAt),
+	}, nil
+}
+
+// setTwoFactorAuditSnapshot keeps the two-factor state of the user by setSnapshot,
+// the secret and recovery codes are never included.
+func (h *Handler) setTwoFactorAuditSnapshot(c *gin.Context, userID uuid.UUID, role string, setSnapshot func(c *gin.Context, snapshot any)) {
+	status, err := h.getJSONTwoFactorStatusModel(c.Request.Context(), userID, role)
+	if err != nil {
+		h.logger.WithContext(c.Request.Context()).WithError(err).Warn("two-factor audit snapshot failed")
+		return
+	}
+
+	setAuditResourceID(c, userID)
+	setSnapshot(c, status)
+}
+
+// getTwoFactorAccountName returns the phone number of a reader, staff signed in
+// through the OIDC provider are not readers and are named by their ID.
+func (h *Handler) getTwoFactorAccountName(ctx context.Context, userID uuid.UUID) (string, error) {
+	reader, err := h.readerService.GetByID(ctx, userID)
+	if errors.Is(err, errs.ErrReaderDoesNotExists) {
+		return userID.String(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return reader.PhoneNumber, nil
+}
+
+// isTwoFactorRequired reports whether the role must sign in with the second factor,
+// which is optional only for readers.
+func isTwoFactorRequired(role string) bool {
+	return role != readerRole
+}
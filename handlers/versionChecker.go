@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	weberrs "github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/libcard"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/moderation"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/versioning"
+	"strings"
+)
+
+const ifMatchHeader = "If-Match"
+
+func (h *Handler) setETag(c *gin.Context, version string) {
+	c.Header("ETag", h.formatETag(version))
+}
+
+func (h *Handler) formatETag(version string) string {
+	return `"` + version + `"`
+}
+
+// checkIfMatch compares If-Match with the current version of the resource.
+// Weak ETags never match, as If-Match requires the strong comparison.
+func (h *Handler) checkIfMatch(c *gin.Context, version string, isRequired bool) error {
+	ifMatch := c.GetHeader(ifMatchHeader)
+	if ifMatch == "" {
+		if isRequired {
+			return weberrs.ErrPreconditionRequired
+		}
+		return nil
+	}
+
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == h.formatETag(version) {
+			return nil
+		}
+	}
+
+	return weberrs.ErrPreconditionFailed
+}
+
+func (h *Handler) getBookVersion(book *models.BookModel) string {
+	return versioning.Compute(book.ID, book.Title, book.Author, book.Publisher, book.CopiesNumber,
+		book.Rarity, book.Genre, book.PublishingYear, book.Language, book.AgeLimit)
+}
+
+func (h *Handler) getReservationVersion(reservation *models.ReservationModel) string {
+	return versioning.Compute(reservation.ID, reservation.ReaderID, reservation.BookID,
+		reservation.IssueDate, reservation.ReturnDate, reservation.State)
+}
+
+func (h *Handler) getLibCardVersion(libCard *models.LibCardModel, state *libcard.CardState) string {
+	return versioning.Compute(libCard.ID, libCard.LibCardNum, libCard.Validity, libCard.IssueDate,
+		libCard.ActionStatus, state.LibCardNum, state.Blocked, len(state.History))
+}
+
+func (h *Handler) getReviewVersion(status *moderation.ReviewStatus) string {
+	return versioning.Compute(status.Rating.ID, status.State, len(status.Reports), len(status.Decisions))
+}
@@ -0,0 +1,320 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/filestore"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Scope string
+
+const (
+	ScopeCatalogRead       Scope = "catalog:read"
+	ScopeReservationsWrite Scope = "reservations:write"
+)
+
+// KeyPrefix starts every key, so that leaked keys are easy to find by secret scanners.
+const KeyPrefix = "bsk_"
+
+const secretLen = 32
+
+// usageFlushPeriod limits writes of the registry file caused by usage of keys,
+// usage recorded since the last write is lost on a crash.
+const usageFlushPeriod = time.Minute
+
+type Usage struct {
+	Count      int64
+	LastUsedAt time.Time
+	ByRoute    map[string]int64
+}
+
+// Key is an API key of an integration. Only a hash of its secret is kept,
+// the secret is returned once when the key is issued or rotated.
+type Key struct {
+	ID         uuid.UUID
+	Name       string
+	Scopes     []Scope
+	SecretHash string
+	CreatedAt  time.Time
+	RotatedAt  time.Time
+	RevokedAt  time.Time
+	Usage      Usage
+}
+
+func (k *Key) HasScope(scope Scope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+func (k *Key) IsRevoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// IKeyRegistry provides storage of API keys and their usage.
+type IKeyRegistry interface {
+	Issue(ctx context.Context, name string, scopes []Scope) (*Key, string, error)
+	Rotate(ctx context.Context, keyID uuid.UUID) (*Key, string, error)
+	Revoke(ctx context.Context, keyID uuid.UUID) (*Key, error)
+	GetByID(ctx context.Context, keyID uuid.UUID) (*Key, error)
+	GetAll(ctx context.Context) ([]*Key, error)
+	// Authenticate returns the key of a raw key passed by a client.
+	Authenticate(ctx context.Context, rawKey string) (*Key, error)
+	RecordUsage(ctx context.Context, keyID uuid.UUID, route string) error
+}
+
+type registryKeys map[uuid.UUID]*Key
+
+type KeyRegistry struct {
+	keys filestore.IDocument[registryKeys]
+	now  func() time.Time
+
+	flushMu     sync.Mutex
+	lastFlushed time.Time
+}
+
+// NewKeyRegistry keeps the keys in the file, an empty path keeps them in memory only.
+func NewKeyRegistry(path string) IKeyRegistry {
+	return &KeyRegistry{
+		keys: filestore.NewDocument(path, func() *registryKeys {
+			keys := make(registryKeys)
+			return &keys
+		}),
+		now:         time.Now,
+		lastFlushed: time.Now(),
+	}
+}
+
+func (kr *KeyRegistry) Issue(_ context.Context, name string, scopes []Scope) (*Key, string, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &Key{
+		ID:         uuid.New(),
+		Name:       name,
+		Scopes:     slices.Clone(scopes),
+		SecretHash: hashSecret(secret),
+		CreatedAt:  kr.now(),
+		Usage:      Usage{ByRoute: make(map[string]int64)},
+	}
+
+	err = kr.keys.Update(func(keys *registryKeys) error {
+		(*keys)[key.ID] = copyKey(key)
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, formatKey(key.ID, secret), nil
+}
+
+// Rotate replaces the secret of the key, the previous one stops working at once.
+func (kr *KeyRegistry) Rotate(_ context.Context, keyID uuid.UUID) (*Key, string, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key, err := kr.update(keyID, func(key *Key) error {
+		if key.IsRevoked() {
+			return errs.ErrAPIKeyIsAlreadyRevoked
+		}
+
+		key.SecretHash = hashSecret(secret)
+		key.RotatedAt = kr.now()
+
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, formatKey(key.ID, secret), nil
+}
+
+// Revoke disables the key, it is kept to show its usage.
+func (kr *KeyRegistry) Revoke(_ context.Context, keyID uuid.UUID) (*Key, error) {
+	return kr.update(keyID, func(key *Key) error {
+		if key.IsRevoked() {
+			return errs.ErrAPIKeyIsAlreadyRevoked
+		}
+
+		key.RevokedAt = kr.now()
+
+		return nil
+	})
+}
+
+func (kr *KeyRegistry) GetByID(_ context.Context, keyID uuid.UUID) (*Key, error) {
+	var keyCopy *Key
+	err := kr.keys.Read(func(keys *registryKeys) error {
+		key, ok := (*keys)[keyID]
+		if !ok {
+			return errs.ErrAPIKeyDoesNotExists
+		}
+		keyCopy = copyKey(key)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keyCopy, nil
+}
+
+// GetAll returns the keys in the order of issue.
+func (kr *KeyRegistry) GetAll(_ context.Context) ([]*Key, error) {
+	var allKeys []*Key
+	err := kr.keys.Read(func(keys *registryKeys) error {
+		allKeys = make([]*Key, 0, len(*keys))
+		for _, key := range *keys {
+			allKeys = append(allKeys, copyKey(key))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(allKeys, func(i, j int) bool {
+		return allKeys[i].CreatedAt.Before(allKeys[j].CreatedAt)
+	})
+
+	return allKeys, nil
+}
+
+func (kr *KeyRegistry) Authenticate(_ context.Context, rawKey string) (*Key, error) {
+	keyID, secret, err := parseKey(rawKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var keyCopy *Key
+	err = kr.keys.Read(func(keys *registryKeys) error {
+		key, ok := (*keys)[keyID]
+		if !ok || subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashSecret(secret))) != 1 {
+			return errs.ErrInvalidAPIKey
+		}
+		keyCopy = copyKey(key)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if keyCopy.IsRevoked() {
+		return nil, errs.ErrAPIKeyIsRevoked
+	}
+
+	return keyCopy, nil
+}
+
+// RecordUsage counts the request without writing the file every time, see usageFlushPeriod.
+func (kr *KeyRegistry) RecordUsage(_ context.Context, keyID uuid.UUID, route string) error {
+	err := kr.keys.Touch(func(keys *registryKeys) {
+		if key, ok := (*keys)[keyID]; ok {
+			key.Usage.Count++
+			key.Usage.LastUsedAt = kr.now()
+			key.Usage.ByRoute[route]++
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	kr.flushMu.Lock()
+	defer kr.flushMu.Unlock()
+
+	if kr.now().Sub(kr.lastFlushed) < usageFlushPeriod {
+		return nil
+	}
+	kr.lastFlushed = kr.now()
+
+	return kr.keys.Flush()
+}
+
+// update changes the key and returns a copy of the changed key.
+func (kr *KeyRegistry) update(keyID uuid.UUID, change func(key *Key) error) (*Key, error) {
+	var keyCopy *Key
+	err := kr.keys.Update(func(keys *registryKeys) error {
+		key, ok := (*keys)[keyID]
+		if !ok {
+			return errs.ErrAPIKeyDoesNotExists
+		}
+		if err := change(key); err != nil {
+			return err
+		}
+		keyCopy = copyKey(key)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return keyCopy, nil
+}
+
+func newSecret() (string, error) {
+	secret := make([]byte, secretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(secret), nil
+}
+
+// hashSecret does not need a slow password hash, since secrets are random
+// and too long to be guessed.
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// formatKey joins the key ID and the secret, e.g. bsk_<32 hex digits>_<64 hex digits>.
+func formatKey(keyID uuid.UUID, secret string) string {
+	return KeyPrefix + strings.ReplaceAll(keyID.String(), "-", "") + "_" + secret
+}
+
+func parseKey(rawKey string) (uuid.UUID, string, error) {
+	idAndSecret, ok := strings.CutPrefix(rawKey, KeyPrefix)
+	if !ok {
+		return uuid.Nil, "", errs.ErrInvalidAPIKey
+	}
+
+	id, secret, ok := strings.Cut(idAndSecret, "_")
+	if !ok || len(secret) != 2*secretLen {
+		return uuid.Nil, "", errs.ErrInvalidAPIKey
+	}
+
+	keyID, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, "", errs.ErrInvalidAPIKey
+	}
+
+	return keyID, secret, nil
+}
+
+func copyKey(key *Key) *Key {
+	keyCopy := *key
+	keyCopy.Scopes = slices.Clone(key.Scopes)
+	keyCopy.Usage.ByRoute = maps.Clone(key.Usage.ByRoute)
+
+	return &keyCopy
+}
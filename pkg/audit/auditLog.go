@@ -0,0 +1,125 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Entry records a state-changing request. Before and After are JSON snapshots
+// of the resource, either one is empty when the resource did not exist.
+type Entry struct {
+	ID           uuid.UUID
+	Time         time.Time
+	ActorID      string // empty for anonymous requests, e.g. sign-up
+	ActorRole    string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Before       json.RawMessage
+	After        json.RawMessage
+	RequestID    string
+	Route        string
+	Status       int
+}
+
+// Filter selects entries, its zero fields match any entry. A zero Limit means no limit.
+type Filter struct {
+	ActorID      string
+	ActorRole    string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	RequestID    string
+	From         time.Time // inclusive
+	To           time.Time // exclusive
+	Limit        int
+	Offset       int
+}
+
+func (f *Filter) Match(entry *Entry) bool {
+	return (f.ActorID == "" || entry.ActorID == f.ActorID) &&
+		(f.ActorRole == "" || entry.ActorRole == f.ActorRole) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		(f.ResourceType == "" || entry.ResourceType == f.ResourceType) &&
+		(f.ResourceID == "" || entry.ResourceID == f.ResourceID) &&
+		(f.RequestID == "" || entry.RequestID == f.RequestID) &&
+		(f.From.IsZero() || !entry.Time.Before(f.From)) &&
+		(f.To.IsZero() || entry.Time.Before(f.To))
+}
+
+// ILog is an append-only log of entries, they can not be changed or deleted.
+type ILog interface {
+	// Append sets ID and Time of the entry if they are not set.
+	Append(ctx context.Context, entry *Entry) error
+	// Query returns entries matching the filter, the newest first.
+	Query(ctx context.Context, filter *Filter) ([]*Entry, error)
+}
+
+// MemoryLog keeps entries of a single instance until it is restarted.
+type MemoryLog struct {
+	mu      sync.RWMutex
+	entries []*Entry
+	now     func() time.Time
+}
+
+func NewMemoryLog() ILog {
+	return &MemoryLog{now: time.Now}
+}
+
+func (ml *MemoryLog) Append(_ context.Context, entry *Entry) error {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	ml.append(entry)
+
+	return nil
+}
+
+func (ml *MemoryLog) append(entry *Entry) *Entry {
+	entryCopy := copyEntry(entry)
+	if entryCopy.ID == uuid.Nil {
+		entryCopy.ID = uuid.New()
+	}
+	if entryCopy.Time.IsZero() {
+		entryCopy.Time = ml.now()
+	}
+	ml.entries = append(ml.entries, entryCopy)
+
+	return entryCopy
+}
+
+func (ml *MemoryLog) Query(_ context.Context, filter *Filter) ([]*Entry, error) {
+	ml.mu.RLock()
+	defer ml.mu.RUnlock()
+
+	entries := make([]*Entry, 0)
+	skipped := 0
+	for i := len(ml.entries) - 1; i >= 0; i-- {
+		entry := ml.entries[i]
+		if filter.Limit > 0 && len(entries) == filter.Limit {
+			break
+		}
+		if !filter.Match(entry) {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		entries = append(entries, copyEntry(entry))
+	}
+
+	return entries, nil
+}
+
+func copyEntry(entry *Entry) *Entry {
+	entryCopy := *entry
+	entryCopy.Before = slices.Clone(entry.Before)
+	entryCopy.After = slices.Clone(entry.After)
+
+	return &entryCopy
+}
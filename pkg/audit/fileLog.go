@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const maxLineSize = 1 << 20
+
+// fileEntry is a line of the log file.
+type fileEntry struct {
+	ID           uuid.UUID       `json:"id"`
+	Time         time.Time       `json:"time"`
+	ActorID      string          `json:"actor_id,omitempty"`
+	ActorRole    string          `json:"actor_role,omitempty"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resource_type"`
+	ResourceID   string          `json:"resource_id,omitempty"`
+	Before       json.RawMessage `json:"before,omitempty"`
+	After        json.RawMessage `json:"after,omitempty"`
+	RequestID    string          `json:"request_id,omitempty"`
+	Route        string          `json:"route"`
+	Status       int             `json:"status"`
+}
+
+// FileLog appends entries as JSON lines to a file opened in append mode, so that
+// they survive restarts. Queries are served from memory, which keeps every entry of
+// the file: the memory grows with the file, which is to be rotated by the operator
+// before it outgrows the process, the entries of a rotated file are not queried.
+type FileLog struct {
+	mu     sync.Mutex
+	file   *os.File
+	memory *MemoryLog
+}
+
+// NewFileLog opens the log file, creating it if needed, and reads the entries written before.
+// A last line torn by a crash during its write is truncated, as its entry was not synced.
+func NewFileLog(path string) (*FileLog, error) {
+	memory := &MemoryLog{now: time.Now}
+
+	size, err := readEntries(path, memory)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	// the next entry must not be appended to the torn line
+	if err = file.Truncate(size); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &FileLog{file: file, memory: memory}, nil
+}
+
+func (fl *FileLog) Append(_ context.Context, entry *Entry) error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	fl.memory.mu.Lock()
+	defer fl.memory.mu.Unlock()
+
+	appended := fl.memory.append(entry)
+
+	line, err := json.Marshal(fileEntry(*appended))
+	if err != nil {
+		fl.memory.entries = fl.memory.entries[:len(fl.memory.entries)-1]
+		return err
+	}
+
+	if _, err = fl.file.Write(append(line, '\n')); err != nil {
+		fl.memory.entries = fl.memory.entries[:len(fl.memory.entries)-1]
+		return err
+	}
+
+	// the entry is written, but may be lost on a crash if it is not synced
+	return fl.file.Sync()
+}
+
+func (fl *FileLog) Query(ctx context.Context, filter *Filter) ([]*Entry, error) {
+	return fl.memory.Query(ctx, filter)
+}
+
+func (fl *FileLog) Close() error {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+
+	return fl.file.Close()
+}
+
+// readEntries returns the size of the complete lines of the file, a line is complete
+// once its newline is written.
+func readEntries(path string, memory *MemoryLog) (int64, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if len(line) > maxLineSize {
+			return 0, bufio.ErrTooLong
+		}
+
+		var fileLine fileEntry
+		if err = json.Unmarshal(line, &fileLine); err != nil {
+			return 0, fmt.Errorf("line at offset %d: %w", size, err)
+		}
+		entry := Entry(fileLine)
+		memory.entries = append(memory.entries, &entry)
+		size += int64(len(line))
+	}
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendEntries(t *testing.T, path string, actions ...string) {
+	t.Helper()
+
+	fl, err := NewFileLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+
+	for _, action := range actions {
+		if err = fl.Append(context.Background(), &Entry{Action: action, ResourceType: "book"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func queryActions(t *testing.T, path string) []string {
+	t.Helper()
+
+	fl, err := NewFileLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+
+	entries, err := fl.Query(context.Background(), &Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+
+	return actions
+}
+
+func TestFileLogTruncatesTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	appendEntries(t, path, "create", "update")
+
+	// a crash during the write of the third entry
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = file.WriteString(`{"id":"0b0e5a4c-4a4a-4f36-8d6f-0a0b5c1f3e11","act`); err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	appendEntries(t, path, "delete")
+
+	got := queryActions(t, path)
+	want := []string{"delete", "update", "create"}
+	if len(got) != len(want) {
+		t.Fatalf("got actions %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got actions %v, want %v", got, want)
+		}
+	}
+}
+
+func TestFileLogRejectsCorruptedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	if err := os.WriteFile(path, []byte("{\"action\":\"create\"}\nnot json\n{\"action\":\"update\"}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewFileLog(path); err == nil {
+		t.Fatal("got no error reading a corrupted line")
+	}
+}
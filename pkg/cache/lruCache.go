@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Entry struct {
+	Body        []byte
+	ContentType string
+	ETag        string
+	ExpiresAt   time.Time
+}
+
+// ICache provides in-process storage of rendered responses.
+type ICache interface {
+	Get(key string) (*Entry, bool)
+	// Generation changes on every deletion. It is read before rendering a response,
+	// so that a response rendered from data changed meanwhile is not stored.
+	Generation() uint64
+	// Set stores the entry unless entries were deleted since the generation was read.
+	Set(key string, entry *Entry, generation uint64) bool
+	DeleteByPrefix(prefix string)
+}
+
+type item struct {
+	key   string
+	entry *Entry
+}
+
+// LRUCache evicts the least recently used entry when capacity is exceeded.
+// Expired entries are dropped lazily on access.
+type LRUCache struct {
+	mu         sync.Mutex
+	capacity   int
+	items      map[string]*list.Element
+	order      *list.List
+	generation uint64
+}
+
+func NewLRUCache(capacity int) ICache {
+	return &LRUCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (lc *LRUCache) Get(key string) (*Entry, bool) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	element, ok := lc.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*item).entry
+	if time.Now().After(entry.ExpiresAt) {
+		lc.remove(element)
+		return nil, false
+	}
+
+	lc.order.MoveToFront(element)
+
+	return entry, true
+}
+
+func (lc *LRUCache) Generation() uint64 {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	return lc.generation
+}
+
+func (lc *LRUCache) Set(key string, entry *Entry, generation uint64) bool {
+	if lc.capacity <= 0 {
+		return false
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if generation != lc.generation {
+		return false
+	}
+
+	if element, ok := lc.items[key]; ok {
+		element.Value.(*item).entry = entry
+		lc.order.MoveToFront(element)
+		return true
+	}
+
+	lc.items[key] = lc.order.PushFront(&item{key: key, entry: entry})
+
+	for lc.order.Len() > lc.capacity {
+		lc.remove(lc.order.Back())
+	}
+
+	return true
+}
+
+// DeleteByPrefix advances the generation even if nothing is deleted, since the
+// response of a request in progress may be stored under the prefix later.
+func (lc *LRUCache) DeleteByPrefix(prefix string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	lc.generation++
+
+	for key, element := range lc.items {
+		if strings.HasPrefix(key, prefix) {
+			lc.remove(element)
+		}
+	}
+}
+
+func (lc *LRUCache) remove(element *list.Element) {
+	lc.order.Remove(element)
+	delete(lc.items, element.Value.(*item).key)
+}
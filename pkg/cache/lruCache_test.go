@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func newEntry(body string) *Entry {
+	return &Entry{Body: []byte(body), ExpiresAt: time.Now().Add(time.Minute)}
+}
+
+func TestSetSkipsEntriesRenderedBeforeDeletion(t *testing.T) {
+	c := NewLRUCache(10)
+
+	// a request reads the generation, a write invalidates the route before the request stores its body
+	generation := c.Generation()
+	c.DeleteByPrefix("/api/v1/books/1")
+
+	if c.Set("/api/v1/books/1?", newEntry("stale"), generation) {
+		t.Fatal("entry rendered before the deletion is stored")
+	}
+	if _, ok := c.Get("/api/v1/books/1?"); ok {
+		t.Fatal("stale entry is served")
+	}
+
+	if !c.Set("/api/v1/books/1?", newEntry("fresh"), c.Generation()) {
+		t.Fatal("entry rendered after the deletion is not stored")
+	}
+	if entry, ok := c.Get("/api/v1/books/1?"); !ok || string(entry.Body) != "fresh" {
+		t.Fatalf("got entry %v, want the fresh one", entry)
+	}
+}
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewLRUCache(2)
+
+	c.Set("a", newEntry("a"), c.Generation())
+	c.Set("b", newEntry("b"), c.Generation())
+	c.Get("a")
+	c.Set("c", newEntry("c"), c.Generation())
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry is kept")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("entry %q is evicted", key)
+		}
+	}
+}
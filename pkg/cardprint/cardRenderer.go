@@ -0,0 +1,195 @@
+package cardprint
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"sync"
+	"time"
+)
+
+// Card is rendered in ID-1 (CR80) format, 85.6 x 54 mm, at 300 dpi.
+const (
+	cardWidthMM  = 85.6
+	cardHeightMM = 54.0
+
+	cardWidthPx  = 1011
+	cardHeightPx = 638
+	marginPx     = 40
+
+	barcodeWidthPx  = 600
+	barcodeHeightPx = 140
+	qrSizePx        = 260
+
+	dateLayout = "02.01.2006"
+)
+
+var (
+	colorWhite  = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	colorBlack  = color.RGBA{A: 0xff}
+	colorHeader = color.RGBA{R: 0x2b, G: 0x4c, B: 0x7e, A: 0xff}
+)
+
+type CardData struct {
+	ReaderFio      string
+	LibCardNum     string
+	IssueDate      time.Time
+	ExpirationDate time.Time
+}
+
+// ICardRenderer provides rendering of a printable library card with
+// a Code128 barcode and a QR code, both encoding the lib card number.
+type ICardRenderer interface {
+	RenderPNG(w io.Writer, data *CardData) error
+	RenderPDF(w io.Writer, data *CardData) error
+}
+
+const (
+	titleFontSize = 44
+	textFontSize  = 36
+)
+
+// CardRenderer parses the fonts once. Faces cache glyphs and are not safe for
+// concurrent use, so they are created for every rendered card.
+type CardRenderer struct {
+	loadFontsOnce sync.Once
+	loadFontsErr  error
+	titleFont     *opentype.Font
+	textFont      *opentype.Font
+}
+
+func NewCardRenderer() ICardRenderer {
+	return &CardRenderer{}
+}
+
+func (cr *CardRenderer) RenderPNG(w io.Writer, data *CardData) error {
+	img, err := cr.renderImage(data)
+	if err != nil {
+		return err
+	}
+
+	return png.Encode(w, img)
+}
+
+func (cr *CardRenderer) RenderPDF(w io.Writer, data *CardData) error {
+	img, err := cr.renderImage(data)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err = writeImagePDF(&buf, img, mmToPt(cardWidthMM), mmToPt(cardHeightMM)); err != nil {
+		return err
+	}
+
+	_, err = w.Write(buf.Bytes())
+
+	return err
+}
+
+func (cr *CardRenderer) renderImage(data *CardData) (*image.RGBA, error) {
+	if err := cr.loadFonts(); err != nil {
+		return nil, err
+	}
+
+	titleFace, err := newFace(cr.titleFont, titleFontSize)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+
+	textFace, err := newFace(cr.textFont, textFontSize)
+	if err != nil {
+		return nil, err
+	}
+	defer textFace.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, cardWidthPx, cardHeightPx))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: colorWhite}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, 0, cardWidthPx, 90), &image.Uniform{C: colorHeader}, image.Point{}, draw.Src)
+
+	cr.drawText(img, titleFace, colorWhite, marginPx, 62, "BookSmart — читательский билет")
+	cr.drawText(img, textFace, colorBlack, marginPx, 160, data.ReaderFio)
+	cr.drawText(img, textFace, colorBlack, marginPx, 215, fmt.Sprintf("№ %s", data.LibCardNum))
+	cr.drawText(img, textFace, colorBlack, marginPx, 270, fmt.Sprintf("Действителен: %s — %s",
+		data.IssueDate.Format(dateLayout), data.ExpirationDate.Format(dateLayout)))
+
+	barcodeImg, err := cr.encodeCode128(data.LibCardNum)
+	if err != nil {
+		return nil, err
+	}
+	barcodeOrigin := image.Pt(marginPx, cardHeightPx-marginPx-barcodeHeightPx)
+	draw.Draw(img, barcodeImg.Bounds().Add(barcodeOrigin), barcodeImg, image.Point{}, draw.Src)
+
+	qrImg, err := cr.encodeQR(data.LibCardNum)
+	if err != nil {
+		return nil, err
+	}
+	qrOrigin := image.Pt(cardWidthPx-marginPx-qrSizePx, cardHeightPx-marginPx-qrSizePx)
+	draw.Draw(img, qrImg.Bounds().Add(qrOrigin), qrImg, image.Point{}, draw.Src)
+
+	return img, nil
+}
+
+func (cr *CardRenderer) loadFonts() error {
+	cr.loadFontsOnce.Do(func() {
+		if cr.titleFont, cr.loadFontsErr = opentype.Parse(gobold.TTF); cr.loadFontsErr != nil {
+			return
+		}
+		cr.textFont, cr.loadFontsErr = opentype.Parse(goregular.TTF)
+	})
+
+	return cr.loadFontsErr
+}
+
+func (cr *CardRenderer) encodeCode128(content string) (barcode.Barcode, error) {
+	code, err := code128.Encode(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return barcode.Scale(code, barcodeWidthPx, barcodeHeightPx)
+}
+
+func (cr *CardRenderer) encodeQR(content string) (barcode.Barcode, error) {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+
+	return barcode.Scale(code, qrSizePx, qrSizePx)
+}
+
+func (cr *CardRenderer) drawText(img draw.Image, face font.Face, textColor color.Color, x, y int, text string) {
+	drawer := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(textColor),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	drawer.DrawString(text)
+}
+
+func newFace(parsedFont *opentype.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(parsedFont, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+}
+
+func mmToPt(mm float64) float64 {
+	return mm / 25.4 * 72
+}
@@ -0,0 +1,110 @@
+package certreload
+
+import (
+	"crypto/tls"
+	"github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"time"
+)
+
+// ICertificateReloader provides the certificate of a TLS server, which is
+// reloaded when its files change, so that renewed certificates are served
+// without a restart.
+type ICertificateReloader interface {
+	GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
+// CertificateReloader checks modification times of the files at most once per
+// check period during handshakes. A certificate failing to load is logged and
+// the previous one is served until the files are fixed.
+type CertificateReloader struct {
+	mu          sync.Mutex
+	certFile    string
+	keyFile     string
+	certificate *tls.Certificate
+	modTime     time.Time
+	checkPeriod time.Duration
+	checkedAt   time.Time
+	logger      *logrus.Entry
+	now         func() time.Time
+}
+
+// NewCertificateReloader loads the certificate, failing if the files are invalid.
+func NewCertificateReloader(certFile, keyFile string, checkPeriod time.Duration, logger *logrus.Entry) (ICertificateReloader, error) {
+	cr := &CertificateReloader{
+		certFile:    certFile,
+		keyFile:     keyFile,
+		checkPeriod: checkPeriod,
+		logger:      logger,
+		now:         time.Now,
+	}
+
+	modTime, err := cr.getModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err = cr.load(modTime); err != nil {
+		return nil, err
+	}
+	cr.checkedAt = cr.now()
+
+	return cr, nil
+}
+
+func (cr *CertificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	now := cr.now()
+	if now.Sub(cr.checkedAt) < cr.checkPeriod {
+		return cr.certificate, nil
+	}
+	cr.checkedAt = now
+
+	modTime, err := cr.getModTime()
+	if err != nil {
+		cr.logger.WithError(err).Warn("failed to check TLS certificate files, serving the previous certificate")
+		return cr.certificate, nil
+	}
+	if !modTime.After(cr.modTime) {
+		return cr.certificate, nil
+	}
+
+	if err = cr.load(modTime); err != nil {
+		cr.logger.WithError(err).Warn("failed to reload TLS certificate, serving the previous certificate")
+		return cr.certificate, nil
+	}
+	cr.logger.WithField("cert_file", cr.certFile).Info("TLS certificate reloaded")
+
+	return cr.certificate, nil
+}
+
+func (cr *CertificateReloader) load(modTime time.Time) error {
+	certificate, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return err
+	}
+
+	cr.certificate = &certificate
+	cr.modTime = modTime
+
+	return nil
+}
+
+// getModTime returns the latest modification time of the files, since
+// the certificate and the key are replaced one after another.
+func (cr *CertificateReloader) getModTime() (time.Time, error) {
+	var modTime time.Time
+	for _, file := range []string{cr.certFile, cr.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	return modTime, nil
+}
@@ -0,0 +1,435 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/nikitalystsev/BookSmart-services/impl"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/i18n"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/moderation"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/oidc"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/totp"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAddr              = ":8000"
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultIdleTimeout       = 60 * time.Second
+	DefaultShutdownTimeout   = 15 * time.Second
+	DefaultRequestTimeout    = 10 * time.Second
+	DefaultMaxBodySize       = 1 << 20
+	DefaultAccessTokenTTL    = 15 * time.Minute
+	DefaultRefreshTokenTTL   = 30 * 24 * time.Hour
+	DefaultPageSize          = impl.PageLimit
+	DefaultEnvironment       = "development"
+	DefaultCORSMaxAge        = time.Hour
+	DefaultCertReloadPeriod  = 10 * time.Second
+	DefaultHSTSMaxAge        = 365 * 24 * time.Hour
+	DefaultOIDCLoginTTL      = oidc.DefaultLoginTTL
+	DefaultTOTPIssuer        = "BookSmart"
+	DefaultChallengeTTL      = totp.DefaultChallengeTTL
+	DefaultDataDir           = "data"
+	AuditFileName            = "audit.jsonl"
+)
+
+const (
+	anyOrigin       = "*"
+	subdomainPrefix = "*."
+)
+
+type Config struct {
+	Environment string           `yaml:"environment"` // e.g. development, staging, production
+	Server      ServerConfig     `yaml:"server"`
+	TLS         TLSConfig        `yaml:"tls"`
+	CORS        CORSConfig       `yaml:"cors"`
+	Auth        AuthConfig       `yaml:"auth"`
+	OIDC        OIDCConfig       `yaml:"oidc"`
+	Catalog     CatalogConfig    `yaml:"catalog"`
+	Storage     StorageConfig    `yaml:"storage"`
+	I18n        I18nConfig       `yaml:"i18n"`
+	Audit       AuditConfig      `yaml:"audit"`
+	Moderation  ModerationConfig `yaml:"moderation"`
+	Metrics     MetricsConfig    `yaml:"metrics"`
+}
+
+type ServerConfig struct {
+	Addr              string        `yaml:"addr"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout       time.Duration `yaml:"read_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"` // to drain in-flight requests, zero waits until all complete
+	RequestTimeout    time.Duration `yaml:"request_timeout"`  // deadline of the request context, zero disables it
+	MaxBodySize       int64         `yaml:"max_body_size"`    // in bytes
+	// RouteTimeouts override RequestTimeout of routes, e.g. "GET /api/v1/readers/:id/lib_cards/card.pdf".
+	RouteTimeouts map[string]time.Duration `yaml:"route_timeouts"`
+	// TrustedProxies are IPs or CIDRs of reverse proxies whose X-Forwarded-For is used as the
+	// client IP, e.g. for rate limiting. Empty means the address of the connection is used.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+// TLSConfig enables HTTPS and HTTP/2 when both files are set.
+type TLSConfig struct {
+	CertFile     string        `yaml:"cert_file"`
+	KeyFile      string        `yaml:"key_file"`
+	ReloadPeriod time.Duration `yaml:"reload_period"`  // of checking the files for a renewed certificate
+	ClientCAFile string        `yaml:"client_ca_file"` // requires client certificates for the admin routes
+	HSTSMaxAge   time.Duration `yaml:"hsts_max_age"`   // zero disables Strict-Transport-Security
+}
+
+func (c TLSConfig) IsEnabled() bool {
+	return c.CertFile != "" && c.KeyFile != ""
+}
+
+func (c TLSConfig) IsAdminClientCertRequired() bool {
+	return c.ClientCAFile != ""
+}
+
+// CORSConfig lists origins allowed to make cross-origin requests. An origin is either
+// exact, e.g. https://booksmart.ru, a pattern of its subdomains, e.g. https://*.booksmart.ru,
+// or "*" allowing any origin without credentials.
+type CORSConfig struct {
+	AllowOrigins       []string            `yaml:"allow_origins"`
+	EnvironmentOrigins map[string][]string `yaml:"environment_origins"` // added to AllowOrigins in the environment
+	AllowCredentials   bool                `yaml:"allow_credentials"`
+	MaxAge             time.Duration       `yaml:"max_age"` // of preflight responses in the browser cache
+}
+
+// GetAllowOrigins returns the origins allowed in the environment.
+func (c CORSConfig) GetAllowOrigins(environment string) []string {
+	origins := make([]string, 0, len(c.AllowOrigins)+len(c.EnvironmentOrigins[environment]))
+	origins = append(origins, c.AllowOrigins...)
+
+	return append(origins, c.EnvironmentOrigins[environment]...)
+}
+
+type AuthConfig struct {
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl"`
+	TOTPIssuer      string        `yaml:"totp_issuer"`   // shown by authenticator apps next to the account
+	ChallengeTTL    time.Duration `yaml:"challenge_ttl"` // of entering the two-factor code after the password
+}
+
+// OIDCConfig enables sign-in of staff through an OpenID Connect provider when IssuerURL is set.
+type OIDCConfig struct {
+	IssuerURL    string   `yaml:"issuer_url"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url"` // of the callback route, registered at the provider
+	Scopes       []string `yaml:"scopes"`
+	GroupsClaim  string   `yaml:"groups_claim"` // ID token claim listing groups of the user
+	// GroupRoles grant roles to members of groups, the first mapping containing the user wins.
+	GroupRoles []oidc.GroupRole `yaml:"group_roles"`
+	LoginTTL   time.Duration    `yaml:"login_ttl"` // of a login started at the provider
+}
+
+func (c OIDCConfig) IsEnabled() bool {
+	return c.IssuerURL != ""
+}
+
+// GetProviderConfig returns the settings of the provider client.
+func (c OIDCConfig) GetProviderConfig() oidc.Config {
+	return oidc.Config{
+		IssuerURL:    c.IssuerURL,
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		RedirectURL:  c.RedirectURL,
+		Scopes:       c.Scopes,
+		GroupsClaim:  c.GroupsClaim,
+	}
+}
+
+type CatalogConfig struct {
+	PageSize uint `yaml:"page_size"`
+}
+
+type StorageConfig struct {
+	DataDir string `yaml:"data_dir"` // of the files keeping state of the handlers between restarts
+}
+
+// GetPath returns the path of a file in the data directory.
+func (c StorageConfig) GetPath(name string) string {
+	return filepath.Join(c.DataDir, name)
+}
+
+// AuditConfig sets the file of the audit log. Its entries are also kept in memory for
+// queries, so the file is to be rotated before it outgrows the process, see audit.FileLog.
+type AuditConfig struct {
+	File string `yaml:"file"` // of the audit log, AuditFileName in storage.data_dir if empty
+}
+
+// GetAuditFile returns the path of the audit log file.
+func (c *Config) GetAuditFile() string {
+	if c.Audit.File != "" {
+		return c.Audit.File
+	}
+
+	return c.Storage.GetPath(AuditFileName)
+}
+
+type I18nConfig struct {
+	// LocalesDir keeps message catalogs adding languages or overriding built-in messages, e.g. "fr.json".
+	LocalesDir string `yaml:"locales_dir"`
+}
+
+// NewTranslator loads the built-in catalogs and those of LocalesDir, if it is set.
+func (c I18nConfig) NewTranslator() (i18n.ITranslator, error) {
+	if c.LocalesDir == "" {
+		return i18n.NewDefaultTranslator(), nil
+	}
+
+	return i18n.NewTranslator(i18n.DefaultLanguage, c.LocalesDir)
+}
+
+type ModerationConfig struct {
+	// BlockedWordsFile lists words which hold a review for moderation, one per line.
+	BlockedWordsFile string `yaml:"blocked_words_file"`
+}
+
+// LoadBlockedWords reads BlockedWordsFile, no words are blocked if it is not set.
+func (c ModerationConfig) LoadBlockedWords() ([]string, error) {
+	if c.BlockedWordsFile == "" {
+		return nil, nil
+	}
+
+	return moderation.LoadBlockedWords(c.BlockedWordsFile)
+}
+
+// MetricsConfig moves /metrics from the public router to a separate plain HTTP server
+// when Addr is set, e.g. to a port reachable only by Prometheus.
+type MetricsConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+func (c MetricsConfig) IsSeparate() bool {
+	return c.Addr != ""
+}
+
+// Default returns the configuration used for settings missing in every source.
+func Default() *Config {
+	return &Config{
+		Environment: DefaultEnvironment,
+		Server: ServerConfig{
+			Addr:              DefaultAddr,
+			ReadHeaderTimeout: DefaultReadHeaderTimeout,
+			ReadTimeout:       DefaultReadTimeout,
+			WriteTimeout:      DefaultWriteTimeout,
+			IdleTimeout:       DefaultIdleTimeout,
+			ShutdownTimeout:   DefaultShutdownTimeout,
+			RequestTimeout:    DefaultRequestTimeout,
+			MaxBodySize:       DefaultMaxBodySize,
+		},
+		TLS: TLSConfig{
+			ReloadPeriod: DefaultCertReloadPeriod,
+			HSTSMaxAge:   DefaultHSTSMaxAge,
+		},
+		CORS: CORSConfig{
+			AllowOrigins: []string{anyOrigin},
+			MaxAge:       DefaultCORSMaxAge,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:  DefaultAccessTokenTTL,
+			RefreshTokenTTL: DefaultRefreshTokenTTL,
+			TOTPIssuer:      DefaultTOTPIssuer,
+			ChallengeTTL:    DefaultChallengeTTL,
+		},
+		OIDC: OIDCConfig{
+			Scopes:      oidc.DefaultScopes,
+			GroupsClaim: oidc.DefaultGroupsClaim,
+			LoginTTL:    DefaultOIDCLoginTTL,
+		},
+		Catalog: CatalogConfig{
+			PageSize: DefaultPageSize,
+		},
+		Storage: StorageConfig{
+			DataDir: DefaultDataDir,
+		},
+	}
+}
+
+// Validate reports every invalid setting of the configuration.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr must not be empty"))
+	}
+	timeouts := []struct {
+		key     string
+		timeout time.Duration
+	}{
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+		{"server.request_timeout", c.Server.RequestTimeout},
+	}
+	for _, t := range timeouts {
+		if t.timeout < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", t.key))
+		}
+	}
+
+	for route, timeout := range c.Server.RouteTimeouts {
+		method, path, ok := strings.Cut(route, " ")
+		if !ok || method == "" || !strings.HasPrefix(path, "/") {
+			errs = append(errs, fmt.Errorf("server.route_timeouts: route %q must be of the form \"METHOD /path\"", route))
+		}
+		if timeout <= 0 {
+			errs = append(errs, fmt.Errorf("server.route_timeouts: timeout of route %q must be positive", route))
+		}
+	}
+	if c.Server.MaxBodySize <= 0 {
+		errs = append(errs, errors.New("server.max_body_size must be positive"))
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("server.trusted_proxies: %q is neither an IP nor a CIDR", proxy))
+		}
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls.cert_file and tls.key_file must be set together"))
+	}
+	if c.TLS.IsAdminClientCertRequired() && !c.TLS.IsEnabled() {
+		errs = append(errs, errors.New("tls.client_ca_file requires tls.cert_file and tls.key_file"))
+	}
+	if c.TLS.ReloadPeriod < 0 {
+		errs = append(errs, errors.New("tls.reload_period must not be negative"))
+	}
+	if c.TLS.HSTSMaxAge < 0 {
+		errs = append(errs, errors.New("tls.hsts_max_age must not be negative"))
+	}
+
+	if c.Environment == "" {
+		errs = append(errs, errors.New("environment must not be empty"))
+	}
+
+	allowOrigins := c.CORS.GetAllowOrigins(c.Environment)
+	if len(allowOrigins) == 0 {
+		errs = append(errs, fmt.Errorf("cors.allow_origins must not be empty in environment %q", c.Environment))
+	}
+	for _, origin := range allowOrigins {
+		if origin == anyOrigin && c.CORS.AllowCredentials {
+			errs = append(errs, errors.New("cors.allow_credentials must not be set with origin \"*\""))
+		}
+		if err := validateOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("cors.allow_origins: %w", err))
+		}
+	}
+	if c.CORS.MaxAge < 0 {
+		errs = append(errs, errors.New("cors.max_age must not be negative"))
+	}
+
+	if c.Auth.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.access_token_ttl must be positive"))
+	}
+	if c.Auth.RefreshTokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.refresh_token_ttl must be positive"))
+	}
+	// the issuer is the prefix of the label of provisioning URIs
+	if c.Auth.TOTPIssuer == "" || strings.Contains(c.Auth.TOTPIssuer, ":") {
+		errs = append(errs, errors.New("auth.totp_issuer must not be empty or contain \":\""))
+	}
+	if c.Auth.ChallengeTTL <= 0 {
+		errs = append(errs, errors.New("auth.challenge_ttl must be positive"))
+	}
+
+	if c.OIDC.IsEnabled() {
+		errs = append(errs, c.OIDC.validate()...)
+	}
+
+	if c.Catalog.PageSize == 0 {
+		errs = append(errs, errors.New("catalog.page_size must be positive"))
+	}
+
+	if c.Storage.DataDir == "" {
+		errs = append(errs, errors.New("storage.data_dir must not be empty"))
+	}
+
+	if _, err := c.I18n.NewTranslator(); err != nil {
+		errs = append(errs, fmt.Errorf("i18n.locales_dir: %w", err))
+	}
+
+	if _, err := c.Moderation.LoadBlockedWords(); err != nil {
+		errs = append(errs, fmt.Errorf("moderation.blocked_words_file: %w", err))
+	}
+
+	if c.Metrics.IsSeparate() && c.Metrics.Addr == c.Server.Addr {
+		errs = append(errs, errors.New("metrics.addr must differ from server.addr"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c OIDCConfig) validate() []error {
+	var errs []error
+
+	for _, u := range []struct {
+		key string
+		url string
+	}{
+		{"oidc.issuer_url", c.IssuerURL},
+		{"oidc.redirect_url", c.RedirectURL},
+	} {
+		parsed, err := url.Parse(u.url)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("%s must be an absolute http(s) URL", u.key))
+		}
+	}
+	if c.ClientID == "" {
+		errs = append(errs, errors.New("oidc.client_id must not be empty"))
+	}
+	if c.GroupsClaim == "" {
+		errs = append(errs, errors.New("oidc.groups_claim must not be empty"))
+	}
+	if c.LoginTTL <= 0 {
+		errs = append(errs, errors.New("oidc.login_ttl must be positive"))
+	}
+
+	// without mappings nobody could sign in
+	if len(c.GroupRoles) == 0 {
+		errs = append(errs, errors.New("oidc.group_roles must not be empty"))
+	}
+	for _, groupRole := range c.GroupRoles {
+		if groupRole.Group == "" || groupRole.Role == "" {
+			errs = append(errs, errors.New("oidc.group_roles: group and role must not be empty"))
+		}
+		if groupRole.Role == oidc.ReaderRole {
+			errs = append(errs, fmt.Errorf("oidc.group_roles: group %q must not be mapped to role %q, readers sign in with a password", groupRole.Group, groupRole.Role))
+		}
+	}
+
+	return errs
+}
+
+// validateOrigin accepts "*", exact origins and patterns of subdomains of a domain.
+// Any other wildcard would allow unrelated sites, e.g. https://*booksmart.ru
+// allows https://evilbooksmart.ru.
+func validateOrigin(origin string) error {
+	if origin == anyOrigin {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("origin %q must be of the form scheme://host[:port]", origin)
+	}
+	if u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("origin %q must not contain a path, query or credentials", origin)
+	}
+
+	host := strings.TrimPrefix(u.Hostname(), subdomainPrefix)
+	if strings.Contains(host, anyOrigin) || (host != u.Hostname() && !strings.Contains(host, ".")) {
+		return fmt.Errorf("origin %q may only start with \"*.\" followed by a domain", origin)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateCORS(t *testing.T) {
+	tests := []struct {
+		name             string
+		allowOrigins     []string
+		allowCredentials bool
+		wantErr          string
+	}{
+		{"any origin", []string{"*"}, false, ""},
+		{"any origin with credentials", []string{"*"}, true, "cors.allow_credentials must not be set with origin \"*\""},
+		{"exact origins with credentials", []string{"https://app.example.com", "http://localhost:3000"}, true, ""},
+		{"subdomains", []string{"https://*.example.com"}, true, ""},
+		{"subdomains of a top-level domain", []string{"https://*.com"}, false, "may only start with \"*.\" followed by a domain"},
+		{"wildcard inside the host", []string{"https://app*.example.com"}, false, "may only start with \"*.\" followed by a domain"},
+		{"path", []string{"https://app.example.com/"}, false, "must not contain a path, query or credentials"},
+		{"credentials in the origin", []string{"https://user@app.example.com"}, false, "must not contain a path, query or credentials"},
+		{"other scheme", []string{"ftp://app.example.com"}, false, "must be of the form scheme://host[:port]"},
+		{"no scheme", []string{"app.example.com"}, false, "must be of the form scheme://host[:port]"},
+		{"no origins", nil, false, "cors.allow_origins must not be empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.CORS.AllowOrigins = tt.allowOrigins
+			cfg.CORS.AllowCredentials = tt.allowCredentials
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCORSEnvironmentOrigins(t *testing.T) {
+	cfg := Default()
+	cfg.Environment = "production"
+	cfg.CORS.AllowOrigins = nil
+	cfg.CORS.AllowCredentials = true
+	cfg.CORS.EnvironmentOrigins = map[string][]string{
+		"production":  {"https://booksmart.example"},
+		"development": {"*"},
+	}
+
+	// origins of other environments are not validated against allow_credentials
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	cfg.Environment = "development"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("origin \"*\" with credentials in the development environment is accepted")
+	}
+}
+
+func TestLoadBlockedWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocked_words.txt")
+	if err := os.WriteFile(path, []byte("# reviews with these words are moderated\nspoiler\n\nscam\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Default()
+	cfg.Moderation.BlockedWordsFile = path
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	words, err := cfg.Moderation.LoadBlockedWords()
+	if err != nil || strings.Join(words, ",") != "spoiler,scam" {
+		t.Fatalf("got words %v and error %v, want spoiler and scam", words, err)
+	}
+
+	cfg.Moderation.BlockedWordsFile = filepath.Join(t.TempDir(), "missing.txt")
+	if err = cfg.Validate(); err == nil || !strings.Contains(err.Error(), "moderation.blocked_words_file") {
+		t.Fatalf("got error %v, want one about moderation.blocked_words_file", err)
+	}
+}
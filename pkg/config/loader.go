@@ -0,0 +1,210 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EnvPrefix     = "BOOKSMART_"
+	EnvConfigFile = EnvPrefix + "CONFIG"
+	FlagConfig    = "config"
+)
+
+// setting is a single value of Config which may be overridden by an environment
+// variable, e.g. BOOKSMART_SERVER_ADDR, or a flag, e.g. -server.addr.
+type setting struct {
+	key    string
+	usage  string
+	isBool bool // the flag may be passed without a value
+	set    func(cfg *Config, value string) error
+}
+
+func (s setting) envName() string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_").Replace(s.key))
+}
+
+var settings = []setting{
+	stringSetting("environment", "name of the environment selecting cors.environment_origins", func(cfg *Config) *string { return &cfg.Environment }),
+	stringSetting("server.addr", "address to listen on", func(cfg *Config) *string { return &cfg.Server.Addr }),
+	durationSetting("server.read_header_timeout", "max duration of reading request headers", func(cfg *Config) *time.Duration { return &cfg.Server.ReadHeaderTimeout }),
+	durationSetting("server.read_timeout", "max duration of reading a request", func(cfg *Config) *time.Duration { return &cfg.Server.ReadTimeout }),
+	durationSetting("server.write_timeout", "max duration of writing a response", func(cfg *Config) *time.Duration { return &cfg.Server.WriteTimeout }),
+	durationSetting("server.idle_timeout", "max duration of waiting for the next request of a keep-alive connection", func(cfg *Config) *time.Duration { return &cfg.Server.IdleTimeout }),
+	durationSetting("server.shutdown_timeout", "max duration of draining in-flight requests on shutdown", func(cfg *Config) *time.Duration { return &cfg.Server.ShutdownTimeout }),
+	durationSetting("server.request_timeout", "deadline of handling a request, zero disables it", func(cfg *Config) *time.Duration { return &cfg.Server.RequestTimeout }),
+	listSetting("server.trusted_proxies", "comma-separated IPs or CIDRs of reverse proxies trusted to set X-Forwarded-For", func(cfg *Config) *[]string { return &cfg.Server.TrustedProxies }),
+	int64Setting("server.max_body_size", "max size of a request body in bytes", func(cfg *Config) *int64 { return &cfg.Server.MaxBodySize }),
+	stringSetting("tls.cert_file", "certificate file enabling HTTPS", func(cfg *Config) *string { return &cfg.TLS.CertFile }),
+	stringSetting("tls.key_file", "private key file of the certificate", func(cfg *Config) *string { return &cfg.TLS.KeyFile }),
+	durationSetting("tls.reload_period", "period of checking the certificate files for changes", func(cfg *Config) *time.Duration { return &cfg.TLS.ReloadPeriod }),
+	stringSetting("tls.client_ca_file", "CA certificates verifying client certificates required for the admin routes", func(cfg *Config) *string { return &cfg.TLS.ClientCAFile }),
+	durationSetting("tls.hsts_max_age", "max-age of the Strict-Transport-Security header, zero disables it", func(cfg *Config) *time.Duration { return &cfg.TLS.HSTSMaxAge }),
+	listSetting("cors.allow_origins", "comma-separated origins allowed to make cross-origin requests", func(cfg *Config) *[]string { return &cfg.CORS.AllowOrigins }),
+	boolSetting("cors.allow_credentials", "allow cross-origin requests with credentials", func(cfg *Config) *bool { return &cfg.CORS.AllowCredentials }),
+	durationSetting("cors.max_age", "how long browsers cache preflight responses", func(cfg *Config) *time.Duration { return &cfg.CORS.MaxAge }),
+	durationSetting("auth.access_token_ttl", "lifetime of access tokens", func(cfg *Config) *time.Duration { return &cfg.Auth.AccessTokenTTL }),
+	durationSetting("auth.refresh_token_ttl", "lifetime of refresh tokens", func(cfg *Config) *time.Duration { return &cfg.Auth.RefreshTokenTTL }),
+	stringSetting("auth.totp_issuer", "issuer shown by authenticator apps", func(cfg *Config) *string { return &cfg.Auth.TOTPIssuer }),
+	durationSetting("auth.challenge_ttl", "max duration of entering the two-factor code after the password", func(cfg *Config) *time.Duration { return &cfg.Auth.ChallengeTTL }),
+	stringSetting("oidc.issuer_url", "issuer of the OpenID Connect provider enabling sign-in of staff", func(cfg *Config) *string { return &cfg.OIDC.IssuerURL }),
+	stringSetting("oidc.client_id", "client ID registered at the OpenID Connect provider", func(cfg *Config) *string { return &cfg.OIDC.ClientID }),
+	stringSetting("oidc.client_secret", "client secret registered at the OpenID Connect provider", func(cfg *Config) *string { return &cfg.OIDC.ClientSecret }),
+	stringSetting("oidc.redirect_url", "URL of the OIDC callback route", func(cfg *Config) *string { return &cfg.OIDC.RedirectURL }),
+	listSetting("oidc.scopes", "comma-separated scopes requested from the OpenID Connect provider", func(cfg *Config) *[]string { return &cfg.OIDC.Scopes }),
+	stringSetting("oidc.groups_claim", "ID token claim listing groups of the user", func(cfg *Config) *string { return &cfg.OIDC.GroupsClaim }),
+	durationSetting("oidc.login_ttl", "max duration of a login at the OpenID Connect provider", func(cfg *Config) *time.Duration { return &cfg.OIDC.LoginTTL }),
+	uintSetting("catalog.page_size", "number of books on a catalog page", func(cfg *Config) *uint { return &cfg.Catalog.PageSize }),
+	stringSetting("i18n.locales_dir", "directory of message catalogs adding languages or overriding messages", func(cfg *Config) *string { return &cfg.I18n.LocalesDir }),
+	stringSetting("storage.data_dir", "directory of the files keeping state between restarts", func(cfg *Config) *string { return &cfg.Storage.DataDir }),
+	stringSetting("moderation.blocked_words_file", "file of words holding reviews for moderation, one per line", func(cfg *Config) *string { return &cfg.Moderation.BlockedWordsFile }),
+	stringSetting("metrics.addr", "address of a separate server of /metrics, empty serves it on server.addr", func(cfg *Config) *string { return &cfg.Metrics.Addr }),
+	stringSetting("audit.file", "audit log file, audit.jsonl in storage.data_dir if empty", func(cfg *Config) *string { return &cfg.Audit.File }),
+}
+
+// Load reads the configuration from the defaults, the file passed with -config or
+// BOOKSMART_CONFIG, environment variables and flags of args, each source overriding
+// the previous ones.
+func Load(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("booksmart", flag.ContinueOnError)
+	configFile := fs.String(FlagConfig, os.Getenv(EnvConfigFile), "YAML or JSON configuration file")
+
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		key, usage := s.key, fmt.Sprintf("%s (env %s)", s.usage, s.envName())
+		setValue := func(value string) error {
+			flagValues[key] = value
+			return nil
+		}
+		if s.isBool {
+			fs.BoolFunc(key, usage, setValue)
+		} else {
+			fs.Func(key, usage, setValue)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := Default()
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := os.LookupEnv(s.envName())
+		if !ok {
+			continue
+		}
+		if err := s.set(cfg, value); err != nil {
+			return nil, fmt.Errorf("env %s: %w", s.envName(), err)
+		}
+	}
+
+	for _, s := range settings {
+		value, ok := flagValues[s.key]
+		if !ok {
+			continue
+		}
+		if err := s.set(cfg, value); err != nil {
+			return nil, fmt.Errorf("flag -%s: %w", s.key, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile overrides cfg by the settings of a YAML file. JSON files are accepted too,
+// since JSON is a subset of YAML.
+func loadFile(cfg *Config, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func stringSetting(key, usage string, field func(cfg *Config) *string) setting {
+	return setting{key: key, usage: usage, set: func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}}
+}
+
+func listSetting(key, usage string, field func(cfg *Config) *[]string) setting {
+	return setting{key: key, usage: usage, set: func(cfg *Config, value string) error {
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		*field(cfg) = values
+		return nil
+	}}
+}
+
+func boolSetting(key, usage string, field func(cfg *Config) *bool) setting {
+	return setting{key: key, usage: usage, isBool: true, set: func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = b
+		return nil
+	}}
+}
+
+func durationSetting(key, usage string, field func(cfg *Config) *time.Duration) setting {
+	return setting{key: key, usage: usage, set: func(cfg *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = d
+		return nil
+	}}
+}
+
+func uintSetting(key, usage string, field func(cfg *Config) *uint) setting {
+	return setting{key: key, usage: usage, set: func(cfg *Config, value string) error {
+		u, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = uint(u)
+		return nil
+	}}
+}
+
+func int64Setting(key, usage string, field func(cfg *Config) *int64) setting {
+	return setting{key: key, usage: usage, set: func(cfg *Config, value string) error {
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		*field(cfg) = i
+		return nil
+	}}
+}
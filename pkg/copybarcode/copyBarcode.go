@@ -0,0 +1,47 @@
+package copybarcode
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/errs"
+	"strconv"
+	"strings"
+)
+
+// Book copy barcode is the book ID without dashes followed by the copy number:
+// "0f8fad5bd9cb469fa16570867728950e-3". A bare book ID refers to the book without a copy number.
+const separator = "-"
+
+type CopyBarcode struct {
+	BookID  uuid.UUID
+	CopyNum uint
+}
+
+func Format(bookID uuid.UUID, copyNum uint) string {
+	return fmt.Sprintf("%s%s%d", strings.ReplaceAll(bookID.String(), "-", ""), separator, copyNum)
+}
+
+func Parse(code string) (*CopyBarcode, error) {
+	code = strings.TrimSpace(code)
+
+	if bookID, err := uuid.Parse(code); err == nil {
+		return &CopyBarcode{BookID: bookID}, nil
+	}
+
+	idPart, copyPart, ok := strings.Cut(code, separator)
+	if !ok {
+		return nil, errs.ErrInvalidBookCopyBarcode
+	}
+
+	bookID, err := uuid.Parse(idPart)
+	if err != nil {
+		return nil, errs.ErrInvalidBookCopyBarcode
+	}
+
+	copyNum, err := strconv.ParseUint(copyPart, 10, 0)
+	if err != nil || copyNum == 0 {
+		return nil, errs.ErrInvalidBookCopyBarcode
+	}
+
+	return &CopyBarcode{BookID: bookID, CopyNum: uint(copyNum)}, nil
+}
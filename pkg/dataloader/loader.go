@@ -0,0 +1,116 @@
+package dataloader
+
+import (
+	"context"
+	"sync"
+)
+
+const DefaultMaxParallelism = 8
+
+// FetchFunc loads a single value by key, e.g. IBookService.GetByID.
+type FetchFunc[K comparable, V any] func(ctx context.Context, key K) (V, error)
+
+type result[V any] struct {
+	value V
+	err   error
+	done  chan struct{}
+}
+
+// Loader deduplicates keys, fetches them concurrently with bounded parallelism
+// and caches results for its lifetime. It is meant to be created per request.
+type Loader[K comparable, V any] struct {
+	fetch          FetchFunc[K, V]
+	maxParallelism int
+
+	mu    sync.Mutex
+	cache map[K]*result[V]
+}
+
+func NewLoader[K comparable, V any](fetch FetchFunc[K, V], maxParallelism int) *Loader[K, V] {
+	if maxParallelism < 1 {
+		maxParallelism = 1
+	}
+
+	return &Loader[K, V]{
+		fetch:          fetch,
+		maxParallelism: maxParallelism,
+		cache:          make(map[K]*result[V]),
+	}
+}
+
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	values, err := l.LoadMany(ctx, []K{key})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+
+	return values[key], nil
+}
+
+// LoadMany returns values for all keys. The first fetch error (in keys order) is returned.
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) (map[K]V, error) {
+	var (
+		results = make(map[K]*result[V], len(keys))
+		pending []K
+	)
+
+	l.mu.Lock()
+	for _, key := range keys {
+		if _, ok := results[key]; ok {
+			continue
+		}
+
+		res, ok := l.cache[key]
+		if !ok {
+			res = &result[V]{done: make(chan struct{})}
+			l.cache[key] = res
+			pending = append(pending, key)
+		}
+		results[key] = res
+	}
+	l.mu.Unlock()
+
+	l.fetchPending(ctx, pending, results)
+
+	values := make(map[K]V, len(results))
+	for _, key := range keys {
+		res := results[key]
+		select {
+		case <-res.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if res.err != nil {
+			return nil, res.err
+		}
+		values[key] = res.value
+	}
+
+	return values, nil
+}
+
+func (l *Loader[K, V]) fetchPending(ctx context.Context, pending []K, results map[K]*result[V]) {
+	var (
+		wg        sync.WaitGroup
+		semaphore = make(chan struct{}, l.maxParallelism)
+	)
+
+	for _, key := range pending {
+		res := results[key]
+		semaphore <- struct{}{}
+		wg.Add(1)
+
+		go func(key K) {
+			defer func() {
+				<-semaphore
+				close(res.done)
+				wg.Done()
+			}()
+
+			res.value, res.err = l.fetch(ctx, key)
+		}(key)
+	}
+
+	wg.Wait()
+}
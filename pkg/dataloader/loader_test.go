@@ -0,0 +1,216 @@
+package dataloader
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	benchmarkKeys        = 50
+	benchmarkUniqueKeys  = 20
+	benchmarkStubLatency = time.Millisecond
+)
+
+// stubFetch imitates a service call, e.g. IBookService.GetByID, over the network.
+func stubFetch(ctx context.Context, key int) (int, error) {
+	select {
+	case <-time.After(benchmarkStubLatency):
+		return key, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// benchmarkKeyList repeats keys, e.g. reservations of the same book.
+func benchmarkKeyList() []int {
+	keys := make([]int, benchmarkKeys)
+	for i := range keys {
+		keys[i] = i % benchmarkUniqueKeys
+	}
+
+	return keys
+}
+
+func BenchmarkSequential(b *testing.B) {
+	ctx, keys := context.Background(), benchmarkKeyList()
+
+	for i := 0; i < b.N; i++ {
+		values := make(map[int]int, len(keys))
+		for _, key := range keys {
+			value, err := stubFetch(ctx, key)
+			if err != nil {
+				b.Fatal(err)
+			}
+			values[key] = value
+		}
+	}
+}
+
+func BenchmarkLoader(b *testing.B) {
+	ctx, keys := context.Background(), benchmarkKeyList()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := NewLoader(stubFetch, DefaultMaxParallelism).LoadMany(ctx, keys); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkLoaderShared loads the keys twice with one loader, as helpers of a request do.
+func BenchmarkLoaderShared(b *testing.B) {
+	ctx, keys := context.Background(), benchmarkKeyList()
+
+	for i := 0; i < b.N; i++ {
+		loader := NewLoader(stubFetch, DefaultMaxParallelism)
+		for j := 0; j < 2; j++ {
+			if _, err := loader.LoadMany(ctx, keys); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// countingFetch returns the key and records the calls and the peak of concurrent ones.
+type countingFetch struct {
+	mu          sync.Mutex
+	calls       map[int]int
+	running     int
+	maxRunning  int
+	failingKeys map[int]error
+}
+
+func newCountingFetch() *countingFetch {
+	return &countingFetch{calls: make(map[int]int), failingKeys: make(map[int]error)}
+}
+
+func (cf *countingFetch) fetch(ctx context.Context, key int) (int, error) {
+	cf.mu.Lock()
+	cf.calls[key]++
+	cf.running++
+	cf.maxRunning = max(cf.maxRunning, cf.running)
+	err := cf.failingKeys[key]
+	cf.mu.Unlock()
+
+	defer func() {
+		cf.mu.Lock()
+		cf.running--
+		cf.mu.Unlock()
+	}()
+
+	value, fetchErr := stubFetch(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	return value, fetchErr
+}
+
+func TestLoadManyDeduplicatesKeys(t *testing.T) {
+	cf := newCountingFetch()
+	loader := NewLoader(cf.fetch, DefaultMaxParallelism)
+
+	values, err := loader.LoadMany(context.Background(), []int{1, 2, 1, 3, 2, 1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(values) != 3 || values[1] != 1 || values[2] != 2 || values[3] != 3 {
+		t.Errorf("got values %v, want keys 1, 2 and 3 mapped to themselves", values)
+	}
+	for key, calls := range cf.calls {
+		if calls != 1 {
+			t.Errorf("key %d is fetched %d times, want once", key, calls)
+		}
+	}
+}
+
+func TestLoaderCachesResults(t *testing.T) {
+	cf := newCountingFetch()
+	loader := NewLoader(cf.fetch, DefaultMaxParallelism)
+	ctx := context.Background()
+
+	if _, err := loader.LoadMany(ctx, []int{1, 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := loader.LoadMany(ctx, []int{2, 3}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value, err := loader.Load(ctx, 1); err != nil || value != 1 {
+		t.Fatalf("got value %d and error %v, want 1", value, err)
+	}
+
+	if got := cf.calls[1] + cf.calls[2] + cf.calls[3]; got != 3 {
+		t.Errorf("got %d fetches for 3 keys loaded repeatedly, want 3", got)
+	}
+
+	// a new loader, e.g. of the next request, does not see the cache
+	if _, err := NewLoader(cf.fetch, DefaultMaxParallelism).Load(ctx, 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cf.calls[1] != 2 {
+		t.Errorf("key 1 is fetched %d times by two loaders, want 2", cf.calls[1])
+	}
+}
+
+func TestLoaderReturnsErrorsToEachCaller(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	cf := newCountingFetch()
+	cf.failingKeys[2] = errFetch
+	loader := NewLoader(cf.fetch, DefaultMaxParallelism)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = loader.LoadMany(ctx, []int{1, 2, 3})
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if !errors.Is(err, errFetch) {
+			t.Errorf("caller %d got error %v, want %v", i, err, errFetch)
+		}
+	}
+	if cf.calls[2] != 1 {
+		t.Errorf("failing key is fetched %d times, want once", cf.calls[2])
+	}
+
+	// keys loaded without the failing one succeed
+	if value, err := loader.Load(ctx, 3); err != nil || value != 3 {
+		t.Errorf("got value %d and error %v, want 3", value, err)
+	}
+}
+
+func TestLoaderBoundsParallelism(t *testing.T) {
+	const maxParallelism = 3
+
+	cf := newCountingFetch()
+	loader := NewLoader(cf.fetch, maxParallelism)
+
+	if _, err := loader.LoadMany(context.Background(), benchmarkKeyList()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cf.maxRunning > maxParallelism {
+		t.Errorf("got %d concurrent fetches, want at most %d", cf.maxRunning, maxParallelism)
+	}
+	if cf.maxRunning < 2 {
+		t.Errorf("got %d concurrent fetches, want keys fetched concurrently", cf.maxRunning)
+	}
+}
+
+func TestLoadManyStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := NewLoader(stubFetch, DefaultMaxParallelism).LoadMany(ctx, []int{1, 2}); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
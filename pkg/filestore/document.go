@@ -0,0 +1,193 @@
+package filestore
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// IDocument is a value of a store kept in a single JSON file.
+type IDocument[T any] interface {
+	// Read calls fn with the value, fn must neither change it nor keep references to it.
+	Read(fn func(value *T) error) error
+	// Update calls fn to change the value and saves it. The changes are discarded
+	// if fn returns an error or the value can not be saved.
+	Update(fn func(value *T) error) error
+	// Touch changes the value without saving it, the changes are saved by the next
+	// Update or Flush. It suits frequent changes which may be lost, e.g. counters.
+	Touch(fn func(value *T)) error
+	// Flush saves changes made by Touch.
+	Flush() error
+}
+
+// Document reads the file on first use and replaces it atomically on every save,
+// so that a crash leaves either the previous or the new version. An empty path
+// keeps the value in memory only.
+type Document[T any] struct {
+	mu       sync.Mutex
+	path     string
+	newValue func() *T
+	value    *T
+	saved    []byte // encoding of the saved value, restored when an update fails
+	isDirty  bool
+}
+
+func NewDocument[T any](path string, newValue func() *T) IDocument[T] {
+	return &Document[T]{path: path, newValue: newValue}
+}
+
+func (d *Document[T]) Read(fn func(value *T) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.load(); err != nil {
+		return err
+	}
+
+	return fn(d.value)
+}
+
+func (d *Document[T]) Update(fn func(value *T) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.load(); err != nil {
+		return err
+	}
+
+	if err := fn(d.value); err != nil {
+		d.restore()
+		return err
+	}
+	if err := d.save(); err != nil {
+		d.restore()
+		return err
+	}
+
+	return nil
+}
+
+func (d *Document[T]) Touch(fn func(value *T)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := d.load(); err != nil {
+		return err
+	}
+
+	fn(d.value)
+	d.isDirty = true
+
+	return nil
+}
+
+func (d *Document[T]) Flush() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.isDirty {
+		return nil
+	}
+
+	return d.save()
+}
+
+func (d *Document[T]) load() error {
+	if d.value != nil {
+		return nil
+	}
+
+	value := d.newValue()
+	if d.path != "" {
+		data, err := os.ReadFile(d.path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if len(data) > 0 {
+			if err = json.Unmarshal(data, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	saved, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	d.value, d.saved = value, saved
+
+	return nil
+}
+
+func (d *Document[T]) save() error {
+	data, err := json.Marshal(d.value)
+	if err != nil {
+		return err
+	}
+
+	if d.path != "" {
+		if err = writeFile(d.path, data); err != nil {
+			return err
+		}
+	}
+
+	d.saved, d.isDirty = data, false
+
+	return nil
+}
+
+// restore discards the changes since the last save, including those made by Touch.
+func (d *Document[T]) restore() {
+	value := d.newValue()
+	if err := json.Unmarshal(d.saved, value); err != nil {
+		// the file is read again on next use
+		d.value = nil
+		return
+	}
+
+	d.value, d.isDirty = value, false
+}
+
+// writeFile writes a temporary file and renames it over the file.
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0o600); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// the rename is durable once the directory is synced
+	if dirFile, err := os.Open(dir); err == nil {
+		_ = dirFile.Sync()
+		_ = dirFile.Close()
+	}
+
+	return nil
+}
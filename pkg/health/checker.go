@@ -0,0 +1,94 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// IChecker reports whether a dependency of the API is usable.
+type IChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type Checker struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// NewChecker makes a checker of a plain function.
+func NewChecker(name string, check func(ctx context.Context) error) IChecker {
+	return &Checker{name: name, check: check}
+}
+
+func (c *Checker) Name() string {
+	return c.name
+}
+
+func (c *Checker) Check(ctx context.Context) error {
+	return c.check(ctx)
+}
+
+type Result struct {
+	Name     string
+	Status   string
+	Error    string
+	Duration time.Duration
+}
+
+// RunChecks runs all checkers concurrently, each one limited by timeout,
+// and returns their results in the order of checkers.
+func RunChecks(ctx context.Context, checkers []IChecker, timeout time.Duration) ([]Result, bool) {
+	results := make([]Result, len(checkers))
+
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func(i int, checker IChecker) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, checker, timeout)
+		}(i, checker)
+	}
+	wg.Wait()
+
+	isReady := true
+	for _, result := range results {
+		if result.Status != StatusUp {
+			isReady = false
+		}
+	}
+
+	return results, isReady
+}
+
+func runCheck(ctx context.Context, checker IChecker, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Name: checker.Name(), Status: StatusUp, Duration: time.Since(start)}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,176 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when the client accepts none of the catalog languages.
+const DefaultLanguage = "ru"
+
+//go:embed locales/*.json
+var embeddedLocales embed.FS
+
+// ITranslator provides messages of the API in the language of the client.
+type ITranslator interface {
+	Translate(lang, key string, args ...any) string
+	Negotiate(acceptLanguage string) string
+	HasMessage(key string) bool
+}
+
+// Translator keeps message catalogs by language. A catalog is a flat JSON object
+// of message keys and texts in a file named after the language, e.g. "en.json".
+type Translator struct {
+	catalogs    map[string]map[string]string
+	defaultLang string
+}
+
+// NewTranslator loads the built-in catalogs and then the catalogs of localesDirs,
+// which add languages or override messages without rebuilding the API.
+func NewTranslator(defaultLang string, localesDirs ...string) (ITranslator, error) {
+	t := &Translator{
+		catalogs:    make(map[string]map[string]string),
+		defaultLang: defaultLang,
+	}
+
+	if err := t.loadCatalogs(embeddedLocales, "locales"); err != nil {
+		return nil, err
+	}
+	for _, dir := range localesDirs {
+		// a mistyped directory would otherwise be skipped as one without catalogs
+		if _, err := os.ReadDir(dir); err != nil {
+			return nil, err
+		}
+		if err := t.loadCatalogs(os.DirFS(dir), "."); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := t.catalogs[defaultLang]; !ok {
+		return nil, fmt.Errorf("no message catalog for default language %q", defaultLang)
+	}
+
+	return t, nil
+}
+
+// NewDefaultTranslator uses only the built-in catalogs, which are verified at build time.
+func NewDefaultTranslator() ITranslator {
+	t, err := NewTranslator(DefaultLanguage)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+// Translate returns the message of the language, falling back to the default
+// language and then to the key itself. Args are formatted as in fmt.Sprintf.
+func (t *Translator) Translate(lang, key string, args ...any) string {
+	message, ok := t.catalogs[lang][key]
+	if !ok {
+		message, ok = t.catalogs[t.defaultLang][key]
+	}
+	if !ok {
+		message = key
+	}
+
+	if len(args) == 0 {
+		return message
+	}
+
+	return fmt.Sprintf(message, args...)
+}
+
+// HasMessage reports whether the default language has a message for key.
+func (t *Translator) HasMessage(key string) bool {
+	_, ok := t.catalogs[t.defaultLang][key]
+
+	return ok
+}
+
+type languageRange struct {
+	tag     string
+	quality float64
+}
+
+// Negotiate picks the catalog language for the Accept-Language header, e.g.
+// "en-US,en;q=0.9,ru;q=0.8". A regional tag matches the catalog of its base language.
+func (t *Translator) Negotiate(acceptLanguage string) string {
+	var ranges []languageRange
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		ranges = append(ranges, languageRange{tag: strings.ToLower(tag), quality: quality})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		if r.tag == "*" {
+			return t.defaultLang
+		}
+		if _, ok := t.catalogs[r.tag]; ok {
+			return r.tag
+		}
+
+		base, _, _ := strings.Cut(r.tag, "-")
+		if _, ok := t.catalogs[base]; ok {
+			return base
+		}
+	}
+
+	return t.defaultLang
+}
+
+func (t *Translator) loadCatalogs(fsys fs.FS, dir string) error {
+	paths, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range paths {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+
+		var messages map[string]string
+		if err = json.Unmarshal(data, &messages); err != nil {
+			return fmt.Errorf("message catalog %s: %w", file, err)
+		}
+
+		lang := strings.ToLower(strings.TrimSuffix(path.Base(file), ".json"))
+		if t.catalogs[lang] == nil {
+			t.catalogs[lang] = make(map[string]string, len(messages))
+		}
+		for key, message := range messages {
+			t.catalogs[lang][key] = message
+		}
+	}
+
+	return nil
+}
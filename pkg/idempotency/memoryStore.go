@@ -0,0 +1,88 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IStore keeps responses by idempotency key. Begin reserves a new key for the
+// request and returns nil, or returns the record of a key which is already known.
+// A reserved key is either completed with the response or released, so that
+// the request can be retried.
+type IStore interface {
+	Begin(ctx context.Context, key, requestHash string, ttl time.Duration) (*Record, error)
+	Complete(ctx context.Context, key string, record *Record) error
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryStore keeps records of a single instance. Expired records are dropped on sweeps.
+type MemoryStore struct {
+	mu          sync.Mutex
+	records     map[string]*Record
+	sweepPeriod time.Duration
+	sweptAt     time.Time
+	now         func() time.Time
+}
+
+const defaultSweepPeriod = time.Minute
+
+func NewMemoryStore() IStore {
+	return &MemoryStore{
+		records:     make(map[string]*Record),
+		sweepPeriod: defaultSweepPeriod,
+		sweptAt:     time.Now(),
+		now:         time.Now,
+	}
+}
+
+func (ms *MemoryStore) Begin(_ context.Context, key, requestHash string, ttl time.Duration) (*Record, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	ms.sweep(now)
+
+	if record, ok := ms.records[key]; ok && now.Before(record.ExpiresAt) {
+		copied := *record
+		return &copied, nil
+	}
+
+	ms.records[key] = &Record{RequestHash: requestHash, ExpiresAt: now.Add(ttl)}
+
+	return nil, nil
+}
+
+func (ms *MemoryStore) Complete(_ context.Context, key string, record *Record) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if pending, ok := ms.records[key]; ok {
+		record.ExpiresAt = pending.ExpiresAt
+	}
+	ms.records[key] = record
+
+	return nil
+}
+
+func (ms *MemoryStore) Release(_ context.Context, key string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.records, key)
+
+	return nil
+}
+
+func (ms *MemoryStore) sweep(now time.Time) {
+	if now.Sub(ms.sweptAt) < ms.sweepPeriod {
+		return
+	}
+	ms.sweptAt = now
+
+	for key, record := range ms.records {
+		if !now.Before(record.ExpiresAt) {
+			delete(ms.records, key)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package idempotency
+
+import (
+	"net/http"
+	"time"
+)
+
+// Record is the response to the first request with an idempotency key. Status
+// is zero while the first request is still being handled.
+type Record struct {
+	RequestHash string
+	Status      int
+	Header      http.Header
+	Body        []byte
+	ExpiresAt   time.Time
+}
+
+func (r *Record) IsCompleted() bool {
+	return r.Status != 0
+}
@@ -0,0 +1,284 @@
+package libcard
+
+import (
+	"context"
+	"crypto/rand"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/filestore"
+	"math/big"
+	"time"
+)
+
+const (
+	libCardNumLength   = 13
+	charset            = "0123456789"
+	maxLibCardNumTries = 10
+)
+
+type Event string
+
+const (
+	EventBlocked   Event = "blocked"
+	EventUnblocked Event = "unblocked"
+	EventReplaced  Event = "replaced"
+)
+
+type StatusChange struct {
+	Event              Event
+	Reason             string
+	LibCardNum         string
+	PreviousLibCardNum string
+	ActorID            uuid.UUID
+	CreatedAt          time.Time
+}
+
+// CardState is the part of lib card lifecycle which the services layer does not store:
+// blocking and the number of a replacement card issued instead of a lost one.
+type CardState struct {
+	LibCardID   uuid.UUID
+	ReaderID    uuid.UUID
+	LibCardNum  string
+	Blocked     bool
+	BlockReason string
+	History     []*StatusChange
+}
+
+// IStatusRegistry provides storage of lib card blocks, replacements and their history.
+type IStatusRegistry interface {
+	Block(ctx context.Context, libCard *models.LibCardModel, actorID uuid.UUID, reason string) (*CardState, error)
+	Unblock(ctx context.Context, libCard *models.LibCardModel, actorID uuid.UUID, reason string) (*CardState, error)
+	Replace(ctx context.Context, libCard *models.LibCardModel, actorID uuid.UUID, reason string) (*CardState, error)
+	GetState(ctx context.Context, libCard *models.LibCardModel) (*CardState, error)
+	Index(ctx context.Context, libCard *models.LibCardModel) error
+	GetReaderIDByNum(ctx context.Context, libCardNum string) (uuid.UUID, error)
+}
+
+// IsNumTakenFunc reports whether a lib card number is used by a card of the services
+// layer, e.g. one which was never indexed by the registry.
+type IsNumTakenFunc func(ctx context.Context, libCardNum string) (bool, error)
+
+type numRecord struct {
+	ReaderID uuid.UUID
+	Replaced bool
+}
+
+type registryData struct {
+	States map[uuid.UUID]*CardState
+	Nums   map[string]*numRecord
+}
+
+type StatusRegistry struct {
+	data       filestore.IDocument[registryData]
+	isNumTaken IsNumTakenFunc
+}
+
+// NewStatusRegistry keeps the states in the file, an empty path keeps them in memory only.
+func NewStatusRegistry(path string, isNumTaken IsNumTakenFunc) IStatusRegistry {
+	return &StatusRegistry{
+		data: filestore.NewDocument(path, func() *registryData {
+			return &registryData{
+				States: make(map[uuid.UUID]*CardState),
+				Nums:   make(map[string]*numRecord),
+			}
+		}),
+		isNumTaken: isNumTaken,
+	}
+}
+
+func (sr *StatusRegistry) Block(_ context.Context, libCard *models.LibCardModel, actorID uuid.UUID, reason string) (*CardState, error) {
+	if reason == "" {
+		return nil, errs.ErrEmptyLibCardStatusReason
+	}
+
+	return sr.update(libCard, func(_ *registryData, state *CardState) error {
+		if state.Blocked {
+			return errs.ErrLibCardIsBlocked
+		}
+
+		state.Blocked = true
+		state.BlockReason = reason
+		sr.addStatusChange(state, EventBlocked, actorID, reason, "")
+
+		return nil
+	})
+}
+
+func (sr *StatusRegistry) Unblock(_ context.Context, libCard *models.LibCardModel, actorID uuid.UUID, reason string) (*CardState, error) {
+	if reason == "" {
+		return nil, errs.ErrEmptyLibCardStatusReason
+	}
+
+	return sr.update(libCard, func(_ *registryData, state *CardState) error {
+		if !state.Blocked {
+			return errs.ErrLibCardIsNotBlocked
+		}
+
+		state.Blocked = false
+		state.BlockReason = ""
+		sr.addStatusChange(state, EventUnblocked, actorID, reason, "")
+
+		return nil
+	})
+}
+
+// Replace issues a new number for a lost card. The previous number stays in the history.
+func (sr *StatusRegistry) Replace(ctx context.Context, libCard *models.LibCardModel, actorID uuid.UUID, reason string) (*CardState, error) {
+	if reason == "" {
+		return nil, errs.ErrEmptyLibCardStatusReason
+	}
+
+	return sr.update(libCard, func(data *registryData, state *CardState) error {
+		libCardNum, err := sr.generateUniqueLibCardNum(ctx, data)
+		if err != nil {
+			return err
+		}
+
+		previousLibCardNum := state.LibCardNum
+		state.LibCardNum = libCardNum
+
+		data.Nums[previousLibCardNum] = &numRecord{ReaderID: libCard.ReaderID, Replaced: true}
+		data.Nums[state.LibCardNum] = &numRecord{ReaderID: libCard.ReaderID}
+		sr.addStatusChange(state, EventReplaced, actorID, reason, previousLibCardNum)
+
+		return nil
+	})
+}
+
+func (sr *StatusRegistry) GetState(_ context.Context, libCard *models.LibCardModel) (*CardState, error) {
+	var stateCopy *CardState
+	err := sr.data.Read(func(data *registryData) error {
+		state, ok := data.States[libCard.ID]
+		if !ok {
+			stateCopy = &CardState{LibCardID: libCard.ID, ReaderID: libCard.ReaderID, LibCardNum: libCard.LibCardNum}
+			return nil
+		}
+		stateCopy = sr.copyState(state)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stateCopy, nil
+}
+
+// Index remembers the number of the lib card so that the reader can be found by a scanned card.
+func (sr *StatusRegistry) Index(_ context.Context, libCard *models.LibCardModel) error {
+	var isIndexed bool
+	err := sr.data.Read(func(data *registryData) error {
+		_, isIndexed = data.Nums[libCard.LibCardNum]
+		return nil
+	})
+	if err != nil || isIndexed {
+		return err
+	}
+
+	return sr.data.Update(func(data *registryData) error {
+		if _, ok := data.Nums[libCard.LibCardNum]; !ok {
+			data.Nums[libCard.LibCardNum] = &numRecord{ReaderID: libCard.ReaderID}
+		}
+
+		return nil
+	})
+}
+
+func (sr *StatusRegistry) GetReaderIDByNum(_ context.Context, libCardNum string) (uuid.UUID, error) {
+	var record *numRecord
+	err := sr.data.Read(func(data *registryData) error {
+		record = data.Nums[libCardNum]
+		return nil
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	if record == nil {
+		return uuid.Nil, errs.ErrLibCardNumIsUnknown
+	}
+	if record.Replaced {
+		return uuid.Nil, errs.ErrLibCardNumIsReplaced
+	}
+
+	return record.ReaderID, nil
+}
+
+// update changes the state of the lib card and returns a copy of the changed state.
+func (sr *StatusRegistry) update(libCard *models.LibCardModel, change func(data *registryData, state *CardState) error) (*CardState, error) {
+	var stateCopy *CardState
+	err := sr.data.Update(func(data *registryData) error {
+		state := sr.getOrCreateState(data, libCard)
+		if err := change(data, state); err != nil {
+			return err
+		}
+		stateCopy = sr.copyState(state)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stateCopy, nil
+}
+
+func (sr *StatusRegistry) getOrCreateState(data *registryData, libCard *models.LibCardModel) *CardState {
+	state, ok := data.States[libCard.ID]
+	if !ok {
+		state = &CardState{LibCardID: libCard.ID, ReaderID: libCard.ReaderID, LibCardNum: libCard.LibCardNum}
+		data.States[libCard.ID] = state
+	}
+
+	return state
+}
+
+func (sr *StatusRegistry) addStatusChange(state *CardState, event Event, actorID uuid.UUID, reason, previousLibCardNum string) {
+	state.History = append(state.History, &StatusChange{
+		Event:              event,
+		Reason:             reason,
+		LibCardNum:         state.LibCardNum,
+		PreviousLibCardNum: previousLibCardNum,
+		ActorID:            actorID,
+		CreatedAt:          time.Now(),
+	})
+}
+
+func (sr *StatusRegistry) copyState(state *CardState) *CardState {
+	stateCopy := *state
+	stateCopy.History = append([]*StatusChange(nil), state.History...)
+
+	return &stateCopy
+}
+
+// generateUniqueLibCardNum returns a number used neither by the registry nor by the services layer.
+func (sr *StatusRegistry) generateUniqueLibCardNum(ctx context.Context, data *registryData) (string, error) {
+	for i := 0; i < maxLibCardNumTries; i++ {
+		libCardNum := sr.generateLibCardNum()
+		if _, ok := data.Nums[libCardNum]; ok {
+			continue
+		}
+
+		isTaken, err := sr.isNumTaken(ctx, libCardNum)
+		if err != nil {
+			return "", err
+		}
+		if !isTaken {
+			return libCardNum, nil
+		}
+	}
+
+	return "", errs.ErrLibCardNumIsNotGenerated
+}
+
+func (sr *StatusRegistry) generateLibCardNum() string {
+	result := make([]byte, libCardNumLength)
+
+	for i := range result {
+		num, _ := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		result[i] = charset[num.Int64()]
+	}
+
+	return string(result)
+}
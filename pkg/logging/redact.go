@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RedactedValue replaces the values which must not be logged.
+const RedactedValue = "[REDACTED]"
+
+var sensitiveKeyParts = []string{
+	"password",
+	"token",
+	"secret",
+	"authorization",
+	"api_key",
+	"recovery_code",
+	"totp",
+}
+
+// IsSensitiveKey reports whether the value of the field must not be logged.
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RedactJSON replaces values of sensitive fields at any depth of a JSON document.
+// A body which is not valid JSON is replaced entirely, as it cannot be inspected.
+func RedactJSON(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var document any
+	if err := json.Unmarshal(body, &document); err != nil {
+		return RedactedValue
+	}
+
+	redacted, err := json.Marshal(redactValue(document))
+	if err != nil {
+		return RedactedValue
+	}
+
+	return string(redacted)
+}
+
+func redactValue(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, nested := range typed {
+			if IsSensitiveKey(key) {
+				typed[key] = RedactedValue
+				continue
+			}
+			typed[key] = redactValue(nested)
+		}
+	case []any:
+		for i, nested := range typed {
+			typed[i] = redactValue(nested)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,28 @@
+package logging
+
+import "testing"
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty", ``, ``},
+		{"not JSON", `password=secret`, RedactedValue},
+		{"sign-in", `{"phone_number":"89000000000","password":"secret"}`, `{"password":"[REDACTED]","phone_number":"89000000000"}`},
+		{"tokens", `{"refresh_token":"r","challenge_token":"c"}`, `{"challenge_token":"[REDACTED]","refresh_token":"[REDACTED]"}`},
+		{"nested", `{"keys":[{"name":"k","api_key":"s"}]}`, `{"keys":[{"api_key":"[REDACTED]","name":"k"}]}`},
+		{"recovery codes", `{"recovery_codes":["a","b"]}`, `{"recovery_codes":"[REDACTED]"}`},
+		{"problem code", `{"code":"invalid_oidc_state","status":400}`, `{"code":"invalid_oidc_state","status":400}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RedactJSON([]byte(tt.body))
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
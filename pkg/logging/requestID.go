@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+)
+
+const RequestIDField = "request_id"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the correlation ID of the request.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the correlation ID of the request or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+
+	return requestID
+}
+
+// RequestIDHook adds the correlation ID to entries logged with a request context,
+// e.g. logger.WithContext(ctx).Info(...). The services layer logs without a context,
+// its entries get the ID from the loggers of the decorators in requestServices.go.
+type RequestIDHook struct{}
+
+func NewRequestIDHook() logrus.Hook {
+	return &RequestIDHook{}
+}
+
+// AddRequestIDHook registers RequestIDHook on the logger unless it is already registered,
+// so that a logger shared by several handlers does not fire the hook more than once.
+func AddRequestIDHook(logger *logrus.Logger) {
+	for _, hook := range logger.Hooks[logrus.InfoLevel] {
+		if _, ok := hook.(*RequestIDHook); ok {
+			return
+		}
+	}
+
+	logger.AddHook(NewRequestIDHook())
+}
+
+func (hook *RequestIDHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook *RequestIDHook) Fire(entry *logrus.Entry) error {
+	if _, ok := entry.Data[RequestIDField]; ok {
+		return nil
+	}
+
+	if requestID := RequestIDFromContext(entry.Context); requestID != "" {
+		entry.Data[RequestIDField] = requestID
+	}
+
+	return nil
+}
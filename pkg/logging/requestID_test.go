@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/dto"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"github.com/nikitalystsev/BookSmart-services/intf"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"testing"
+)
+
+func TestAddRequestIDHookOnce(t *testing.T) {
+	logger := logrus.New()
+
+	AddRequestIDHook(logger)
+	AddRequestIDHook(logger)
+
+	if got := len(logger.Hooks[logrus.InfoLevel]); got != 1 {
+		t.Errorf("got %d hooks, want 1", got)
+	}
+}
+
+// loggingBookService logs as the services do, without a context.
+type loggingBookService struct {
+	intf.IBookService
+	logger *logrus.Entry
+}
+
+func (s *loggingBookService) GetByParams(_ context.Context, _ *dto.BookParamsDTO) ([]*models.BookModel, error) {
+	s.logger.Info("getting books")
+
+	return nil, nil
+}
+
+func (s *loggingBookService) GetByID(_ context.Context, _ uuid.UUID) (*models.BookModel, error) {
+	s.logger.Info("getting book")
+
+	return nil, nil
+}
+
+func TestRequestServiceLogsRequestID(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	bookService := NewBookService(func(logger *logrus.Entry) intf.IBookService {
+		return &loggingBookService{logger: logger}
+	}, logrus.NewEntry(logger))
+
+	_, _ = bookService.GetByParams(WithRequestID(context.Background(), "first"), &dto.BookParamsDTO{})
+	_, _ = bookService.GetByID(WithRequestID(context.Background(), "second"), uuid.New())
+	_, _ = bookService.GetByID(context.Background(), uuid.New())
+
+	entries := hook.AllEntries()
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	for i, want := range []string{"first", "second"} {
+		if got := entries[i].Data[RequestIDField]; got != want {
+			t.Errorf("entry %d: got request ID %v, want %q", i, got, want)
+		}
+	}
+	if _, ok := entries[2].Data[RequestIDField]; ok {
+		t.Errorf("entry without a request context got request ID %v", entries[2].Data[RequestIDField])
+	}
+}
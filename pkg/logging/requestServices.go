@@ -0,0 +1,155 @@
+package logging
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/dto"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"github.com/nikitalystsev/BookSmart-services/intf"
+	"github.com/sirupsen/logrus"
+)
+
+// The decorators below build the service for every call with a logger carrying the
+// request ID of the call context. The services take their logger at construction and
+// log without a context, so this is how their entries get the ID of the request.
+
+// NewServiceFunc builds a service logging to the given logger.
+type NewServiceFunc[S any] func(logger *logrus.Entry) S
+
+type requestService[S any] struct {
+	newService NewServiceFunc[S]
+	logger     *logrus.Entry
+}
+
+func (s requestService[S]) get(ctx context.Context) S {
+	logger := s.logger
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		logger = logger.WithField(RequestIDField, requestID)
+	}
+
+	return s.newService(logger)
+}
+
+type requestBookService struct {
+	requestService[intf.IBookService]
+}
+
+func NewBookService(newService NewServiceFunc[intf.IBookService], logger *logrus.Entry) intf.IBookService {
+	return &requestBookService{requestService[intf.IBookService]{newService: newService, logger: logger}}
+}
+
+func (s *requestBookService) Create(ctx context.Context, book *models.BookModel) error {
+	return s.get(ctx).Create(ctx, book)
+}
+
+func (s *requestBookService) Delete(ctx context.Context, ID uuid.UUID) error {
+	return s.get(ctx).Delete(ctx, ID)
+}
+
+func (s *requestBookService) GetByID(ctx context.Context, ID uuid.UUID) (*models.BookModel, error) {
+	return s.get(ctx).GetByID(ctx, ID)
+}
+
+func (s *requestBookService) GetByParams(ctx context.Context, params *dto.BookParamsDTO) ([]*models.BookModel, error) {
+	return s.get(ctx).GetByParams(ctx, params)
+}
+
+type requestLibCardService struct {
+	requestService[intf.ILibCardService]
+}
+
+func NewLibCardService(newService NewServiceFunc[intf.ILibCardService], logger *logrus.Entry) intf.ILibCardService {
+	return &requestLibCardService{requestService[intf.ILibCardService]{newService: newService, logger: logger}}
+}
+
+func (s *requestLibCardService) Create(ctx context.Context, readerID uuid.UUID) error {
+	return s.get(ctx).Create(ctx, readerID)
+}
+
+func (s *requestLibCardService) Update(ctx context.Context, libCard *models.LibCardModel) error {
+	return s.get(ctx).Update(ctx, libCard)
+}
+
+func (s *requestLibCardService) GetByReaderID(ctx context.Context, readerID uuid.UUID) (*models.LibCardModel, error) {
+	return s.get(ctx).GetByReaderID(ctx, readerID)
+}
+
+type requestReaderService struct {
+	requestService[intf.IReaderService]
+}
+
+func NewReaderService(newService NewServiceFunc[intf.IReaderService], logger *logrus.Entry) intf.IReaderService {
+	return &requestReaderService{requestService[intf.IReaderService]{newService: newService, logger: logger}}
+}
+
+func (s *requestReaderService) SignUp(ctx context.Context, reader *models.ReaderModel) error {
+	return s.get(ctx).SignUp(ctx, reader)
+}
+
+func (s *requestReaderService) SignIn(ctx context.Context, phoneNumber, password string) (*models.Tokens, error) {
+	return s.get(ctx).SignIn(ctx, phoneNumber, password)
+}
+
+func (s *requestReaderService) GetByID(ctx context.Context, ID uuid.UUID) (*models.ReaderModel, error) {
+	return s.get(ctx).GetByID(ctx, ID)
+}
+
+func (s *requestReaderService) GetByPhoneNumber(ctx context.Context, phoneNumber string) (*models.ReaderModel, error) {
+	return s.get(ctx).GetByPhoneNumber(ctx, phoneNumber)
+}
+
+func (s *requestReaderService) RefreshTokens(ctx context.Context, refreshToken string) (*models.Tokens, error) {
+	return s.get(ctx).RefreshTokens(ctx, refreshToken)
+}
+
+func (s *requestReaderService) AddToFavorites(ctx context.Context, readerID, bookID uuid.UUID) error {
+	return s.get(ctx).AddToFavorites(ctx, readerID, bookID)
+}
+
+type requestReservationService struct {
+	requestService[intf.IReservationService]
+}
+
+func NewReservationService(newService NewServiceFunc[intf.IReservationService], logger *logrus.Entry) intf.IReservationService {
+	return &requestReservationService{requestService[intf.IReservationService]{newService: newService, logger: logger}}
+}
+
+func (s *requestReservationService) Create(ctx context.Context, readerID, bookID uuid.UUID) error {
+	return s.get(ctx).Create(ctx, readerID, bookID)
+}
+
+func (s *requestReservationService) Update(ctx context.Context, reservation *models.ReservationModel, extentionPeriodDays int) error {
+	return s.get(ctx).Update(ctx, reservation, extentionPeriodDays)
+}
+
+func (s *requestReservationService) GetByBookID(ctx context.Context, bookID uuid.UUID) ([]*models.ReservationModel, error) {
+	return s.get(ctx).GetByBookID(ctx, bookID)
+}
+
+func (s *requestReservationService) GetAllReservationsByReaderID(ctx context.Context, readerID uuid.UUID) ([]*models.ReservationModel, error) {
+	return s.get(ctx).GetAllReservationsByReaderID(ctx, readerID)
+}
+
+func (s *requestReservationService) GetByID(ctx context.Context, ID uuid.UUID) (*models.ReservationModel, error) {
+	return s.get(ctx).GetByID(ctx, ID)
+}
+
+type requestRatingService struct {
+	requestService[intf.IRatingService]
+}
+
+func NewRatingService(newService NewServiceFunc[intf.IRatingService], logger *logrus.Entry) intf.IRatingService {
+	return &requestRatingService{requestService[intf.IRatingService]{newService: newService, logger: logger}}
+}
+
+func (s *requestRatingService) Create(ctx context.Context, rating *models.RatingModel) error {
+	return s.get(ctx).Create(ctx, rating)
+}
+
+func (s *requestRatingService) GetByBookID(ctx context.Context, bookID uuid.UUID) ([]*models.RatingModel, error) {
+	return s.get(ctx).GetByBookID(ctx, bookID)
+}
+
+func (s *requestRatingService) GetAvgRatingByBookID(ctx context.Context, bookID uuid.UUID) (float32, error) {
+	return s.get(ctx).GetAvgRatingByBookID(ctx, bookID)
+}
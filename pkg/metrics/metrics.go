@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "booksmart"
+
+// IMetrics provides collection of API metrics in Prometheus format.
+type IMetrics interface {
+	ObserveRequest(method, route string, status int, duration time.Duration)
+	IncInFlight()
+	DecInFlight()
+	IncAuthFailure(reason string)
+	IncReservationOutcome(outcome string)
+	IncAuditAppendFailure()
+	Handler() http.Handler
+}
+
+type Metrics struct {
+	registry            *prometheus.Registry
+	requestsTotal       *prometheus.CounterVec
+	requestDuration     *prometheus.HistogramVec
+	requestsInFlight    prometheus.Gauge
+	authFailures        *prometheus.CounterVec
+	reservationOutcomes *prometheus.CounterVec
+	auditAppendFailures prometheus.Counter
+}
+
+// NewMetrics creates metrics in a dedicated registry together with Go runtime and process collectors.
+func NewMetrics() IMetrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of handled HTTP requests by route template and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by route template and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "Number of HTTP requests being handled.",
+		}),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auth_failures_total",
+			Help:      "Number of failed authentications by reason.",
+		}, []string{"reason"}),
+		reservationOutcomes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reservation_outcomes_total",
+			Help:      "Number of book reservation attempts by outcome.",
+		}, []string{"outcome"}),
+		auditAppendFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "audit_append_failures_total",
+			Help:      "Number of audit entries which could not be written.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requestsTotal,
+		m.requestDuration,
+		m.requestsInFlight,
+		m.authFailures,
+		m.reservationOutcomes,
+		m.auditAppendFailures,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	statusStr := strconv.Itoa(status)
+
+	m.requestsTotal.WithLabelValues(method, route, statusStr).Inc()
+	m.requestDuration.WithLabelValues(method, route, statusStr).Observe(duration.Seconds())
+}
+
+func (m *Metrics) IncInFlight() {
+	m.requestsInFlight.Inc()
+}
+
+func (m *Metrics) DecInFlight() {
+	m.requestsInFlight.Dec()
+}
+
+func (m *Metrics) IncAuthFailure(reason string) {
+	m.authFailures.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) IncReservationOutcome(outcome string) {
+	m.reservationOutcomes.WithLabelValues(outcome).Inc()
+}
+
+func (m *Metrics) IncAuditAppendFailure() {
+	m.auditAppendFailures.Inc()
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
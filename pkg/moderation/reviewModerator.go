@@ -0,0 +1,265 @@
+package moderation
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/filestore"
+	"sort"
+	"time"
+)
+
+type ReviewState string
+
+const (
+	ReviewStateVisible ReviewState = "visible"
+	ReviewStatePending ReviewState = "pending"
+	ReviewStateHidden  ReviewState = "hidden"
+	ReviewStateDeleted ReviewState = "deleted"
+)
+
+type Action string
+
+const (
+	ActionApprove Action = "approve"
+	ActionHide    Action = "hide"
+	ActionDelete  Action = "delete"
+)
+
+type Report struct {
+	ReaderID  uuid.UUID
+	Reason    string
+	CreatedAt time.Time
+}
+
+type Decision struct {
+	ModeratorID     uuid.UUID
+	Action          Action
+	Reason          string
+	ResolvedReports int
+	CreatedAt       time.Time
+}
+
+type ReviewStatus struct {
+	Rating       models.RatingModel
+	State        ReviewState
+	FlaggedWords []string
+	Reports      []*Report
+	Decisions    []*Decision
+	QueuedAt     time.Time
+}
+
+// IReviewModerator provides storage of review visibility states, reports and moderation decisions.
+// Reviews unknown to the moderator are considered visible.
+type IReviewModerator interface {
+	// Hold puts a review into the moderation queue before it is created, so that it is never visible.
+	Hold(ctx context.Context, rating *models.RatingModel, flaggedWords []string) error
+	// Release forgets a held review which could not be created.
+	Release(ctx context.Context, ratingID uuid.UUID) error
+	Report(ctx context.Context, rating *models.RatingModel, readerID uuid.UUID, reason string) error
+	Moderate(ctx context.Context, ratingID, moderatorID uuid.UUID, action Action, reason string) (*ReviewStatus, error)
+	GetQueue(ctx context.Context) ([]*ReviewStatus, error)
+	GetState(ctx context.Context, ratingID uuid.UUID) (ReviewState, error)
+	GetStatus(ctx context.Context, ratingID uuid.UUID) (*ReviewStatus, error)
+}
+
+type reviewStatuses map[uuid.UUID]*ReviewStatus
+
+type ReviewModerator struct {
+	statuses filestore.IDocument[reviewStatuses]
+}
+
+// NewReviewModerator keeps the moderation state in the file, an empty path keeps it in memory only.
+func NewReviewModerator(path string) IReviewModerator {
+	return &ReviewModerator{
+		statuses: filestore.NewDocument(path, func() *reviewStatuses {
+			statuses := make(reviewStatuses)
+			return &statuses
+		}),
+	}
+}
+
+func (rm *ReviewModerator) Hold(_ context.Context, rating *models.RatingModel, flaggedWords []string) error {
+	return rm.statuses.Update(func(statuses *reviewStatuses) error {
+		status := rm.getOrCreateStatus(*statuses, rating)
+		status.State = ReviewStatePending
+		status.FlaggedWords = flaggedWords
+		status.QueuedAt = time.Now()
+
+		return nil
+	})
+}
+
+func (rm *ReviewModerator) Release(_ context.Context, ratingID uuid.UUID) error {
+	return rm.statuses.Update(func(statuses *reviewStatuses) error {
+		delete(*statuses, ratingID)
+		return nil
+	})
+}
+
+func (rm *ReviewModerator) Report(_ context.Context, rating *models.RatingModel, readerID uuid.UUID, reason string) error {
+	if reason == "" {
+		return errs.ErrEmptyReportReason
+	}
+
+	return rm.statuses.Update(func(statuses *reviewStatuses) error {
+		status := rm.getOrCreateStatus(*statuses, rating)
+		if status.State == ReviewStateDeleted || status.State == ReviewStateHidden {
+			return errs.ErrReviewDoesNotExists
+		}
+
+		for _, report := range status.Reports {
+			if report.ReaderID == readerID {
+				return errs.ErrReviewAlreadyReported
+			}
+		}
+
+		if !rm.isQueued(status) {
+			status.QueuedAt = time.Now()
+		}
+
+		status.Reports = append(status.Reports, &Report{
+			ReaderID:  readerID,
+			Reason:    reason,
+			CreatedAt: time.Now(),
+		})
+
+		return nil
+	})
+}
+
+// Moderate applies the decision. Deleted reviews lose their text and are excluded
+// from every listing and statistic for good.
+func (rm *ReviewModerator) Moderate(_ context.Context, ratingID, moderatorID uuid.UUID, action Action, reason string) (*ReviewStatus, error) {
+	var newState ReviewState
+	switch action {
+	case ActionApprove:
+		newState = ReviewStateVisible
+	case ActionHide:
+		newState = ReviewStateHidden
+	case ActionDelete:
+		newState = ReviewStateDeleted
+	default:
+		return nil, errs.ErrInvalidModerationAction
+	}
+
+	if action != ActionApprove && reason == "" {
+		return nil, errs.ErrEmptyModerationReason
+	}
+
+	var moderated *ReviewStatus
+	err := rm.statuses.Update(func(statuses *reviewStatuses) error {
+		status, ok := (*statuses)[ratingID]
+		if !ok {
+			return errs.ErrReviewDoesNotExists
+		}
+		if status.State == ReviewStateDeleted {
+			return errs.ErrReviewIsDeleted
+		}
+
+		status.Decisions = append(status.Decisions, &Decision{
+			ModeratorID:     moderatorID,
+			Action:          action,
+			Reason:          reason,
+			ResolvedReports: len(status.Reports),
+			CreatedAt:       time.Now(),
+		})
+		status.State = newState
+		status.Reports = nil
+		status.FlaggedWords = nil
+		if newState == ReviewStateDeleted {
+			status.Rating.Review = ""
+		}
+
+		moderated = rm.copyStatus(status)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return moderated, nil
+}
+
+// GetQueue returns reviews awaiting moderation (pending or reported), oldest first.
+func (rm *ReviewModerator) GetQueue(_ context.Context) ([]*ReviewStatus, error) {
+	queue := make([]*ReviewStatus, 0)
+	err := rm.statuses.Read(func(statuses *reviewStatuses) error {
+		for _, status := range *statuses {
+			if rm.isQueued(status) {
+				queue = append(queue, rm.copyStatus(status))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(queue, func(i, j int) bool {
+		return queue[i].QueuedAt.Before(queue[j].QueuedAt)
+	})
+
+	return queue, nil
+}
+
+func (rm *ReviewModerator) GetState(_ context.Context, ratingID uuid.UUID) (ReviewState, error) {
+	state := ReviewStateVisible
+	err := rm.statuses.Read(func(statuses *reviewStatuses) error {
+		if status, ok := (*statuses)[ratingID]; ok {
+			state = status.State
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return state, nil
+}
+
+// GetStatus returns the moderation history of a review which was held or reported.
+func (rm *ReviewModerator) GetStatus(_ context.Context, ratingID uuid.UUID) (*ReviewStatus, error) {
+	var statusCopy *ReviewStatus
+	err := rm.statuses.Read(func(statuses *reviewStatuses) error {
+		status, ok := (*statuses)[ratingID]
+		if !ok {
+			return errs.ErrReviewDoesNotExists
+		}
+		statusCopy = rm.copyStatus(status)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return statusCopy, nil
+}
+
+func (rm *ReviewModerator) getOrCreateStatus(statuses reviewStatuses, rating *models.RatingModel) *ReviewStatus {
+	status, ok := statuses[rating.ID]
+	if !ok {
+		status = &ReviewStatus{Rating: *rating, State: ReviewStateVisible}
+		statuses[rating.ID] = status
+	}
+
+	return status
+}
+
+func (rm *ReviewModerator) isQueued(status *ReviewStatus) bool {
+	return status.State == ReviewStatePending || (status.State == ReviewStateVisible && len(status.Reports) > 0)
+}
+
+func (rm *ReviewModerator) copyStatus(status *ReviewStatus) *ReviewStatus {
+	statusCopy := *status
+	statusCopy.FlaggedWords = append([]string(nil), status.FlaggedWords...)
+	statusCopy.Reports = append([]*Report(nil), status.Reports...)
+	statusCopy.Decisions = append([]*Decision(nil), status.Decisions...)
+
+	return &statusCopy
+}
@@ -0,0 +1,79 @@
+package moderation
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// IWordFilter provides checking of review text against a list of blocked words.
+type IWordFilter interface {
+	FindBlockedWords(text string) []string
+}
+
+type WordFilter struct {
+	blockedWords map[string]struct{}
+}
+
+func NewWordFilter(blockedWords []string) IWordFilter {
+	filter := &WordFilter{blockedWords: make(map[string]struct{}, len(blockedWords))}
+	for _, word := range blockedWords {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			filter.blockedWords[word] = struct{}{}
+		}
+	}
+
+	return filter
+}
+
+// LoadBlockedWords reads blocked words from file, one word per line.
+// Empty lines and lines starting with '#' are skipped.
+func LoadBlockedWords(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		words = append(words, line)
+	}
+
+	return words, scanner.Err()
+}
+
+// FindBlockedWords returns blocked words found in the text (case-insensitive, whole words only).
+func (wf *WordFilter) FindBlockedWords(text string) []string {
+	if len(wf.blockedWords) == 0 {
+		return nil
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var (
+		found []string
+		seen  = make(map[string]struct{})
+	)
+	for _, word := range words {
+		if _, ok := wf.blockedWords[word]; !ok {
+			continue
+		}
+		if _, ok := seen[word]; ok {
+			continue
+		}
+		seen[word] = struct{}{}
+		found = append(found, word)
+	}
+
+	return found
+}
@@ -0,0 +1,70 @@
+package oidc
+
+import (
+	"context"
+	"github.com/nikitalystsev/BookSmart-web-api/errs"
+	"sync"
+	"time"
+)
+
+// DefaultLoginTTL limits the time the user spends at the provider.
+const DefaultLoginTTL = 10 * time.Minute
+
+// Login is a login started by redirecting the user to the provider.
+type Login struct {
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// ILoginStore provides storage of started logins by their state.
+type ILoginStore interface {
+	Save(ctx context.Context, state string, login *Login) error
+	// Take returns the login and deletes it, so that a redirect can not be replayed.
+	Take(ctx context.Context, state string) (*Login, error)
+}
+
+type MemoryLoginStore struct {
+	mu     sync.Mutex
+	logins map[string]*Login
+	now    func() time.Time
+}
+
+func NewMemoryLoginStore() ILoginStore {
+	return &MemoryLoginStore{logins: make(map[string]*Login), now: time.Now}
+}
+
+func (ms *MemoryLoginStore) Save(_ context.Context, state string, login *Login) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	// logins abandoned at the provider are never taken
+	now := ms.now()
+	for s, l := range ms.logins {
+		if !now.Before(l.ExpiresAt) {
+			delete(ms.logins, s)
+		}
+	}
+
+	loginCopy := *login
+	ms.logins[state] = &loginCopy
+
+	return nil
+}
+
+func (ms *MemoryLoginStore) Take(_ context.Context, state string) (*Login, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	login, ok := ms.logins[state]
+	if !ok {
+		return nil, errs.ErrInvalidOIDCState
+	}
+	delete(ms.logins, state)
+
+	if !ms.now().Before(login.ExpiresAt) {
+		return nil, errs.ErrInvalidOIDCState
+	}
+
+	return login, nil
+}
@@ -0,0 +1,39 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// CodeChallengeMethod is the only PKCE method sent, plain challenges
+// would expose the verifier in the redirect URL.
+const CodeChallengeMethod = "S256"
+
+const randomLen = 32
+
+// NewCodeVerifier returns a PKCE code verifier of 43 characters.
+func NewCodeVerifier() (string, error) {
+	return newRandomString()
+}
+
+// NewState returns a random value binding the redirect back from the provider
+// to the login which started it, state and nonce are generated the same way.
+func NewState() (string, error) {
+	return newRandomString()
+}
+
+func NewCodeChallenge(codeVerifier string) string {
+	hash := sha256.Sum256([]byte(codeVerifier))
+
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func newRandomString() (string, error) {
+	b := make([]byte, randomLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,297 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/nikitalystsev/BookSmart-web-api/errs"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	discoveryPath      = "/.well-known/openid-configuration"
+	DefaultGroupsClaim = "groups"
+	defaultHTTPTimeout = 10 * time.Second
+)
+
+var DefaultScopes = []string{"openid", "profile", "email"}
+
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	GroupsClaim  string
+}
+
+// Identity is a user authenticated by the provider.
+type Identity struct {
+	Issuer  string
+	Subject string
+	Email   string
+	Name    string
+	Groups  []string
+}
+
+// IProvider runs the authorization code flow with PKCE against an OpenID Connect provider.
+type IProvider interface {
+	// AuthCodeURL returns the URL of the provider to redirect the user to,
+	// loginHint prefills the account of the user if not empty.
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge, loginHint string) (string, error)
+	// Exchange redeems the code returned to the redirect URL and verifies the ID token.
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error)
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+	Error   string `json:"error"`
+}
+
+// Provider discovers endpoints and keys of the issuer on first use, so that the API
+// starts while the provider is unavailable. Keys are fetched again on an unknown key ID.
+type Provider struct {
+	mu         sync.Mutex
+	cfg        Config
+	httpClient *http.Client
+	discovery  *discovery
+	keys       map[string]*rsa.PublicKey
+}
+
+func NewProvider(cfg Config) IProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = DefaultScopes
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = DefaultGroupsClaim
+	}
+
+	return &Provider{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: defaultHTTPTimeout},
+		keys:       make(map[string]*rsa.PublicKey),
+	}
+}
+
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge, loginHint string) (string, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.cfg.ClientID},
+		"redirect_uri":          {p.cfg.RedirectURL},
+		"scope":                 {strings.Join(p.cfg.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {CodeChallengeMethod},
+	}
+	if loginHint != "" {
+		params.Set("login_hint", loginHint)
+	}
+
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return d.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	d, err := p.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"client_id":     {p.cfg.ClientID},
+		"client_secret": {p.cfg.ClientSecret},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrOIDCProviderFailed, err)
+	}
+	defer res.Body.Close()
+
+	var token tokenResponse
+	if err = json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrOIDCProviderFailed, err)
+	}
+	if res.StatusCode != http.StatusOK || token.IDToken == "" {
+		// the code is invalid, expired or was issued for another verifier
+		return nil, fmt.Errorf("%w: %s", errs.ErrInvalidOIDCCode, token.Error)
+	}
+
+	return p.verifyIDToken(ctx, d, token.IDToken, nonce)
+}
+
+func (p *Provider) verifyIDToken(ctx context.Context, d *discovery, idToken, nonce string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+
+		return p.getKey(ctx, d, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errs.ErrInvalidIDToken, err)
+	}
+
+	if !claims.VerifyIssuer(d.Issuer, true) || !claims.VerifyAudience(p.cfg.ClientID, true) {
+		return nil, fmt.Errorf("%w: issuer or audience mismatch", errs.ErrInvalidIDToken)
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", errs.ErrInvalidIDToken)
+	}
+
+	identity := &Identity{Issuer: d.Issuer}
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: empty subject", errs.ErrInvalidIDToken)
+	}
+
+	groups, _ := claims[p.cfg.GroupsClaim].([]interface{})
+	for _, group := range groups {
+		if groupStr, ok := group.(string); ok {
+			identity.Groups = append(identity.Groups, groupStr)
+		}
+	}
+
+	return identity, nil
+}
+
+func (p *Provider) getDiscovery(ctx context.Context) (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	var d discovery
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.cfg.IssuerURL, "/")+discoveryPath, &d); err != nil {
+		return nil, err
+	}
+	if d.Issuer != p.cfg.IssuerURL {
+		return nil, fmt.Errorf("%w: issuer %q does not match %q", errs.ErrOIDCProviderFailed, d.Issuer, p.cfg.IssuerURL)
+	}
+	p.discovery = &d
+
+	return p.discovery, nil
+}
+
+func (p *Provider) getKey(ctx context.Context, d *discovery, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	// the provider may have rotated its keys
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, d.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		key, err := parseRSAKey(jwk)
+		if err != nil {
+			return nil, err
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrOIDCProviderFailed, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s responded with %d", errs.ErrOIDCProviderFailed, url, res.StatusCode)
+	}
+	if err = json.NewDecoder(res.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", errs.ErrOIDCProviderFailed, err)
+	}
+
+	return nil
+}
+
+func parseRSAKey(jwk jsonWebKey) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	if len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
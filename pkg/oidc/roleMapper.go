@@ -0,0 +1,36 @@
+package oidc
+
+import (
+	"github.com/google/uuid"
+	"slices"
+)
+
+// ReaderRole is never granted by mappings, readers sign in with a password.
+const ReaderRole = "Reader"
+
+// staffNamespace derives stable staff IDs from identities of the provider.
+var staffNamespace = uuid.MustParse("5c1f0b4e-8d3a-4f2e-9b7c-2a6d1e0f3c84")
+
+// GroupRole grants a BookSmart role to the members of a group of the provider.
+type GroupRole struct {
+	Group string `yaml:"group"`
+	Role  string `yaml:"role"`
+}
+
+// MapRole returns the role of the first mapping whose group contains the user,
+// so that mappings are listed from the most privileged role.
+func MapRole(groups []string, groupRoles []GroupRole) (string, bool) {
+	for _, groupRole := range groupRoles {
+		if slices.Contains(groups, groupRole.Group) {
+			return groupRole.Role, true
+		}
+	}
+
+	return "", false
+}
+
+// GetStaffID returns the same ID on every sign-in of the user, subjects are
+// unique only within their issuer.
+func GetStaffID(identity *Identity) uuid.UUID {
+	return uuid.NewSHA1(staffNamespace, []byte(identity.Issuer+"|"+identity.Subject))
+}
@@ -0,0 +1,76 @@
+package oidc
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/errs"
+	"sync"
+	"time"
+)
+
+// Session is a sign-in of a staff member through the provider. Staff members are
+// not stored by the reader service, so their refresh tokens are kept here.
+type Session struct {
+	StaffID   uuid.UUID
+	Role      string
+	Email     string
+	ExpiresAt time.Time
+}
+
+// ISessionStore provides storage of staff sessions by their refresh tokens.
+type ISessionStore interface {
+	Save(ctx context.Context, refreshToken string, session *Session) error
+	// Take returns the session and deletes its refresh token, since refresh tokens are rotated on use.
+	Take(ctx context.Context, refreshToken string) (*Session, error)
+}
+
+// NewRefreshToken returns a refresh token of a staff session. Tokens of the token
+// manager are seeded by the current second, so staff signing in at the same time
+// would share them.
+func NewRefreshToken() (string, error) {
+	return newRandomString()
+}
+
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	now      func() time.Time
+}
+
+func NewMemorySessionStore() ISessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*Session), now: time.Now}
+}
+
+func (ms *MemorySessionStore) Save(_ context.Context, refreshToken string, session *Session) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	for token, s := range ms.sessions {
+		if !now.Before(s.ExpiresAt) {
+			delete(ms.sessions, token)
+		}
+	}
+
+	sessionCopy := *session
+	ms.sessions[refreshToken] = &sessionCopy
+
+	return nil
+}
+
+func (ms *MemorySessionStore) Take(_ context.Context, refreshToken string) (*Session, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	session, ok := ms.sessions[refreshToken]
+	if !ok {
+		return nil, errs.ErrInvalidToken
+	}
+	delete(ms.sessions, refreshToken)
+
+	if !ms.now().Before(session.ExpiresAt) {
+		return nil, errs.ErrInvalidToken
+	}
+
+	return session, nil
+}
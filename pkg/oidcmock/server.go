@@ -0,0 +1,317 @@
+package oidcmock
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v4"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	DiscoveryPath     = "/.well-known/openid-configuration"
+	AuthorizationPath = "/authorize"
+	TokenPath         = "/token"
+	JWKSPath          = "/jwks"
+)
+
+const (
+	keyID         = "oidcmock"
+	rsaKeyBits    = 2048
+	codeTTL       = time.Minute
+	idTokenTTL    = 5 * time.Minute
+	codeLen       = 16
+	challengeS256 = "S256"
+)
+
+// User is an account of the provider. The user signing in is chosen by the login_hint
+// parameter of the authorization request, the only user signs in without it.
+type User struct {
+	Subject string
+	Email   string
+	Name    string
+	Groups  []string
+}
+
+// IServer is a local OpenID Connect provider for testing sign-in of staff
+// without an external provider, e.g. served by httptest.NewServer.
+type IServer interface {
+	http.Handler
+	AddUser(user User)
+}
+
+type grant struct {
+	user          User
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	expiresAt     time.Time
+}
+
+// Server approves every authorization request at once, so that the whole
+// authorization code flow runs without a browser.
+type Server struct {
+	mu           sync.Mutex
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+	users        map[string]User
+	grants       map[string]*grant
+	mux          *http.ServeMux
+	now          func() time.Time
+}
+
+func NewServer(clientID, clientSecret string) (IServer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		key:          key,
+		users:        make(map[string]User),
+		grants:       make(map[string]*grant),
+		mux:          http.NewServeMux(),
+		now:          time.Now,
+	}
+	s.mux.HandleFunc("GET "+DiscoveryPath, s.discovery)
+	s.mux.HandleFunc("GET "+AuthorizationPath, s.authorize)
+	s.mux.HandleFunc("POST "+TokenPath, s.token)
+	s.mux.HandleFunc("GET "+JWKSPath, s.jwks)
+
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// AddUser adds the user or replaces the one with the same subject, e.g. to change its groups.
+func (s *Server) AddUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[user.Subject] = user
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := getIssuer(r)
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + AuthorizationPath,
+		"token_endpoint":                        issuer + TokenPath,
+		"jwks_uri":                              issuer + JWKSPath,
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{challengeS256},
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Get("client_id") != s.clientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	redirectURL, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || !redirectURL.IsAbs() {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+
+	redirectError := func(code string) {
+		params := redirectURL.Query()
+		params.Set("error", code)
+		params.Set("state", query.Get("state"))
+		redirectURL.RawQuery = params.Encode()
+		http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+	}
+	if query.Get("response_type") != "code" {
+		redirectError("unsupported_response_type")
+		return
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge_method") != challengeS256 {
+		redirectError("invalid_request")
+		return
+	}
+
+	user, ok := s.getUser(query.Get("login_hint"))
+	if !ok {
+		redirectError("access_denied")
+		return
+	}
+
+	code, err := newCode()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	s.grants[code] = &grant{
+		user:          user,
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		expiresAt:     s.now().Add(codeTTL),
+	}
+	s.mu.Unlock()
+
+	params := redirectURL.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirectURL.RawQuery = params.Encode()
+
+	http.Redirect(w, r, redirectURL.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != s.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(s.clientSecret)) != 1 {
+		writeTokenError(w, http.StatusUnauthorized, "invalid_client")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type")
+		return
+	}
+
+	// codes are single-use, even if the exchange fails
+	s.mu.Lock()
+	g, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	if !ok || !s.now().Before(g.expiresAt) || g.redirectURI != r.PostForm.Get("redirect_uri") {
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+	challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != g.codeChallenge {
+		writeTokenError(w, http.StatusBadRequest, "invalid_grant")
+		return
+	}
+
+	idToken, err := s.newIDToken(getIssuer(r), g)
+	if err != nil {
+		writeTokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+	accessToken, err := newCode()
+	if err != nil {
+		writeTokenError(w, http.StatusInternalServerError, "server_error")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(idTokenTTL.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kid": keyID,
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) getUser(loginHint string) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if loginHint != "" {
+		user, ok := s.users[loginHint]
+		return user, ok
+	}
+	if len(s.users) != 1 {
+		return User{}, false
+	}
+	for _, user := range s.users {
+		return user, true
+	}
+
+	return User{}, false
+}
+
+func (s *Server) newIDToken(issuer string, g *grant) (string, error) {
+	now := s.now()
+	claims := jwt.MapClaims{
+		"iss":    issuer,
+		"sub":    g.user.Subject,
+		"aud":    s.clientID,
+		"iat":    now.Unix(),
+		"exp":    now.Add(idTokenTTL).Unix(),
+		"email":  g.user.Email,
+		"name":   g.user.Name,
+		"groups": g.user.Groups,
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+
+	return token.SignedString(s.key)
+}
+
+// getIssuer returns the URL the server is reached at, since the port
+// of a test server is known only after it is started.
+func getIssuer(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return scheme + "://" + r.Host
+}
+
+func newCode() (string, error) {
+	b := make([]byte, codeLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func writeTokenError(w http.ResponseWriter, status int, code string) {
+	writeJSON(w, status, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IStore keeps token buckets. Implementations backed by a shared storage
+// let several instances of the API enforce common limits.
+type IStore interface {
+	Take(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	window    time.Duration
+}
+
+// MemoryStore keeps buckets of a single instance. Full buckets are dropped
+// on sweeps, so the memory is proportional to the number of active clients.
+type MemoryStore struct {
+	mu          sync.Mutex
+	buckets     map[string]*bucket
+	sweepPeriod time.Duration
+	sweptAt     time.Time
+	now         func() time.Time
+}
+
+const defaultSweepPeriod = time.Minute
+
+func NewMemoryStore() IStore {
+	return &MemoryStore{
+		buckets:     make(map[string]*bucket),
+		sweepPeriod: defaultSweepPeriod,
+		sweptAt:     time.Now(),
+		now:         time.Now,
+	}
+}
+
+func (ms *MemoryStore) Take(ctx context.Context, key string, policy Policy) (Result, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	ms.sweep(now)
+
+	b, ok := ms.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(policy.Limit), updatedAt: now, window: policy.Window}
+		ms.buckets[key] = b
+	}
+
+	refillInterval := policy.refillInterval()
+	b.tokens = min(float64(policy.Limit), b.tokens+float64(now.Sub(b.updatedAt))/float64(refillInterval))
+	b.updatedAt = now
+
+	result := Result{Allowed: b.tokens >= 1, Limit: policy.Limit}
+	if result.Allowed {
+		b.tokens--
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * float64(refillInterval))
+	}
+
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = time.Duration((float64(policy.Limit) - b.tokens) * float64(refillInterval))
+
+	return result, nil
+}
+
+// sweep drops the buckets which have been refilled completely since their last use.
+func (ms *MemoryStore) sweep(now time.Time) {
+	if now.Sub(ms.sweptAt) < ms.sweepPeriod {
+		return
+	}
+	ms.sweptAt = now
+
+	for key, b := range ms.buckets {
+		if now.Sub(b.updatedAt) >= b.window {
+			delete(ms.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Policy is a token bucket of Limit tokens which is refilled completely during Window.
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// Header formats the policy as a value of the RateLimit-Policy header, e.g. "10;w=60".
+func (p Policy) Header() string {
+	return fmt.Sprintf("%d;w=%d", p.Limit, int(p.Window.Seconds()))
+}
+
+// refillInterval is the time needed to refill a single token.
+func (p Policy) refillInterval() time.Duration {
+	return p.Window / time.Duration(p.Limit)
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next token, zero if allowed
+}
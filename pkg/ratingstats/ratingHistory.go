@@ -0,0 +1,65 @@
+package ratingstats
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/filestore"
+	"time"
+)
+
+// HistoryRecord stores the moment a rating was submitted through the API.
+// Rating models of the services layer carry no timestamps, so trend is built from these records.
+type HistoryRecord struct {
+	RatingID  uuid.UUID
+	BookID    uuid.UUID
+	Rating    int
+	CreatedAt time.Time
+}
+
+// IRatingHistory provides storage of rating submission times.
+type IRatingHistory interface {
+	Add(ctx context.Context, record *HistoryRecord) error
+	GetByBookID(ctx context.Context, bookID uuid.UUID) ([]*HistoryRecord, error)
+}
+
+type historyRecords map[uuid.UUID][]*HistoryRecord
+
+type RatingHistory struct {
+	records filestore.IDocument[historyRecords]
+}
+
+// NewRatingHistory keeps the records in the file, an empty path keeps them in memory only.
+func NewRatingHistory(path string) IRatingHistory {
+	return &RatingHistory{
+		records: filestore.NewDocument(path, func() *historyRecords {
+			records := make(historyRecords)
+			return &records
+		}),
+	}
+}
+
+func (rh *RatingHistory) Add(_ context.Context, record *HistoryRecord) error {
+	recordCopy := *record
+
+	return rh.records.Update(func(records *historyRecords) error {
+		(*records)[record.BookID] = append((*records)[record.BookID], &recordCopy)
+		return nil
+	})
+}
+
+func (rh *RatingHistory) GetByBookID(_ context.Context, bookID uuid.UUID) ([]*HistoryRecord, error) {
+	var bookRecords []*HistoryRecord
+	err := rh.records.Read(func(records *historyRecords) error {
+		for _, record := range (*records)[bookID] {
+			recordCopy := *record
+			bookRecords = append(bookRecords, &recordCopy)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bookRecords, nil
+}
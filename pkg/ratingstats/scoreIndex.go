@@ -0,0 +1,132 @@
+package ratingstats
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/filestore"
+	"sort"
+)
+
+// ScoreEntry aggregates the visible ratings of a book.
+type ScoreEntry struct {
+	Count int
+	Total int
+}
+
+func NewScoreEntry(ratings []*models.RatingModel) ScoreEntry {
+	entry := ScoreEntry{Count: len(ratings)}
+	for _, rating := range ratings {
+		entry.Total += rating.Rating
+	}
+
+	return entry
+}
+
+// IScoreIndex keeps the rating aggregates of books, so that the catalog is ranked
+// without reading the ratings of every book.
+type IScoreIndex interface {
+	// Set replaces the aggregate of a book after its visible ratings changed.
+	Set(ctx context.Context, bookID uuid.UUID, entry ScoreEntry) error
+	// Backfill indexes books for the first time, keeping aggregates set concurrently,
+	// and marks the index complete, so that the catalog is not listed again.
+	Backfill(ctx context.Context, entries map[uuid.UUID]ScoreEntry) error
+	// IsComplete reports whether the books rated before the index existed are indexed.
+	IsComplete(ctx context.Context) (bool, error)
+	// GetRanked returns the books with ratings by descending score.
+	GetRanked(ctx context.Context, score func(entry ScoreEntry) float32) ([]uuid.UUID, error)
+}
+
+type scoreIndexData struct {
+	Entries    map[uuid.UUID]ScoreEntry
+	IsComplete bool
+}
+
+type ScoreIndex struct {
+	data filestore.IDocument[scoreIndexData]
+}
+
+// NewScoreIndex keeps the index in the file, an empty path keeps it in memory only.
+func NewScoreIndex(path string) IScoreIndex {
+	return &ScoreIndex{
+		data: filestore.NewDocument(path, func() *scoreIndexData {
+			return &scoreIndexData{Entries: make(map[uuid.UUID]ScoreEntry)}
+		}),
+	}
+}
+
+func (si *ScoreIndex) Set(_ context.Context, bookID uuid.UUID, entry ScoreEntry) error {
+	return si.data.Update(func(data *scoreIndexData) error {
+		if entry.Count == 0 {
+			delete(data.Entries, bookID)
+		} else {
+			data.Entries[bookID] = entry
+		}
+
+		return nil
+	})
+}
+
+func (si *ScoreIndex) Backfill(_ context.Context, newEntries map[uuid.UUID]ScoreEntry) error {
+	return si.data.Update(func(data *scoreIndexData) error {
+		for bookID, entry := range newEntries {
+			if _, ok := data.Entries[bookID]; !ok && entry.Count != 0 {
+				data.Entries[bookID] = entry
+			}
+		}
+		data.IsComplete = true
+
+		return nil
+	})
+}
+
+func (si *ScoreIndex) IsComplete(_ context.Context) (bool, error) {
+	var isComplete bool
+	err := si.data.Read(func(data *scoreIndexData) error {
+		isComplete = data.IsComplete
+		return nil
+	})
+
+	return isComplete, err
+}
+
+// GetRanked breaks ties of scores by the number of ratings and then by ID,
+// so that pages of the ranking do not overlap.
+func (si *ScoreIndex) GetRanked(_ context.Context, score func(entry ScoreEntry) float32) ([]uuid.UUID, error) {
+	type rankedBook struct {
+		id    uuid.UUID
+		score float32
+		count int
+	}
+
+	var books []rankedBook
+	err := si.data.Read(func(data *scoreIndexData) error {
+		books = make([]rankedBook, 0, len(data.Entries))
+		for bookID, entry := range data.Entries {
+			books = append(books, rankedBook{id: bookID, score: score(entry), count: entry.Count})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(books, func(i, j int) bool {
+		if books[i].score != books[j].score {
+			return books[i].score > books[j].score
+		}
+		if books[i].count != books[j].count {
+			return books[i].count > books[j].count
+		}
+
+		return books[i].id.String() < books[j].id.String()
+	})
+
+	bookIDs := make([]uuid.UUID, len(books))
+	for i, book := range books {
+		bookIDs[i] = book.id
+	}
+
+	return bookIDs, nil
+}
@@ -0,0 +1,125 @@
+package ratingstats
+
+import (
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"sort"
+)
+
+const (
+	MinRating = 1
+	MaxRating = 5
+
+	DefaultPriorMean   = 3.0
+	DefaultPriorWeight = 10.0
+
+	trendPeriodLayout = "2006-01"
+)
+
+type TrendPoint struct {
+	Period    string
+	Count     int
+	AvgRating float32
+}
+
+type Statistics struct {
+	// Histogram counts ratings by stars, ratings without stars are counted only in TotalCount.
+	Histogram     [MaxRating - MinRating + 1]int
+	TotalCount    int
+	AvgRating     float32
+	BayesianScore float32
+	Trend         []*TrendPoint
+	// UntrackedCount is the number of ratings submitted before their times were recorded,
+	// they are missing from Trend.
+	UntrackedCount int
+}
+
+// IStatisticsCalculator provides calculation of rating statistics and the ranking score of a book.
+type IStatisticsCalculator interface {
+	Calculate(ratings []*models.RatingModel, history []*HistoryRecord) *Statistics
+	Score(entry ScoreEntry) float32
+}
+
+// StatisticsCalculator ranks books by the Bayesian average:
+// score = (priorWeight * priorMean + sum(ratings)) / (priorWeight + len(ratings)),
+// so that books with few ratings are pulled towards the prior mean.
+type StatisticsCalculator struct {
+	priorMean   float64
+	priorWeight float64
+}
+
+func NewStatisticsCalculator(priorMean, priorWeight float64) IStatisticsCalculator {
+	return &StatisticsCalculator{priorMean: priorMean, priorWeight: priorWeight}
+}
+
+func (sc *StatisticsCalculator) Calculate(ratings []*models.RatingModel, history []*HistoryRecord) *Statistics {
+	stats := &Statistics{TotalCount: len(ratings)}
+
+	var total int
+	for _, rating := range ratings {
+		if rating.Rating >= MinRating && rating.Rating <= MaxRating {
+			stats.Histogram[rating.Rating-MinRating]++
+		}
+		total += rating.Rating
+	}
+
+	if len(ratings) > 0 {
+		stats.AvgRating = float32(total) / float32(len(ratings))
+	}
+	stats.BayesianScore = sc.Score(NewScoreEntry(ratings))
+	stats.Trend = sc.calculateTrend(ratings, history)
+	stats.UntrackedCount = len(ratings)
+	for _, point := range stats.Trend {
+		stats.UntrackedCount -= point.Count
+	}
+
+	return stats
+}
+
+func (sc *StatisticsCalculator) Score(entry ScoreEntry) float32 {
+	weight := sc.priorWeight + float64(entry.Count)
+	if weight == 0 {
+		return 0
+	}
+
+	return float32((sc.priorWeight*sc.priorMean + float64(entry.Total)) / weight)
+}
+
+// calculateTrend groups ratings by month of submission. Ratings without a history record are skipped.
+func (sc *StatisticsCalculator) calculateTrend(ratings []*models.RatingModel, history []*HistoryRecord) []*TrendPoint {
+	ratingIDs := make(map[uuid.UUID]struct{}, len(ratings))
+	for _, rating := range ratings {
+		ratingIDs[rating.ID] = struct{}{}
+	}
+
+	var (
+		points = make(map[string]*TrendPoint)
+		totals = make(map[string]int)
+	)
+	for _, record := range history {
+		if _, ok := ratingIDs[record.RatingID]; !ok {
+			continue
+		}
+
+		period := record.CreatedAt.Format(trendPeriodLayout)
+		point, ok := points[period]
+		if !ok {
+			point = &TrendPoint{Period: period}
+			points[period] = point
+		}
+		point.Count++
+		totals[period] += record.Rating
+	}
+
+	trend := make([]*TrendPoint, 0, len(points))
+	for period, point := range points {
+		point.AvgRating = float32(totals[period]) / float32(point.Count)
+		trend = append(trend, point)
+	}
+
+	sort.Slice(trend, func(i, j int) bool {
+		return trend[i].Period < trend[j].Period
+	})
+
+	return trend
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/certreload"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/config"
+	"github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+type IServer interface {
+	// Run serves requests until ctx is done, then stops accepting connections and
+	// waits for in-flight requests to complete during the shutdown timeout.
+	Run(ctx context.Context) error
+	// RunUntilSignal runs the server until SIGINT or SIGTERM is received.
+	RunUntilSignal() error
+	// RegisterOnShutdown adds a function called when the shutdown starts,
+	// e.g. to fail readiness checks while requests are drained.
+	RegisterOnShutdown(f func())
+}
+
+type Server struct {
+	httpServer    *http.Server
+	metricsServer *http.Server // nil serves no separate metrics port
+	cfg           *config.Config
+	logger        *logrus.Entry
+	onShutdown    []func()
+	mu            sync.Mutex
+}
+
+type ServerOption func(s *Server)
+
+// WithMetricsHandler serves the handler, e.g. handlers.Handler.MetricsHandler, over plain
+// HTTP on config.MetricsConfig.Addr. The option does nothing if the address is not set.
+func WithMetricsHandler(handler http.Handler) ServerOption {
+	return func(s *Server) {
+		if !s.cfg.Metrics.IsSeparate() {
+			return
+		}
+
+		s.metricsServer = s.newHTTPServer(s.cfg.Metrics.Addr, handler)
+	}
+}
+
+func NewServer(cfg *config.Config, handler http.Handler, logger *logrus.Entry, opts ...ServerOption) IServer {
+	s := &Server{
+		cfg:    cfg,
+		logger: logger,
+	}
+	s.httpServer = s.newHTTPServer(cfg.Server.Addr, handler)
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *Server) newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: s.cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       s.cfg.Server.ReadTimeout,
+		WriteTimeout:      s.cfg.Server.WriteTimeout,
+		IdleTimeout:       s.cfg.Server.IdleTimeout,
+	}
+}
+
+func (s *Server) Run(ctx context.Context) error {
+	if s.cfg.TLS.IsEnabled() {
+		tlsConfig, err := s.getTLSConfig()
+		if err != nil {
+			return err
+		}
+		s.httpServer.TLSConfig = tlsConfig
+	}
+
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+
+	var metricsListener net.Listener
+	if s.metricsServer != nil {
+		if metricsListener, err = net.Listen("tcp", s.metricsServer.Addr); err != nil {
+			_ = listener.Close()
+			return err
+		}
+	}
+
+	serveErr := make(chan error, 2)
+	go func() {
+		s.logger.WithFields(logrus.Fields{"addr": listener.Addr().String(), "tls": s.cfg.TLS.IsEnabled()}).Info("server started")
+		serveErr <- s.serve(listener)
+	}()
+	if metricsListener != nil {
+		go func() {
+			s.logger.WithField("addr", metricsListener.Addr().String()).Info("metrics server started")
+			serveErr <- ignoreServerClosed(s.metricsServer.Serve(metricsListener))
+		}()
+	}
+
+	select {
+	case err = <-serveErr:
+		// neither server keeps running without the other one
+		s.close()
+		return err
+	case <-ctx.Done():
+	}
+
+	return s.shutdown()
+}
+
+func (s *Server) RunUntilSignal() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return s.Run(ctx)
+}
+
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onShutdown = append(s.onShutdown, f)
+}
+
+func (s *Server) serve(listener net.Listener) error {
+	var err error
+	if s.cfg.TLS.IsEnabled() {
+		err = s.httpServer.ServeTLS(listener, "", "")
+	} else {
+		err = s.httpServer.Serve(listener)
+	}
+
+	return ignoreServerClosed(err)
+}
+
+func ignoreServerClosed(err error) error {
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// getTLSConfig serves HTTP/2 with a certificate reloaded on renewal. Client certificates
+// are verified if given, the admin routes reject requests without them.
+func (s *Server) getTLSConfig() (*tls.Config, error) {
+	reloader, err := certreload.NewCertificateReloader(s.cfg.TLS.CertFile, s.cfg.TLS.KeyFile, s.cfg.TLS.ReloadPeriod, s.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+	if !s.cfg.TLS.IsAdminClientCertRequired() {
+		return tlsConfig, nil
+	}
+
+	caCerts, err := os.ReadFile(s.cfg.TLS.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caCerts) {
+		return nil, fmt.Errorf("no certificates in client CA file %s", s.cfg.TLS.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+
+	return tlsConfig, nil
+}
+
+func (s *Server) shutdown() error {
+	s.logger.WithField("timeout", s.cfg.Server.ShutdownTimeout.String()).Info("server is shutting down, draining in-flight requests")
+
+	s.mu.Lock()
+	for _, f := range s.onShutdown {
+		f()
+	}
+	s.mu.Unlock()
+
+	ctx := context.Background()
+	if s.cfg.Server.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.Server.ShutdownTimeout)
+		defer cancel()
+	}
+
+	// metrics are scraped until the requests are drained
+	if err := s.httpServer.Shutdown(ctx); err != nil {
+		s.logger.WithError(err).Warn("server shutdown timed out, closing remaining connections")
+		if s.metricsServer != nil {
+			_ = s.metricsServer.Close()
+		}
+		return s.httpServer.Close()
+	}
+	if s.metricsServer != nil {
+		if err := s.metricsServer.Shutdown(ctx); err != nil {
+			s.logger.WithError(err).Warn("metrics server shutdown timed out, closing remaining connections")
+			return s.metricsServer.Close()
+		}
+	}
+
+	s.logger.Info("server stopped")
+
+	return nil
+}
+
+func (s *Server) close() {
+	_ = s.httpServer.Close()
+	if s.metricsServer != nil {
+		_ = s.metricsServer.Close()
+	}
+}
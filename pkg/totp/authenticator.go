@@ -0,0 +1,270 @@
+package totp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/errs"
+	"github.com/nikitalystsev/BookSmart-web-api/pkg/filestore"
+	"strings"
+	"time"
+)
+
+const (
+	RecoveryCodesNumber = 10
+	recoveryCodeLen     = 5 // in bytes, formatted as xxxxx-xxxxx
+)
+
+// Enrollment is the secret of a user to be added to an authenticator app.
+type Enrollment struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+type Status struct {
+	IsEnabled           bool
+	IsEnrollmentPending bool
+	RecoveryCodesLeft   int
+	EnabledAt           time.Time
+}
+
+// IAuthenticator provides two-factor authentication of users by TOTP codes.
+// Methods accepting a code accept a recovery code as well, which is then used up.
+type IAuthenticator interface {
+	// Enroll starts enrolment of the user, replacing the secret of a pending one.
+	Enroll(ctx context.Context, userID uuid.UUID, issuer, accountName string) (*Enrollment, error)
+	// Confirm enables two-factor authentication once the user enters a code
+	// of the app, returning recovery codes which are shown only once.
+	Confirm(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	Verify(ctx context.Context, userID uuid.UUID, code string) error
+	Disable(ctx context.Context, userID uuid.UUID, code string) error
+	// RegenerateRecoveryCodes replaces the recovery codes, the previous ones stop working.
+	RegenerateRecoveryCodes(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+	GetStatus(ctx context.Context, userID uuid.UUID) (*Status, error)
+}
+
+type factor struct {
+	Secret             string
+	IsEnabled          bool
+	EnabledAt          time.Time
+	LastStep           int64
+	RecoveryCodeHashes []string
+}
+
+type userFactors map[uuid.UUID]*factor
+
+// Authenticator saves every change of a factor, including the last used step and
+// recovery codes, so that a code can not be used again after a restart.
+type Authenticator struct {
+	factors filestore.IDocument[userFactors]
+	now     func() time.Time
+}
+
+// NewAuthenticator keeps the factors in the file, an empty path keeps them in memory only.
+func NewAuthenticator(path string) IAuthenticator {
+	return &Authenticator{
+		factors: filestore.NewDocument(path, func() *userFactors {
+			factors := make(userFactors)
+			return &factors
+		}),
+		now: time.Now,
+	}
+}
+
+func (a *Authenticator) Enroll(_ context.Context, userID uuid.UUID, issuer, accountName string) (*Enrollment, error) {
+	secret, err := NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	err = a.factors.Update(func(factors *userFactors) error {
+		if f, ok := (*factors)[userID]; ok && f.IsEnabled {
+			return errs.ErrTwoFactorIsAlreadyEnabled
+		}
+		(*factors)[userID] = &factor{Secret: secret}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Enrollment{Secret: secret, ProvisioningURI: GetProvisioningURI(issuer, accountName, secret)}, nil
+}
+
+func (a *Authenticator) Confirm(_ context.Context, userID uuid.UUID, code string) ([]string, error) {
+	var recoveryCodes []string
+	err := a.factors.Update(func(factors *userFactors) error {
+		f, ok := (*factors)[userID]
+		if !ok {
+			return errs.ErrTwoFactorEnrollmentNotStarted
+		}
+		if f.IsEnabled {
+			return errs.ErrTwoFactorIsAlreadyEnabled
+		}
+
+		// recovery codes do not exist yet, only the app proves the secret was added
+		step, ok := validate(f.Secret, normalizeCode(code), a.now(), f.LastStep)
+		if !ok {
+			return errs.ErrInvalidTwoFactorCode
+		}
+
+		var recoveryCodeHashes []string
+		var err error
+		recoveryCodes, recoveryCodeHashes, err = newRecoveryCodes()
+		if err != nil {
+			return err
+		}
+
+		f.IsEnabled = true
+		f.EnabledAt = a.now()
+		f.LastStep = step
+		f.RecoveryCodeHashes = recoveryCodeHashes
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+func (a *Authenticator) Verify(_ context.Context, userID uuid.UUID, code string) error {
+	return a.factors.Update(func(factors *userFactors) error {
+		f, err := getEnabledFactor(factors, userID)
+		if err != nil {
+			return err
+		}
+
+		return a.verify(f, code)
+	})
+}
+
+func (a *Authenticator) Disable(_ context.Context, userID uuid.UUID, code string) error {
+	return a.factors.Update(func(factors *userFactors) error {
+		f, err := getEnabledFactor(factors, userID)
+		if err != nil {
+			return err
+		}
+		if err = a.verify(f, code); err != nil {
+			return err
+		}
+
+		delete(*factors, userID)
+
+		return nil
+	})
+}
+
+func (a *Authenticator) RegenerateRecoveryCodes(_ context.Context, userID uuid.UUID, code string) ([]string, error) {
+	var recoveryCodes []string
+	err := a.factors.Update(func(factors *userFactors) error {
+		f, err := getEnabledFactor(factors, userID)
+		if err != nil {
+			return err
+		}
+		if err = a.verify(f, code); err != nil {
+			return err
+		}
+
+		var recoveryCodeHashes []string
+		recoveryCodes, recoveryCodeHashes, err = newRecoveryCodes()
+		if err != nil {
+			return err
+		}
+		f.RecoveryCodeHashes = recoveryCodeHashes
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return recoveryCodes, nil
+}
+
+func (a *Authenticator) GetStatus(_ context.Context, userID uuid.UUID) (*Status, error) {
+	status := &Status{}
+	err := a.factors.Read(func(factors *userFactors) error {
+		f, ok := (*factors)[userID]
+		if !ok {
+			return nil
+		}
+
+		status = &Status{
+			IsEnabled:           f.IsEnabled,
+			IsEnrollmentPending: !f.IsEnabled,
+			RecoveryCodesLeft:   len(f.RecoveryCodeHashes),
+			EnabledAt:           f.EnabledAt,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+func getEnabledFactor(factors *userFactors, userID uuid.UUID) (*factor, error) {
+	f, ok := (*factors)[userID]
+	if !ok || !f.IsEnabled {
+		return nil, errs.ErrTwoFactorIsNotEnabled
+	}
+
+	return f, nil
+}
+
+func (a *Authenticator) verify(f *factor, code string) error {
+	code = normalizeCode(code)
+
+	if step, ok := validate(f.Secret, code, a.now(), f.LastStep); ok {
+		f.LastStep = step
+		return nil
+	}
+
+	codeHash := hashRecoveryCode(code)
+	for i, recoveryCodeHash := range f.RecoveryCodeHashes {
+		if subtle.ConstantTimeCompare([]byte(recoveryCodeHash), []byte(codeHash)) == 1 {
+			f.RecoveryCodeHashes = append(f.RecoveryCodeHashes[:i], f.RecoveryCodeHashes[i+1:]...)
+			return nil
+		}
+	}
+
+	return errs.ErrInvalidTwoFactorCode
+}
+
+// newRecoveryCodes returns the codes to show and their hashes to keep.
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RecoveryCodesNumber)
+	hashes := make([]string, 0, RecoveryCodesNumber)
+	for range RecoveryCodesNumber {
+		b := make([]byte, recoveryCodeLen)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+
+		codes = append(codes, code[:recoveryCodeLen]+"-"+code[recoveryCodeLen:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	hash := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// normalizeCode accepts codes typed with spaces or in upper case and recovery
+// codes without the hyphen.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+}
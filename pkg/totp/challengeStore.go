@@ -0,0 +1,89 @@
+package totp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/errs"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultChallengeTTL = 5 * time.Minute
+	// MaxChallengeAttempts limits guessing of codes, the password has to be entered again then.
+	MaxChallengeAttempts = 5
+	challengeTokenLen    = 32
+)
+
+// Challenge is a sign-in waiting for the second factor. The tokens issued on
+// the first step are kept here until the code is verified.
+type Challenge struct {
+	UserID       uuid.UUID
+	Role         string
+	AccountName  string
+	RefreshToken string
+	Attempts     int
+	ExpiresAt    time.Time
+}
+
+// IChallengeStore provides storage of challenges by their tokens.
+type IChallengeStore interface {
+	Save(ctx context.Context, challengeToken string, challenge *Challenge) error
+	// Take returns the challenge and deletes it, so that it is used by one verification at a time.
+	Take(ctx context.Context, challengeToken string) (*Challenge, error)
+}
+
+func NewChallengeToken() (string, error) {
+	b := make([]byte, challengeTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type MemoryChallengeStore struct {
+	mu         sync.Mutex
+	challenges map[string]*Challenge
+	now        func() time.Time
+}
+
+func NewMemoryChallengeStore() IChallengeStore {
+	return &MemoryChallengeStore{challenges: make(map[string]*Challenge), now: time.Now}
+}
+
+func (ms *MemoryChallengeStore) Save(_ context.Context, challengeToken string, challenge *Challenge) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.now()
+	for token, c := range ms.challenges {
+		if !now.Before(c.ExpiresAt) {
+			delete(ms.challenges, token)
+		}
+	}
+
+	challengeCopy := *challenge
+	ms.challenges[challengeToken] = &challengeCopy
+
+	return nil
+}
+
+func (ms *MemoryChallengeStore) Take(_ context.Context, challengeToken string) (*Challenge, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	challenge, ok := ms.challenges[challengeToken]
+	if !ok {
+		return nil, errs.ErrInvalidChallengeToken
+	}
+	delete(ms.challenges, challengeToken)
+
+	if !ms.now().Before(challenge.ExpiresAt) {
+		return nil, errs.ErrInvalidChallengeToken
+	}
+
+	return challenge, nil
+}
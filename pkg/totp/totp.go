@@ -0,0 +1,102 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Codes follow RFC 6238 with the parameters supported by every authenticator app.
+const (
+	Digits    = 6
+	Period    = 30 * time.Second
+	modulo    = 1_000_000 // 10^Digits
+	secretLen = 20
+	// skew accepts codes of the adjacent periods, since clocks of phones drift.
+	skew = 1
+)
+
+var secretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random base32 secret shared with the authenticator app.
+func NewSecret() (string, error) {
+	secret := make([]byte, secretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	return secretEncoding.EncodeToString(secret), nil
+}
+
+// GetProvisioningURI returns the otpauth URI which authenticator apps read from a QR code.
+func GetProvisioningURI(issuer, accountName, secret string) string {
+	params := url.Values{
+		"secret":    {secret},
+		"issuer":    {issuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(Digits)},
+		"period":    {fmt.Sprint(int(Period.Seconds()))},
+	}
+
+	// the label is escaped as a path, the colon separating the issuer is kept
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(accountName)
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// GetCode returns the code of the period containing t.
+func GetCode(secret string, t time.Time) (string, error) {
+	key, err := secretEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+
+	return getCode(key, getStep(t)), nil
+}
+
+// validate returns the period step of the code, accepting only steps after lastStep,
+// so that a code can not be used twice.
+func validate(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	key, err := secretEncoding.DecodeString(secret)
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+
+	step := getStep(t)
+	for i := int64(-skew); i <= skew; i++ {
+		if step+i <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(getCode(key, step+i)), []byte(code)) == 1 {
+			return step + i, true
+		}
+	}
+
+	return 0, false
+}
+
+func getStep(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+func getCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation of RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", Digits, value%modulo)
+}
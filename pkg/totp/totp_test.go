@@ -0,0 +1,117 @@
+package totp
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-web-api/errs"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the test vectors of RFC 6238, "12345678901234567890".
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGetCodeRFC6238(t *testing.T) {
+	// the RFC lists 8 digits, codes of 6 digits are their last 6
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		got, err := GetCode(rfc6238Secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.want[len(tt.want)-Digits:]; got != want {
+			t.Errorf("T=%d: got code %s, want %s", tt.unix, got, want)
+		}
+	}
+}
+
+func newTestAuthenticator(t *testing.T, now *time.Time) (*Authenticator, uuid.UUID, string, []string) {
+	t.Helper()
+
+	a := NewAuthenticator("").(*Authenticator)
+	a.now = func() time.Time { return *now }
+	userID := uuid.New()
+
+	enrollment, err := a.Enroll(context.Background(), userID, "BookSmart", "librarian")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := GetCode(enrollment.Secret, *now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recoveryCodes, err := a.Confirm(context.Background(), userID, code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a, userID, enrollment.Secret, recoveryCodes
+}
+
+func TestVerifyRejectsReplayedCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	a, userID, secret, _ := newTestAuthenticator(t, &now)
+
+	// the code confirming the enrolment is used up
+	code, _ := GetCode(secret, now)
+	if err := a.Verify(context.Background(), userID, code); !errors.Is(err, errs.ErrInvalidTwoFactorCode) {
+		t.Fatalf("got error %v replaying the code, want ErrInvalidTwoFactorCode", err)
+	}
+
+	now = now.Add(Period)
+	code, _ = GetCode(secret, now)
+	if err := a.Verify(context.Background(), userID, code); err != nil {
+		t.Fatalf("got error %v with the code of the next period", err)
+	}
+	if err := a.Verify(context.Background(), userID, code); !errors.Is(err, errs.ErrInvalidTwoFactorCode) {
+		t.Fatalf("got error %v replaying the code, want ErrInvalidTwoFactorCode", err)
+	}
+
+	// a code of the skewed previous period is older than the used one
+	previousCode, _ := GetCode(secret, now.Add(-Period))
+	if err := a.Verify(context.Background(), userID, previousCode); !errors.Is(err, errs.ErrInvalidTwoFactorCode) {
+		t.Fatalf("got error %v with the code of the previous period, want ErrInvalidTwoFactorCode", err)
+	}
+}
+
+func TestVerifyUsesUpRecoveryCode(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	a, userID, _, recoveryCodes := newTestAuthenticator(t, &now)
+	if len(recoveryCodes) != RecoveryCodesNumber {
+		t.Fatalf("got %d recovery codes, want %d", len(recoveryCodes), RecoveryCodesNumber)
+	}
+
+	if err := a.Verify(context.Background(), userID, recoveryCodes[0]); err != nil {
+		t.Fatalf("got error %v with a recovery code", err)
+	}
+	if err := a.Verify(context.Background(), userID, recoveryCodes[0]); !errors.Is(err, errs.ErrInvalidTwoFactorCode) {
+		t.Fatalf("got error %v reusing the recovery code, want ErrInvalidTwoFactorCode", err)
+	}
+
+	// typed in upper case without the hyphen
+	typedCode := strings.ToUpper(strings.ReplaceAll(recoveryCodes[1], "-", ""))
+	if err := a.Verify(context.Background(), userID, typedCode); err != nil {
+		t.Fatalf("got error %v with a typed recovery code", err)
+	}
+
+	status, err := a.GetStatus(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := RecoveryCodesNumber - 2; status.RecoveryCodesLeft != want {
+		t.Errorf("got %d recovery codes left, want %d", status.RecoveryCodesLeft, want)
+	}
+}
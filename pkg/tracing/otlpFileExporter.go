@@ -0,0 +1,245 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// OTLPFileExporter writes spans in the OTLP/JSON file format: one ExportTraceServiceRequest per line.
+// Such files are readable by the otlpjsonfile receiver of the OpenTelemetry Collector.
+type OTLPFileExporter struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewOTLPFileExporter(writer io.Writer) sdktrace.SpanExporter {
+	return &OTLPFileExporter{writer: writer}
+}
+
+func (e *OTLPFileExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	line, err := json.Marshal(convertToOTLPRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, err = e.writer.Write(append(line, '\n'))
+
+	return err
+}
+
+func (e *OTLPFileExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if closer, ok := e.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string         `json:"stringValue,omitempty"`
+	BoolValue   *bool           `json:"boolValue,omitempty"`
+	IntValue    *string         `json:"intValue,omitempty"`
+	DoubleValue *float64        `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue `json:"arrayValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpValue `json:"values"`
+}
+
+func convertToOTLPRequest(spans []sdktrace.ReadOnlySpan) *otlpRequest {
+	request := &otlpRequest{}
+	resources := make(map[attribute.Distinct]*otlpResourceSpans)
+	scopes := make(map[attribute.Distinct]map[string]*otlpScopeSpans)
+
+	for _, span := range spans {
+		resourceKey := span.Resource().Equivalent()
+		resourceSpans, ok := resources[resourceKey]
+		if !ok {
+			resourceSpans = &otlpResourceSpans{
+				Resource: otlpResource{Attributes: convertToOTLPAttributes(span.Resource().Attributes())},
+			}
+			resources[resourceKey] = resourceSpans
+			scopes[resourceKey] = make(map[string]*otlpScopeSpans)
+			request.ResourceSpans = append(request.ResourceSpans, resourceSpans)
+		}
+
+		scope := span.InstrumentationScope()
+		scopeKey := scope.Name + "@" + scope.Version
+		scopeSpans, ok := scopes[resourceKey][scopeKey]
+		if !ok {
+			scopeSpans = &otlpScopeSpans{Scope: otlpScope{Name: scope.Name, Version: scope.Version}}
+			scopes[resourceKey][scopeKey] = scopeSpans
+			resourceSpans.ScopeSpans = append(resourceSpans.ScopeSpans, scopeSpans)
+		}
+
+		scopeSpans.Spans = append(scopeSpans.Spans, convertToOTLPSpan(span))
+	}
+
+	return request
+}
+
+func convertToOTLPSpan(span sdktrace.ReadOnlySpan) otlpSpan {
+	otlp := otlpSpan{
+		TraceID:           span.SpanContext().TraceID().String(),
+		SpanID:            span.SpanContext().SpanID().String(),
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()),
+		StartTimeUnixNano: formatUnixNano(span.StartTime()),
+		EndTimeUnixNano:   formatUnixNano(span.EndTime()),
+		Attributes:        convertToOTLPAttributes(span.Attributes()),
+		Status:            convertToOTLPStatus(span.Status()),
+	}
+	if span.Parent().HasSpanID() {
+		otlp.ParentSpanID = span.Parent().SpanID().String()
+	}
+
+	for _, event := range span.Events() {
+		otlp.Events = append(otlp.Events, otlpEvent{
+			TimeUnixNano: formatUnixNano(event.Time),
+			Name:         event.Name,
+			Attributes:   convertToOTLPAttributes(event.Attributes),
+		})
+	}
+
+	return otlp
+}
+
+// convertToOTLPStatus maps SDK status codes, which differ from the numeric codes of OTLP.
+func convertToOTLPStatus(status sdktrace.Status) otlpStatus {
+	switch status.Code {
+	case codes.Ok:
+		return otlpStatus{Code: 1}
+	case codes.Error:
+		return otlpStatus{Code: 2, Message: status.Description}
+	default:
+		return otlpStatus{}
+	}
+}
+
+func convertToOTLPAttributes(attrs []attribute.KeyValue) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	otlpAttrs := make([]otlpKeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		otlpAttrs = append(otlpAttrs, otlpKeyValue{Key: string(attr.Key), Value: convertToOTLPValue(attr.Value)})
+	}
+
+	return otlpAttrs
+}
+
+func convertToOTLPValue(value attribute.Value) otlpValue {
+	switch value.Type() {
+	case attribute.BOOL:
+		v := value.AsBool()
+		return otlpValue{BoolValue: &v}
+	case attribute.INT64:
+		v := strconv.FormatInt(value.AsInt64(), 10)
+		return otlpValue{IntValue: &v}
+	case attribute.FLOAT64:
+		v := value.AsFloat64()
+		return otlpValue{DoubleValue: &v}
+	case attribute.BOOLSLICE:
+		values := make([]otlpValue, 0)
+		for _, v := range value.AsBoolSlice() {
+			values = append(values, convertToOTLPValue(attribute.BoolValue(v)))
+		}
+		return otlpValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.INT64SLICE:
+		values := make([]otlpValue, 0)
+		for _, v := range value.AsInt64Slice() {
+			values = append(values, convertToOTLPValue(attribute.Int64Value(v)))
+		}
+		return otlpValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.FLOAT64SLICE:
+		values := make([]otlpValue, 0)
+		for _, v := range value.AsFloat64Slice() {
+			values = append(values, convertToOTLPValue(attribute.Float64Value(v)))
+		}
+		return otlpValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case attribute.STRINGSLICE:
+		values := make([]otlpValue, 0)
+		for _, v := range value.AsStringSlice() {
+			values = append(values, convertToOTLPValue(attribute.StringValue(v)))
+		}
+		return otlpValue{ArrayValue: &otlpArrayValue{Values: values}}
+	default:
+		v := value.Emit()
+		return otlpValue{StringValue: &v}
+	}
+}
+
+func formatUnixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
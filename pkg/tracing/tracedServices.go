@@ -0,0 +1,267 @@
+package tracing
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/nikitalystsev/BookSmart-services/core/dto"
+	"github.com/nikitalystsev/BookSmart-services/core/models"
+	"github.com/nikitalystsev/BookSmart-services/intf"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The decorators below wrap service interfaces and start a child span for every call,
+// so slow requests show which of the service calls took the time.
+
+type tracedBookService struct {
+	next   intf.IBookService
+	tracer trace.Tracer
+}
+
+func NewBookService(next intf.IBookService, tracer trace.Tracer) intf.IBookService {
+	return &tracedBookService{next: next, tracer: tracer}
+}
+
+func (s *tracedBookService) Create(ctx context.Context, book *models.BookModel) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "BookService.Create", attribute.String("book.id", book.ID.String()))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Create(ctx, book)
+}
+
+func (s *tracedBookService) Delete(ctx context.Context, ID uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "BookService.Delete", attribute.String("book.id", ID.String()))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Delete(ctx, ID)
+}
+
+func (s *tracedBookService) GetByID(ctx context.Context, ID uuid.UUID) (book *models.BookModel, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "BookService.GetByID", attribute.String("book.id", ID.String()))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.GetByID(ctx, ID)
+}
+
+func (s *tracedBookService) GetByParams(ctx context.Context, params *dto.BookParamsDTO) (books []*models.BookModel, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "BookService.GetByParams",
+		attribute.Int("page.limit", int(params.Limit)),
+		attribute.Int("page.offset", params.Offset),
+	)
+	defer func() {
+		span.SetAttributes(attribute.Int("books.count", len(books)))
+		endSpan(span, err)
+	}()
+
+	return s.next.GetByParams(ctx, params)
+}
+
+type tracedLibCardService struct {
+	next   intf.ILibCardService
+	tracer trace.Tracer
+}
+
+// libCardNumFinder mirrors the optional lookup by card number, which must stay visible through the decorator.
+type libCardNumFinder interface {
+	GetByNum(ctx context.Context, libCardNum string) (*models.LibCardModel, error)
+}
+
+type tracedLibCardNumService struct {
+	*tracedLibCardService
+	finder libCardNumFinder
+}
+
+func NewLibCardService(next intf.ILibCardService, tracer trace.Tracer) intf.ILibCardService {
+	service := &tracedLibCardService{next: next, tracer: tracer}
+
+	if finder, ok := next.(libCardNumFinder); ok {
+		return &tracedLibCardNumService{tracedLibCardService: service, finder: finder}
+	}
+
+	return service
+}
+
+func (s *tracedLibCardService) Create(ctx context.Context, readerID uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "LibCardService.Create", attribute.String("reader.id", readerID.String()))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Create(ctx, readerID)
+}
+
+func (s *tracedLibCardService) Update(ctx context.Context, libCard *models.LibCardModel) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "LibCardService.Update", attribute.String("lib_card.id", libCard.ID.String()))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Update(ctx, libCard)
+}
+
+func (s *tracedLibCardService) GetByReaderID(ctx context.Context, readerID uuid.UUID) (libCard *models.LibCardModel, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "LibCardService.GetByReaderID", attribute.String("reader.id", readerID.String()))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.GetByReaderID(ctx, readerID)
+}
+
+func (s *tracedLibCardNumService) GetByNum(ctx context.Context, libCardNum string) (libCard *models.LibCardModel, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "LibCardService.GetByNum")
+	defer func() { endSpan(span, err) }()
+
+	return s.finder.GetByNum(ctx, libCardNum)
+}
+
+type tracedReaderService struct {
+	next   intf.IReaderService
+	tracer trace.Tracer
+}
+
+func NewReaderService(next intf.IReaderService, tracer trace.Tracer) intf.IReaderService {
+	return &tracedReaderService{next: next, tracer: tracer}
+}
+
+func (s *tracedReaderService) SignUp(ctx context.Context, reader *models.ReaderModel) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReaderService.SignUp")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.SignUp(ctx, reader)
+}
+
+func (s *tracedReaderService) SignIn(ctx context.Context, phoneNumber, password string) (tokens *models.Tokens, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReaderService.SignIn")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.SignIn(ctx, phoneNumber, password)
+}
+
+func (s *tracedReaderService) GetByID(ctx context.Context, ID uuid.UUID) (reader *models.ReaderModel, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReaderService.GetByID", attribute.String("reader.id", ID.String()))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.GetByID(ctx, ID)
+}
+
+func (s *tracedReaderService) GetByPhoneNumber(ctx context.Context, phoneNumber string) (reader *models.ReaderModel, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReaderService.GetByPhoneNumber")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.GetByPhoneNumber(ctx, phoneNumber)
+}
+
+func (s *tracedReaderService) RefreshTokens(ctx context.Context, refreshToken string) (tokens *models.Tokens, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReaderService.RefreshTokens")
+	defer func() { endSpan(span, err) }()
+
+	return s.next.RefreshTokens(ctx, refreshToken)
+}
+
+func (s *tracedReaderService) AddToFavorites(ctx context.Context, readerID, bookID uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReaderService.AddToFavorites",
+		attribute.String("reader.id", readerID.String()),
+		attribute.String("book.id", bookID.String()),
+	)
+	defer func() { endSpan(span, err) }()
+
+	return s.next.AddToFavorites(ctx, readerID, bookID)
+}
+
+type tracedReservationService struct {
+	next   intf.IReservationService
+	tracer trace.Tracer
+}
+
+func NewReservationService(next intf.IReservationService, tracer trace.Tracer) intf.IReservationService {
+	return &tracedReservationService{next: next, tracer: tracer}
+}
+
+func (s *tracedReservationService) Create(ctx context.Context, readerID, bookID uuid.UUID) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReservationService.Create",
+		attribute.String("reader.id", readerID.String()),
+		attribute.String("book.id", bookID.String()),
+	)
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Create(ctx, readerID, bookID)
+}
+
+func (s *tracedReservationService) Update(ctx context.Context, reservation *models.ReservationModel, extentionPeriodDays int) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReservationService.Update",
+		attribute.String("reservation.id", reservation.ID.String()),
+		attribute.Int("reservation.extention_period_days", extentionPeriodDays),
+	)
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Update(ctx, reservation, extentionPeriodDays)
+}
+
+func (s *tracedReservationService) GetByBookID(ctx context.Context, bookID uuid.UUID) (reservations []*models.ReservationModel, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReservationService.GetByBookID", attribute.String("book.id", bookID.String()))
+	defer func() {
+		span.SetAttributes(attribute.Int("reservations.count", len(reservations)))
+		endSpan(span, err)
+	}()
+
+	return s.next.GetByBookID(ctx, bookID)
+}
+
+func (s *tracedReservationService) GetAllReservationsByReaderID(ctx context.Context, readerID uuid.UUID) (reservations []*models.ReservationModel, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReservationService.GetAllReservationsByReaderID", attribute.String("reader.id", readerID.String()))
+	defer func() {
+		span.SetAttributes(attribute.Int("reservations.count", len(reservations)))
+		endSpan(span, err)
+	}()
+
+	return s.next.GetAllReservationsByReaderID(ctx, readerID)
+}
+
+func (s *tracedReservationService) GetByID(ctx context.Context, ID uuid.UUID) (reservation *models.ReservationModel, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "ReservationService.GetByID", attribute.String("reservation.id", ID.String()))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.GetByID(ctx, ID)
+}
+
+type tracedRatingService struct {
+	next   intf.IRatingService
+	tracer trace.Tracer
+}
+
+func NewRatingService(next intf.IRatingService, tracer trace.Tracer) intf.IRatingService {
+	return &tracedRatingService{next: next, tracer: tracer}
+}
+
+func (s *tracedRatingService) Create(ctx context.Context, rating *models.RatingModel) (err error) {
+	ctx, span := startSpan(ctx, s.tracer, "RatingService.Create", attribute.String("book.id", rating.BookID.String()))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.Create(ctx, rating)
+}
+
+func (s *tracedRatingService) GetByBookID(ctx context.Context, bookID uuid.UUID) (ratings []*models.RatingModel, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "RatingService.GetByBookID", attribute.String("book.id", bookID.String()))
+	defer func() {
+		span.SetAttributes(attribute.Int("ratings.count", len(ratings)))
+		endSpan(span, err)
+	}()
+
+	return s.next.GetByBookID(ctx, bookID)
+}
+
+func (s *tracedRatingService) GetAvgRatingByBookID(ctx context.Context, bookID uuid.UUID) (avgRating float32, err error) {
+	ctx, span := startSpan(ctx, s.tracer, "RatingService.GetAvgRatingByBookID", attribute.String("book.id", bookID.String()))
+	defer func() { endSpan(span, err) }()
+
+	return s.next.GetAvgRatingByBookID(ctx, bookID)
+}
+
+func startSpan(ctx context.Context, tracer trace.Tracer, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
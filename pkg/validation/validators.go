@@ -0,0 +1,76 @@
+package validation
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"time"
+)
+
+// Tags of the custom validators, e.g. `binding:"required,phone"`.
+const (
+	TagPhone      = "phone"
+	TagUUIDNotNil = "uuid_not_nil"
+	TagDate       = "date"
+	TagDateTime   = "date_time"
+)
+
+const (
+	PhoneNumberLen = 11
+	DateLayout     = time.DateOnly
+	DateTimeLayout = time.RFC3339
+)
+
+// Register adds the custom validators to validate.
+func Register(validate *validator.Validate) error {
+	validators := map[string]validator.Func{
+		TagPhone:      isPhoneNumber,
+		TagUUIDNotNil: isUUIDNotNil,
+		TagDate:       isDate,
+		TagDateTime:   isDateTime,
+	}
+
+	for tag, fn := range validators {
+		if err := validate.RegisterValidation(tag, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isPhoneNumber accepts phone numbers of 11 digits without formatting, e.g. 89161234567.
+func isPhoneNumber(fl validator.FieldLevel) bool {
+	phoneNumber := fl.Field().String()
+	if len(phoneNumber) != PhoneNumberLen {
+		return false
+	}
+
+	for _, r := range phoneNumber {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isUUIDNotNil accepts strings which are UUIDs other than the nil UUID.
+func isUUIDNotNil(fl validator.FieldLevel) bool {
+	id, err := uuid.Parse(fl.Field().String())
+
+	return err == nil && id != uuid.Nil
+}
+
+// isDate accepts dates in the format of DateLayout, e.g. 2024-09-19.
+func isDate(fl validator.FieldLevel) bool {
+	_, err := time.Parse(DateLayout, fl.Field().String())
+
+	return err == nil
+}
+
+// isDateTime accepts times in the format of DateTimeLayout, e.g. 2024-09-19T10:30:00+03:00.
+func isDateTime(fl validator.FieldLevel) bool {
+	_, err := time.Parse(DateTimeLayout, fl.Field().String())
+
+	return err == nil
+}
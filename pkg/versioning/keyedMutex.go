@@ -0,0 +1,49 @@
+package versioning
+
+import "sync"
+
+// IKeyedMutex serializes the check of a resource version and its update,
+// so that two requests with the same If-Match cannot both succeed.
+type IKeyedMutex interface {
+	Lock(key string) (unlock func())
+}
+
+type keyedLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// KeyedMutex locks resources of a single instance. Locks are dropped when
+// no request holds or waits for them.
+type KeyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+func NewKeyedMutex() IKeyedMutex {
+	return &KeyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+func (km *KeyedMutex) Lock(key string) func() {
+	km.mu.Lock()
+	lock, ok := km.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		km.locks[key] = lock
+	}
+	lock.refs++
+	km.mu.Unlock()
+
+	lock.mu.Lock()
+
+	return func() {
+		lock.mu.Unlock()
+
+		km.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(km.locks, key)
+		}
+		km.mu.Unlock()
+	}
+}
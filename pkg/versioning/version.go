@@ -0,0 +1,22 @@
+package versioning
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Compute derives the version of a resource from the values of its fields, so
+// the version changes with every change of the resource wherever it was made.
+func Compute(fields ...any) string {
+	hash := sha256.New()
+	for _, field := range fields {
+		if t, ok := field.(time.Time); ok {
+			field = t.UTC().Format(time.RFC3339Nano) // without the monotonic clock reading
+		}
+		_, _ = fmt.Fprintf(hash, "%v\x00", field)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}